@@ -1,11 +1,11 @@
 package main
 
 import (
-	"bytes"
-	"crypto/rand"
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"sync"
 )
@@ -23,6 +23,10 @@ type CipherTransform interface {
 
 // Интерфейс для симметричного алгоритма (п.3)
 type SymmetricAlgorithm interface {
+	// BlockSize возвращает размер блока в байтах; известен до установки ключа
+	BlockSize() int
+	// KeySizes возвращает допустимые длины ключа в байтах
+	KeySizes() []int
 	SetKey(key []byte) error
 	Encrypt(data []byte) ([]byte, error)
 	Decrypt(data []byte) ([]byte, error)
@@ -51,20 +55,50 @@ const (
 	ANSIX923
 	PKCS7
 	ISO10126
+	ISO7816
+	TrailingBitComplement
+	PKCS5
+	NoPadding
 )
 
+// BitPadding - битовая набивка (ISO/IEC 9797-1, метод 2); для данных, кратных байту,
+// она побайтово совпадает с ISO/IEC 7816-4, поэтому это та же схема
+const BitPadding = ISO7816
+
 // Класс, репрезентирующий контекст выполнения симметричного криптографического алгоритма (п.4)
 type CryptoSymmetricContext struct {
-	key         []byte
-	cipher      SymmetricAlgorithm
-	mode        CipherMode
-	padding     PaddingMode
-	iv          []byte
-	extraParams map[string]interface{}
-	blockSize   int
-}
-
-// конструктор
+	key       []byte
+	cipher    SymmetricAlgorithm
+	mode      CipherMode
+	padding   PaddingMode
+	iv        []byte
+	blockSize int
+	// segmentSize - размер сегмента CFB в байтах; 0 - размер блока
+	segmentSize int
+	// counter - раскладка блока счетчика CTR
+	counter CounterLayout
+	// workers - число горутин для параллельных режимов; 0 - runtime.GOMAXPROCS(0)
+	workers int
+	// overwrite разрешает файловым методам заменять существующий выходной файл
+	overwrite bool
+	// allowMissingKCV разрешает дешифровать шифротекст без заголовка с KCV
+	allowMissingKCV bool
+	// random - источник случайности для IV, набивки ISO 10126 и режима RandomDelta; nil - crypto/rand
+	random io.Reader
+	// logger - журнал отладочных записей; nil - логгер библиотеки (см. SetLogger)
+	logger *slog.Logger
+	// keyCache - кэш расписаний ключей для SetKey; nil - расписание строится заново
+	keyCache *KeyScheduleCache
+
+	// block - экземпляр шифра с текущим ключом; cipher при смене ключа не изменяется,
+	// а block заменяется целиком, поэтому Encrypt и SetKey можно вызывать из разных горутин
+	keyMu sync.RWMutex
+	block BlockCipher
+}
+
+// NewCryptoSymmetricContext - прежний конструктор с позиционными параметрами, обертка над NewContext.
+// IV для режимов без IV (ECB, RandomDelta) игнорируется, blockSize должен совпадать с cipher.BlockSize().
+// extraParams оставлены для совместимости и не используются; новые параметры задаются опциями NewContext.
 func NewCryptoSymmetricContext(
 	key []byte,
 	cipher SymmetricAlgorithm,
@@ -74,39 +108,21 @@ func NewCryptoSymmetricContext(
 	blockSize int,
 	extraParams ...interface{}) (*CryptoSymmetricContext, error) {
 
-	// Проверка длины ключа
-	if len(key) != blockSize && len(key) != 16 && len(key) != 24 && len(key) != 32 {
-		return nil, errors.New("key size is invalid")
-	}
-
-	// Инициализация контекста
-	cstc := &CryptoSymmetricContext{
-		key:         key,
-		cipher:      cipher,
-		mode:        mode,
-		padding:     padding,
-		iv:          iv,
-		extraParams: make(map[string]interface{}),
-		blockSize:   blockSize,
-	}
-	fmt.Printf("IV установлен в контексте: %x\n", iv)
-
-	// Установка ключа в cipher
-	if err := cstc.cipher.SetKey(key); err != nil {
-		return nil, fmt.Errorf("failed to set key: %w", err)
+	opts := []ContextOption{WithMode(mode), WithPadding(padding), WithBlockSize(blockSize)}
+	if blockMode, err := mode.BlockMode(); err == nil && blockMode.IVSize(blockSize) > 0 {
+		opts = append(opts, WithIV(iv))
 	}
+	return NewContext(key, cipher, opts...)
+}
 
-	// Обработка дополнительных параметров
-	for i := 0; i < len(extraParams); i += 2 {
-		if i+1 < len(extraParams) {
-			paramKey, ok := extraParams[i].(string)
-			if ok {
-				cstc.extraParams[paramKey] = extraParams[i+1]
-			}
-		}
-	}
+// BlockSize возвращает размер блока алгоритма контекста
+func (cstc *CryptoSymmetricContext) BlockSize() int {
+	return cstc.blockSize
+}
 
-	return cstc, nil
+// KeySizes возвращает допустимые длины ключа алгоритма контекста
+func (cstc *CryptoSymmetricContext) KeySizes() []int {
+	return cstc.cipher.KeySizes()
 }
 
 // Реализация метода SetKey из интерфейса SymmetricAlgorithm
@@ -114,7 +130,16 @@ func (cstc *CryptoSymmetricContext) SetKey(key []byte) error {
 	if cstc.cipher == nil {
 		return errors.New("cipher not initialized")
 	}
-	return cstc.cipher.SetKey(key)
+	block, err := keyedCipherFor(cstc.keyCache, cstc.cipher, key)
+	if err != nil {
+		return err
+	}
+
+	cstc.keyMu.Lock()
+	cstc.key = key
+	cstc.block = block
+	cstc.keyMu.Unlock()
+	return nil
 }
 
 // Реализация метода Encrypt из интерфейса SymmetricAlgorithm
@@ -122,87 +147,89 @@ func (cstc *CryptoSymmetricContext) SetKey(key []byte) error {
 func (cstc *CryptoSymmetricContext) Encrypt(data []byte) ([]byte, error) {
 	// Проверка входных данных
 	if data == nil || len(data) == 0 {
-		return nil, errors.New("data cannot be nil or empty")
+		return nil, ErrEmptyInput
 	}
 
 	// Добавление набивки
 	dataPadded, err := cstc.AddPadding(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add padding: %v", err)
+		return nil, fmt.Errorf("failed to add padding: %w", err)
 	}
 
-	var encrypted []byte
-
-	// Шифрование в зависимости от режима
-	switch cstc.mode {
-	case ECB:
-		encrypted, err = cstc.encryptECB(dataPadded)
-	case CBC:
-		encrypted, err = cstc.encryptCBC(dataPadded)
-	case PCBC:
-		encrypted, err = cstc.encryptPCBC(dataPadded)
-	case CFB:
-		encrypted, err = cstc.encryptCFB(dataPadded)
-	case OFB:
-		encrypted, err = cstc.encryptOFB(dataPadded)
-	case CTR:
-		encrypted, err = cstc.encryptCTR(dataPadded)
-	case RandomDelta:
-		encrypted, err = cstc.encryptRandomDelta(dataPadded)
-	default:
-		err = errors.New("unsupported cipher mode")
+	// Режимы, работающие только с полными блоками, не принимают невыровненные данные (например, при NoPadding)
+	blockMode, err := cstc.mode.BlockMode()
+	if err != nil {
+		return nil, err
+	}
+	// Режим с сегментами короче блока (CFB-8) выравнивает данные по сегменту
+	unit, unitName := cstc.blockSize, "block size"
+	if segmented, ok := blockMode.(SegmentedMode); ok {
+		if segment := segmented.SegmentSize(cstc.modeParams()); segment != cstc.blockSize {
+			unit, unitName = segment, "segment size"
+		}
+	}
+	if blockMode.NeedsPadding() && len(dataPadded)%unit != 0 {
+		return nil, fmt.Errorf("%w: %s mode requires data length (%d) to be a multiple of %s (%d)", ErrInvalidBlockSize, blockMode.Name(), len(dataPadded), unitName, unit)
 	}
 
+	// Шифрование в режиме, взятом из реестра
+	encrypted, err := blockMode.Encrypt(cstc.modeParams(), dataPadded)
 	if err != nil {
-		return nil, fmt.Errorf("encryption failed: %v", err)
+		return nil, fmt.Errorf("%s mode: %w", blockMode.Name(), err)
 	}
 
 	return encrypted, nil
 }
 
+// ErrDecryption - единственная ошибка, которую возвращает Decrypt для непустых данных.
+// Ошибки режима и набивки не различаются, чтобы не давать оракула набивки;
+// errors.Is(ErrDecryption, ErrAuthenticationFailed) истинно.
+var ErrDecryption = fmt.Errorf("decryption failed: %w", ErrAuthenticationFailed)
+
 // Реализация метода Decrypt из интерфейса SymmetricAlgorithm
 func (cstc *CryptoSymmetricContext) Decrypt(data []byte) ([]byte, error) {
 	// Проверка входных данных
 	if data == nil || len(data) == 0 {
-		return nil, errors.New("data cannot be nil or empty")
+		return nil, ErrEmptyInput
 	}
 
-	var decrypted []byte
-	var err error
-
-	// Дешифрование в зависимости от режима
-	switch cstc.mode {
-	case ECB:
-		decrypted, err = cstc.decryptECB(data)
-	case CBC:
-		decrypted, err = cstc.decryptCBC(data)
-	case PCBC:
-		decrypted, err = cstc.decryptPCBC(data)
-	case CFB:
-		decrypted, err = cstc.decryptCFB(data)
-	case OFB:
-		decrypted, err = cstc.decryptOFB(data)
-	case CTR:
-		decrypted, err = cstc.decryptCTR(data)
-	case RandomDelta:
-		decrypted, err = cstc.decryptRandomDelta(data)
-	default:
-		err = errors.New("unsupported cipher mode")
+	// Дешифрование в режиме, взятом из реестра
+	blockMode, err := cstc.mode.BlockMode()
+	if err != nil {
+		return nil, ErrDecryption
 	}
-
+	decrypted, err := blockMode.Decrypt(cstc.modeParams(), data)
 	if err != nil {
-		return nil, fmt.Errorf("decryption failed: %v", err)
+		return nil, ErrDecryption
 	}
 
 	// Удаление набивки
 	decrypted, err = cstc.RemovePadding(decrypted)
 	if err != nil {
-		return nil, fmt.Errorf("failed to remove padding: %v", err)
+		return nil, ErrDecryption
 	}
 
 	return decrypted, nil
 }
 
+// modeParams собирает параметры для режима шифрования из состояния контекста
+func (cstc *CryptoSymmetricContext) modeParams() ModeParams {
+	cstc.keyMu.RLock()
+	block := cstc.block
+	cstc.keyMu.RUnlock()
+
+	return ModeParams{
+		Cipher:      block,
+		BlockSize:   cstc.blockSize,
+		IV:          cstc.iv,
+		Workers:     cstc.workers,
+		Rand:        cstc.random,
+		SegmentSize: cstc.segmentSize,
+		Counter:     cstc.counter,
+		Logger:      cstc.logger,
+	}
+}
+
 // Асинхронное шифрование
 func (cstc *CryptoSymmetricContext) EncryptAsync(data []byte) (<-chan []byte, <-chan error) {
 	resultChan := make(chan []byte, 1) // Канал для результата
@@ -246,612 +273,239 @@ func (cstc *CryptoSymmetricContext) DecryptAsync(data []byte) (<-chan []byte, <-
 func (cstc *CryptoSymmetricContext) EncryptToFile(inputPath, outputPath string) error {
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to open input file: %v", err)
+		return fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer inputFile.Close()
 
-	outputFile, err := os.Create(outputPath)
+	// Результат появится под именем outputPath только после успешной записи
+	outputFile, err := CreateAtomic(outputPath, 0o644, cstc.overwrite)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer outputFile.Close()
+	defer outputFile.Abort()
 
 	// Читаем весь файл
 	data, err := io.ReadAll(inputFile)
 	if err != nil {
-		return fmt.Errorf("failed to read input file: %v", err)
+		return fmt.Errorf("failed to read input file: %w", err)
 	}
 
-	// Шифруем данные, используя выбранный режим и набивку
+	// Шифруем данные, используя выбранный режим и набивку; перед шифротекстом - заголовок с KCV
 	encryptedData, err := cstc.Encrypt(data)
+	if err == nil {
+		encryptedData, err = cstc.withKCVHeader(encryptedData)
+	}
 	if err != nil {
-		return fmt.Errorf("encryption failed: %v", err)
+		return fmt.Errorf("encryption failed: %w", err)
 	}
 
 	// Записываем зашифрованные данные в выходной файл
 	if _, err := outputFile.Write(encryptedData); err != nil {
-		return fmt.Errorf("failed to write to output file: %v", err)
+		return fmt.Errorf("failed to write to output file: %w", err)
 	}
 
-	return nil
+	return outputFile.Commit()
 }
 func (cstc *CryptoSymmetricContext) DecryptFromFile(inputPath, outputPath string) error {
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to open input file: %v", err)
+		return fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer inputFile.Close()
 
-	outputFile, err := os.Create(outputPath)
+	// Результат появится под именем outputPath только после успешной записи
+	outputFile, err := CreateAtomic(outputPath, 0o644, cstc.overwrite)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer outputFile.Close()
+	defer outputFile.Abort()
 
 	// Читаем весь файл
 	data, err := io.ReadAll(inputFile)
 	if err != nil {
-		return fmt.Errorf("failed to read input file: %v", err)
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	// KCV из заголовка сверяется до дешифрования: неверный ключ дает ErrKeyMismatch
+	if data, err = cstc.stripKCVHeader(data); err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
 	}
 
 	// Дешифруем данные, используя выбранный режим и удаляя набивку
 	decryptedData, err := cstc.Decrypt(data)
 	if err != nil {
-		return fmt.Errorf("decryption failed: %v", err)
+		return err
 	}
 
 	// Записываем расшифрованные данные в выходной файл
 	if _, err := outputFile.Write(decryptedData); err != nil {
-		return fmt.Errorf("failed to write to output file: %v", err)
+		return fmt.Errorf("failed to write to output file: %w", err)
 	}
 
-	return nil
+	return outputFile.Commit()
 }
 
-// Реализация режима ECB с распараллеливанием
-func (cstc *CryptoSymmetricContext) encryptECB(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-
-	// Проверка, что длина данных кратна размеру блока
-	if len(data)%blockSize != 0 {
-		return nil, fmt.Errorf("data length (%d) is not a multiple of block size (%d)", len(data), blockSize)
+// EncryptWithIVPrefix шифрует данные и возвращает IV контекста, за которым следует шифротекст,
+// чтобы при дешифровании IV не нужно было передавать отдельно
+func (cstc *CryptoSymmetricContext) EncryptWithIVPrefix(data []byte) ([]byte, error) {
+	ivSize, err := cstc.ivSize()
+	if err != nil {
+		return nil, err
 	}
-
-	numBlocks := len(data) / blockSize
-	encrypted := make([]byte, len(data))
-
-	var wg sync.WaitGroup
-	errChan := make(chan error, numBlocks)
-
-	for i := 0; i < numBlocks; i++ {
-		wg.Add(1)
-		go func(blockIndex int) {
-			defer wg.Done()
-			bs := blockIndex * blockSize
-			block := data[bs : bs+blockSize]
-
-			// Используем метод Encrypt из SymmetricAlgorithm
-			encryptedBlock, err := cstc.cipher.Encrypt(block)
-			if err != nil {
-				errChan <- fmt.Errorf("encryption failed at block %d: %w", blockIndex, err)
-				return
-			}
-			copy(encrypted[bs:], encryptedBlock)
-		}(i)
+	if err := checkIVSize(cstc.iv, ivSize); err != nil {
+		return nil, err
 	}
 
-	wg.Wait()
-	close(errChan)
-
-	// Проверяем наличие ошибок
-	if err, ok := <-errChan; ok {
+	encryptedData, err := cstc.Encrypt(data)
+	if err != nil {
 		return nil, err
 	}
-
-	return encrypted, nil
+	return append(append([]byte{}, cstc.iv...), encryptedData...), nil
 }
 
-func (cstc *CryptoSymmetricContext) decryptECB(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-
-	// Проверка, что длина данных кратна размеру блока
-	if len(data)%blockSize != 0 {
-		return nil, fmt.Errorf("data length (%d) is not a multiple of block size (%d)", len(data), blockSize)
-	}
-
-	numBlocks := len(data) / blockSize
-	decrypted := make([]byte, len(data))
-
-	var wg sync.WaitGroup
-	errChan := make(chan error, numBlocks)
-
-	for i := 0; i < numBlocks; i++ {
-		wg.Add(1)
-		go func(blockIndex int) {
-			defer wg.Done()
-			bs := blockIndex * blockSize
-			block := data[bs : bs+blockSize]
-
-			// Используем метод Decrypt из SymmetricAlgorithm
-			decryptedBlock, err := cstc.cipher.Decrypt(block)
-			if err != nil {
-				errChan <- fmt.Errorf("decryption failed at block %d: %w", blockIndex, err)
-				return
-			}
-			copy(decrypted[bs:], decryptedBlock)
-		}(i)
-	}
-
-	wg.Wait()
-	close(errChan)
-
-	// Проверяем наличие ошибок
-	if err, ok := <-errChan; ok {
+// DecryptWithIVPrefix читает IV из начала данных, записанных EncryptWithIVPrefix,
+// и дешифрует остаток; IV самого контекста не используется и не изменяется
+func (cstc *CryptoSymmetricContext) DecryptWithIVPrefix(data []byte) ([]byte, error) {
+	ivSize, err := cstc.ivSize()
+	if err != nil {
 		return nil, err
 	}
-
-	return decrypted, nil
-}
-
-// Реализация режима CBC без распараллеливания
-func (cstc *CryptoSymmetricContext) encryptCBC(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, errors.New("invalid IV size")
+	if len(data) <= ivSize {
+		return nil, fmt.Errorf("%w: input is too short to contain IV and ciphertext", ErrAuthenticationFailed)
 	}
-
-	encrypted := make([]byte, len(data))
-	numBlocks := len(data) / blockSize
-	previous := make([]byte, blockSize)
-	copy(previous, cstc.iv)
-
-	for i := 0; i < numBlocks; i++ {
-		start := i * blockSize
-		block := data[start : start+blockSize]
-
-		// XOR текущего блока с предыдущим зашифрованным блоком
-		inputBlock := make([]byte, blockSize)
-		for j := 0; j < blockSize; j++ {
-			inputBlock[j] = block[j] ^ previous[j]
-		}
-
-		// Шифруем блок
-		encryptedBlock, err := cstc.cipher.Encrypt(inputBlock)
-		if err != nil {
-			return nil, fmt.Errorf("encryption failed at block %d: %w", i, err)
-		}
-
-		copy(encrypted[start:], encryptedBlock)
-		copy(previous, encryptedBlock)
-	}
-
-	return encrypted, nil
+	return cstc.withIV(data[:ivSize]).Decrypt(data[ivSize:])
 }
 
-func (cstc *CryptoSymmetricContext) decryptCBC(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, fmt.Errorf("invalid IV size: expected %d, got %d", blockSize, len(cstc.iv))
-	}
-
-	if len(data)%blockSize != 0 {
-		return nil, fmt.Errorf("data length (%d) is not a multiple of block size (%d)", len(data), blockSize)
+// EncryptToFileWithIV шифрует файл и записывает заголовок с KCV и IV контекста перед шифротекстом
+func (cstc *CryptoSymmetricContext) EncryptToFileWithIV(inputPath, outputPath string) error {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
 	}
-
-	decrypted := make([]byte, len(data))
-	numBlocks := len(data) / blockSize
-	previous := make([]byte, blockSize)
-	copy(previous, cstc.iv)
-
-	for i := 0; i < numBlocks; i++ {
-		start := i * blockSize
-		block := data[start : start+blockSize]
-
-		// Расшифровка текущего блока
-		decryptedBlock, err := cstc.cipher.Decrypt(block)
-		if err != nil {
-			return nil, fmt.Errorf("decryption failed at block %d: %w", i, err)
-		}
-
-		// XOR с предыдущим зашифрованным блоком
-		for j := 0; j < blockSize; j++ {
-			decrypted[start+j] = decryptedBlock[j] ^ previous[j]
-		}
-
-		// Обновляем previous для следующего блока
-		copy(previous, block)
+	encryptedData, err := cstc.EncryptWithIVPrefix(data)
+	if err == nil {
+		encryptedData, err = cstc.withKCVHeader(encryptedData)
 	}
-
-	return decrypted, nil
-}
-
-// Реализация режима PCBC без распараллеливания
-func (cstc *CryptoSymmetricContext) encryptPCBC(data []byte) ([]byte, error) {
-	// Из-за зависимости между блоками распараллеливание ограничено
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, errors.New("invalid IV size")
+	if err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
 	}
-
-	encrypted := make([]byte, len(data))
-	numBlocks := len(data) / blockSize
-	previousPlaintext := make([]byte, blockSize)
-	previousCiphertext := make([]byte, blockSize)
-	copy(previousCiphertext, cstc.iv)
-
-	for i := 0; i < numBlocks; i++ {
-		bs := i * blockSize
-		plaintextBlock := data[bs : bs+blockSize]
-
-		// XOR текущего блока с предыдущими
-		inputBlock := make([]byte, blockSize)
-		for j := 0; j < blockSize; j++ {
-			inputBlock[j] = plaintextBlock[j] ^ previousPlaintext[j] ^ previousCiphertext[j]
-		}
-
-		// Заменяем вызов transform.Encryption на cipher.Encrypt
-		encryptedBlock, err := cstc.cipher.Encrypt(inputBlock)
-		if err != nil {
-			return nil, fmt.Errorf("encryption failed at block %d: %w", i, err)
-		}
-
-		copy(encrypted[bs:], encryptedBlock)
-		copy(previousPlaintext, plaintextBlock)
-		copy(previousCiphertext, encryptedBlock)
+	if err := WriteFileAtomic(outputPath, encryptedData, 0o644, cstc.overwrite); err != nil {
+		return fmt.Errorf("failed to write to output file: %w", err)
 	}
-
-	return encrypted, nil
+	return nil
 }
 
-func (cstc *CryptoSymmetricContext) decryptPCBC(data []byte) ([]byte, error) {
-	// Из-за зависимости между блоками распараллеливание ограничено
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, errors.New("invalid IV size")
+// DecryptFromFileWithIV дешифрует файл, записанный EncryptToFileWithIV
+func (cstc *CryptoSymmetricContext) DecryptFromFileWithIV(inputPath, outputPath string) error {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
 	}
-
-	decrypted := make([]byte, len(data))
-	numBlocks := len(data) / blockSize
-	previousPlaintext := make([]byte, blockSize)
-	previousCiphertext := make([]byte, blockSize)
-	copy(previousCiphertext, cstc.iv)
-
-	for i := 0; i < numBlocks; i++ {
-		bs := i * blockSize
-		block := data[bs : bs+blockSize]
-
-		// Заменяем вызов transform.Decryption на cipher.Decrypt
-		decryptedBlock, err := cstc.cipher.Decrypt(block)
-		if err != nil {
-			return nil, fmt.Errorf("decryption failed at block %d: %w", i, err)
-		}
-
-		for j := 0; j < blockSize; j++ {
-			decrypted[bs+j] = decryptedBlock[j] ^ previousPlaintext[j] ^ previousCiphertext[j]
-		}
-
-		copy(previousPlaintext, decrypted[bs:bs+blockSize])
-		copy(previousCiphertext, block)
+	if data, err = cstc.stripKCVHeader(data); err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
 	}
-
-	return decrypted, nil
-}
-
-// Реализация режима CFB с распараллеливанием
-func (cstc *CryptoSymmetricContext) encryptCFB(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, errors.New("invalid IV size")
+	decryptedData, err := cstc.DecryptWithIVPrefix(data)
+	if err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
 	}
-
-	encrypted := make([]byte, len(data))
-	numBlocks := len(data) / blockSize
-	feedback := make([]byte, blockSize)
-	copy(feedback, cstc.iv)
-
-	for i := 0; i < numBlocks; i++ {
-		bs := i * blockSize
-		plaintextBlock := data[bs : bs+blockSize]
-
-		// Используем метод Encrypt из SymmetricAlgorithm для получения выходного блока
-		outputBlock, err := cstc.cipher.Encrypt(feedback)
-		if err != nil {
-			return nil, fmt.Errorf("encryption failed at block %d: %w", i, err)
-		}
-
-		for j := 0; j < blockSize; j++ {
-			encrypted[bs+j] = plaintextBlock[j] ^ outputBlock[j]
-		}
-
-		copy(feedback, encrypted[bs:bs+blockSize])
+	if err := WriteFileAtomic(outputPath, decryptedData, 0o644, cstc.overwrite); err != nil {
+		return fmt.Errorf("failed to write to output file: %w", err)
 	}
-
-	return encrypted, nil
+	return nil
 }
 
-func (cstc *CryptoSymmetricContext) decryptCFB(data []byte) ([]byte, error) {
-	// Из-за цепочки зависимостей распараллеливание ограничено
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, errors.New("invalid IV size")
-	}
-
-	decrypted := make([]byte, len(data))
-	numBlocks := len(data) / blockSize
-	feedback := make([]byte, blockSize)
-	copy(feedback, cstc.iv)
-
-	for i := 0; i < numBlocks; i++ {
-		bs := i * blockSize
-		ciphertextBlock := data[bs : bs+blockSize]
-
-		// Используем метод Encrypt из SymmetricAlgorithm для получения выходного блока
-		outputBlock, err := cstc.cipher.Encrypt(feedback)
-		if err != nil {
-			return nil, fmt.Errorf("encryption failed at block %d: %w", i, err)
-		}
-
-		for j := 0; j < blockSize; j++ {
-			decrypted[bs+j] = ciphertextBlock[j] ^ outputBlock[j]
-		}
-
-		copy(feedback, ciphertextBlock)
+// ivSize возвращает длину IV, которую требует режим контекста
+func (cstc *CryptoSymmetricContext) ivSize() (int, error) {
+	blockMode, err := cstc.mode.BlockMode()
+	if err != nil {
+		return 0, err
 	}
-
-	return decrypted, nil
+	return blockMode.IVSize(cstc.blockSize), nil
 }
 
-func (cstc *CryptoSymmetricContext) encryptOFB(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, errors.New("invalid IV size")
+// withIV возвращает копию контекста с тем же ключом и другим IV
+func (cstc *CryptoSymmetricContext) withIV(iv []byte) *CryptoSymmetricContext {
+	cstc.keyMu.RLock()
+	defer cstc.keyMu.RUnlock()
+	return &CryptoSymmetricContext{
+		key:             cstc.key,
+		cipher:          cstc.cipher,
+		mode:            cstc.mode,
+		padding:         cstc.padding,
+		iv:              iv,
+		blockSize:       cstc.blockSize,
+		segmentSize:     cstc.segmentSize,
+		counter:         cstc.counter,
+		workers:         cstc.workers,
+		overwrite:       cstc.overwrite,
+		allowMissingKCV: cstc.allowMissingKCV,
+		random:          cstc.random,
+		logger:          cstc.logger,
+		keyCache:        cstc.keyCache,
+		block:           cstc.block,
 	}
-
-	encrypted := make([]byte, len(data))
-	feedback := make([]byte, blockSize)
-	copy(feedback, cstc.iv)
-	fmt.Printf("Используемый IV: %x\n", cstc.iv)
-	for i := 0; i < len(data); i += blockSize {
-		// Шифруем текущий `feedback`
-		outputBlock, err := cstc.cipher.Encrypt(feedback)
-		if err != nil {
-			return nil, fmt.Errorf("encryption failed at block %d: %w", i/blockSize, err)
-		}
-
-		// XOR текущего блока данных с зашифрованным `feedback`
-		for j := 0; j < blockSize && i+j < len(data); j++ {
-			encrypted[i+j] = data[i+j] ^ outputBlock[j]
-		}
-
-		// Обновляем `feedback`
-		copy(feedback, outputBlock)
-	}
-
-	return encrypted, nil
 }
 
-func (cstc *CryptoSymmetricContext) decryptOFB(data []byte) ([]byte, error) {
-	// OFB режим симметричен для шифрования и дешифрования
-	return cstc.encryptOFB(data)
+// withWorkers возвращает копию контекста с другим числом горутин для параллельных режимов
+func (cstc *CryptoSymmetricContext) withWorkers(workers int) *CryptoSymmetricContext {
+	copied := cstc.withIV(cstc.iv)
+	copied.workers = workers
+	return copied
 }
 
-// Реализация режима CTR с распараллеливанием
-func (cstc *CryptoSymmetricContext) encryptCTR(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-	if len(cstc.iv) != blockSize {
-		return nil, errors.New("invalid IV size")
-	}
-
-	numBlocks := (len(data) + blockSize - 1) / blockSize
-	encrypted := make([]byte, len(data))
-
-	var wg sync.WaitGroup
-	errChan := make(chan error, numBlocks)
-	mutex := &sync.Mutex{} // Для синхронизации доступа к `counter`
-
-	counter := make([]byte, blockSize)
-	copy(counter, cstc.iv)
-
-	for i := 0; i < numBlocks; i++ {
-		wg.Add(1)
-		go func(blockIndex int) {
-			defer wg.Done()
-
-			// Инкрементируем счетчик на номер блока
-			mutex.Lock()
-			currentCounter := make([]byte, blockSize)
-			copy(currentCounter, counter)
-			incrementCounter(currentCounter, blockIndex)
-			mutex.Unlock()
-
-			// Шифруем текущий счетчик для получения keystream блока
-			keystreamBlock, err := cstc.cipher.Encrypt(currentCounter)
-			if err != nil {
-				errChan <- fmt.Errorf("encryption failed at block %d: %w", blockIndex, err)
-				return
-			}
-
-			bs := blockIndex * blockSize
-			be := bs + blockSize
-			if be > len(data) {
-				be = len(data)
-			}
-
-			chunkSize := be - bs
-			for j := 0; j < chunkSize; j++ {
-				encrypted[bs+j] = data[bs+j] ^ keystreamBlock[j]
-			}
-		}(i)
-	}
-
-	wg.Wait()
-	close(errChan)
-
-	// Проверяем наличие ошибок
-	if err, ok := <-errChan; ok {
-		return nil, err
-	}
-
-	return encrypted, nil
+// SetOverwrite разрешает или запрещает файловым методам заменять существующий выходной файл.
+// По умолчанию перезапись запрещена и возвращается ErrOutputExists.
+func (cstc *CryptoSymmetricContext) SetOverwrite(allow bool) {
+	cstc.overwrite = allow
 }
 
-func (cstc *CryptoSymmetricContext) decryptCTR(data []byte) ([]byte, error) {
-	// CTR режим симметричен для шифрования и дешифрования
-	return cstc.encryptCTR(data)
+// SetAllowMissingKCV разрешает или запрещает дешифровать шифротекст без заголовка с KCV,
+// например записанный до появления KCV. По умолчанию такой шифротекст отвергается с ErrMissingKCV:
+// без KCV неверный ключ обнаруживается только по ошибке набивки или по мусору на выходе.
+func (cstc *CryptoSymmetricContext) SetAllowMissingKCV(allow bool) {
+	cstc.allowMissingKCV = allow
 }
 
-// Дополнительная функция для инкрементации счетчика с учетом номера блока
-func incrementCounter(counter []byte, blockIndex int) {
-	// Инкрементируем счетчик на значение blockIndex
-	carry := blockIndex
-	for i := len(counter) - 1; i >= 0 && carry > 0; i-- {
-		sum := int(counter[i]) + (carry & 0xFF)
-		counter[i] = byte(sum & 0xFF)
-		carry = (carry >> 8) + (sum >> 8)
-	}
+// SetRand задает источник случайности контекста; nil возвращает crypto/rand.
+// Детерминированный источник (NewHMACDRBG) нужен только для воспроизводимых проверок.
+// Чтения из него сериализуются, поэтому контекст можно использовать из нескольких горутин,
+// но при конкурентных вызовах порядок, в котором они получают случайные байты, не определен:
+// воспроизводим только результат последовательных вызовов.
+func (cstc *CryptoSymmetricContext) SetRand(random io.Reader) {
+	cstc.random = lockRandom(random)
 }
 
-func (cstc *CryptoSymmetricContext) encryptRandomDelta(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-	encrypted := make([]byte, len(data))
-
-	// Генерация delta
-	delta := make([]byte, blockSize)
-	if _, err := rand.Read(delta); err != nil {
-		return nil, fmt.Errorf("failed to generate delta: %w", err)
-	}
-
-	// Шифрование блоков
-	for i := 0; i < len(data); i += blockSize {
-		blockEnd := i + blockSize
-		if blockEnd > len(data) {
-			blockEnd = len(data) // Для последнего неполного блока
-		}
-
-		block := data[i:blockEnd]
-		for j := 0; j < len(block); j++ {
-			encrypted[i+j] = block[j] + delta[j%blockSize]
-		}
-	}
-
-	// Сохраняем `delta` в зашифрованные данные (например, в начало файла)
-	return append(delta, encrypted...), nil
+// randomReader возвращает источник случайности контекста
+func (cstc *CryptoSymmetricContext) randomReader() io.Reader {
+	return randomSource(cstc.random)
 }
 
-func (cstc *CryptoSymmetricContext) decryptRandomDelta(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-
-	// Извлечение `delta` из данных
-	if len(data) < blockSize {
-		return nil, errors.New("data too short to contain delta")
-	}
-	delta := data[:blockSize]
-	data = data[blockSize:]
-
-	decrypted := make([]byte, len(data))
-
-	// Дешифрование блоков
-	for i := 0; i < len(data); i += blockSize {
-		blockEnd := i + blockSize
-		if blockEnd > len(data) {
-			blockEnd = len(data) // Для последнего неполного блока
-		}
-
-		block := data[i:blockEnd]
-		for j := 0; j < len(block); j++ {
-			decrypted[i+j] = block[j] - delta[j%blockSize]
-		}
+// log возвращает журнал контекста
+func (cstc *CryptoSymmetricContext) log() *slog.Logger {
+	if cstc.logger != nil {
+		return cstc.logger
 	}
-
-	return decrypted, nil
+	return Logger()
 }
 
 // Реализация методов добавления и удаления набивки
 func (cstc *CryptoSymmetricContext) AddPadding(data []byte) ([]byte, error) {
-	blockSize := cstc.blockSize
-	paddingLen := blockSize - (len(data) % blockSize)
-	if paddingLen == 0 {
-		paddingLen = blockSize
+	padding, err := cstc.padding.Padding()
+	if err != nil {
+		return nil, err
 	}
-
-	switch cstc.padding {
-	case Zeros:
-		return ZerosPadding(data, paddingLen), nil
-	case ANSIX923:
-		return ANSIX923Padding(data, paddingLen), nil
-	case PKCS7:
-		return PKCS7Padding(data, paddingLen), nil
-	case ISO10126:
-		return ISO10126Padding(data, paddingLen)
-	default:
-		return nil, errors.New("unsupported padding mode")
+	if randomized, ok := padding.(RandomizedPadding); ok {
+		return randomized.PadRand(data, cstc.blockSize, cstc.randomReader())
 	}
+	return padding.Pad(data, cstc.blockSize)
 }
 
 func (cstc *CryptoSymmetricContext) RemovePadding(data []byte) ([]byte, error) {
-	switch cstc.padding {
-	case Zeros:
-		return removeZerosPadding(data), nil
-	case ANSIX923:
-		return removeANSIX923Padding(data)
-	case PKCS7:
-		return removePKCS7Padding(data)
-	case ISO10126:
-		return removePKCS7Padding(data)
-	default:
-		return nil, errors.New("unsupported padding mode")
-	}
-}
-
-// Реализация функций набивки и удаления набивки
-
-func ZerosPadding(data []byte, paddingLen int) []byte {
-	padding := bytes.Repeat([]byte{0}, paddingLen)
-	return append(data, padding...)
-}
-
-func removeZerosPadding(data []byte) []byte {
-	return bytes.TrimRight(data, "\x00")
-}
-
-func ANSIX923Padding(data []byte, paddingLen int) []byte {
-	padding := append(bytes.Repeat([]byte{0}, paddingLen-1), byte(paddingLen))
-	return append(data, padding...)
-}
-
-func removeANSIX923Padding(data []byte) ([]byte, error) {
-	paddingLen := int(data[len(data)-1])
-	if paddingLen > len(data) {
-		return nil, errors.New("invalid padding length")
-	}
-	return data[:len(data)-paddingLen], nil
-}
-
-func PKCS7Padding(data []byte, paddingLen int) []byte {
-	padding := bytes.Repeat([]byte{byte(paddingLen)}, paddingLen)
-	return append(data, padding...)
-}
-
-func removePKCS7Padding(data []byte) ([]byte, error) {
-	paddingLen := int(data[len(data)-1])
-	if paddingLen > len(data) {
-		return nil, errors.New("invalid padding length")
-	}
-	for i := 1; i <= paddingLen; i++ {
-		if data[len(data)-i] != byte(paddingLen) {
-			return nil, errors.New("invalid PKCS7 padding")
-		}
-	}
-	return data[:len(data)-paddingLen], nil
-}
-
-func ISO10126Padding(data []byte, paddingLen int) ([]byte, error) {
-	padding := make([]byte, paddingLen)
-	if _, err := rand.Read(padding[:paddingLen-1]); err != nil {
+	padding, err := cstc.padding.Padding()
+	if err != nil {
 		return nil, err
 	}
-	padding[paddingLen-1] = byte(paddingLen)
-	return append(data, padding...), nil
+	return padding.Unpad(data, cstc.blockSize)
 }
 
 // Реализация дополнительных методов шифрования и дешифрования для файлов с поддержкой асинхронности
@@ -875,41 +529,24 @@ func (cstc *CryptoSymmetricContext) encryptFile(inputPath, outputPath string) er
 	}
 	defer inputFile.Close()
 
-	outputFile, err := os.Create(outputPath)
+	outputFile, err := CreateAtomic(outputPath, 0o644, cstc.overwrite)
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
-
-	blockSize := cstc.blockSize
-	buffer := make([]byte, blockSize*1024) // Читаем по несколько блоков за раз
+	defer outputFile.Abort()
 
-	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return err
-		}
-
-		if n == 0 {
-			break
-		}
-
-		dataChunk := buffer[:n]
-		encryptedData, err := cstc.Encrypt(dataChunk)
-		if err != nil {
-			return err
-		}
-
-		if _, err := outputFile.Write(encryptedData); err != nil {
-			return err
-		}
-
-		if err == io.EOF {
-			break
-		}
+	header, err := cstc.kcvHeader()
+	if err != nil {
+		return err
+	}
+	if _, err := outputFile.Write(header); err != nil {
+		return err
 	}
 
-	return nil
+	if err := cstc.encryptStream(inputFile, outputFile); err != nil {
+		return err
+	}
+	return outputFile.Commit()
 }
 
 func (cstc *CryptoSymmetricContext) DecryptFileAsync(inputPath, outputPath string) <-chan error {
@@ -931,39 +568,137 @@ func (cstc *CryptoSymmetricContext) decryptFile(inputPath, outputPath string) er
 	}
 	defer inputFile.Close()
 
-	outputFile, err := os.Create(outputPath)
+	outputFile, err := CreateAtomic(outputPath, 0o644, cstc.overwrite)
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
+	defer outputFile.Abort()
+
+	if err := cstc.skipKCVHeader(inputFile); err != nil {
+		return err
+	}
+
+	if err := cstc.decryptStream(inputFile, outputFile); err != nil {
+		return err
+	}
+	return outputFile.Commit()
+}
 
-	blockSize := cstc.blockSize
-	buffer := make([]byte, blockSize*1024) // Читаем по несколько блоков за раз
+// fileChunkBlocks - число блоков, которые файловые методы читают за раз
+const fileChunkBlocks = 1024
 
+// forEachChunk читает r частями по chunkSize байт (при chunkSize <= 0 - целиком) и вызывает fn
+// для каждой; last истинно для последней части. Пустой ввод не дает ни одной части.
+func forEachChunk(r io.Reader, chunkSize int, fn func(chunk []byte, last bool) error) error {
+	if chunkSize <= 0 {
+		data, err := io.ReadAll(r)
+		if err != nil || len(data) == 0 {
+			return err
+		}
+		return fn(data, true)
+	}
+	reader := bufio.NewReader(r)
+	buffer := make([]byte, chunkSize)
 	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
+		n, err := io.ReadFull(reader, buffer)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
 			return err
 		}
-
-		if n == 0 {
-			break
+		// Полная часть может оказаться последней: это видно только по следующему байту
+		last := err == io.ErrUnexpectedEOF
+		if !last {
+			if _, err := reader.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return err
+			}
 		}
-
-		dataChunk := buffer[:n]
-		decryptedData, err := cstc.Decrypt(dataChunk)
-		if err != nil {
+		if err := fn(buffer[:n], last); err != nil {
 			return err
 		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// streamChunkSize возвращает размер части для потоковой обработки и режим, продолжающий цепочку;
+// режимы без ChainedMode обрабатывают данные одним вызовом (chunkSize 0)
+func (cstc *CryptoSymmetricContext) streamChunkSize() (BlockMode, ChainedMode, int, error) {
+	blockMode, err := cstc.mode.BlockMode()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	chained, ok := blockMode.(ChainedMode)
+	if !ok {
+		return blockMode, nil, 0, nil
+	}
+	return blockMode, chained, cstc.blockSize * fileChunkBlocks, nil
+}
 
-		if _, err := outputFile.Write(decryptedData); err != nil {
+// encryptStream шифрует r в w частями. Набивка добавляется только к последней части,
+// а следующая часть продолжает цепочку режима, поэтому результат совпадает с Encrypt всего ввода.
+func (cstc *CryptoSymmetricContext) encryptStream(r io.Reader, w io.Writer) error {
+	blockMode, chained, chunkSize, err := cstc.streamChunkSize()
+	if err != nil {
+		return err
+	}
+	ctx := cstc
+	return forEachChunk(r, chunkSize, func(chunk []byte, last bool) error {
+		if last {
+			encrypted, err := ctx.Encrypt(chunk)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(encrypted)
 			return err
 		}
-
-		if err == io.EOF {
-			break
+		p := ctx.modeParams()
+		encrypted, err := blockMode.Encrypt(p, chunk)
+		if err != nil {
+			return fmt.Errorf("%s mode: %w", blockMode.Name(), err)
 		}
-	}
+		iv, err := chained.NextIV(p, chunk, encrypted)
+		if err != nil {
+			return fmt.Errorf("%s mode: %w", blockMode.Name(), err)
+		}
+		ctx = cstc.withIV(iv)
+		_, err = w.Write(encrypted)
+		return err
+	})
+}
 
-	return nil
+// decryptStream дешифрует r в w частями; набивка снимается только с последней части.
+// Как и Decrypt, для испорченных данных возвращает только ErrDecryption.
+func (cstc *CryptoSymmetricContext) decryptStream(r io.Reader, w io.Writer) error {
+	blockMode, chained, chunkSize, err := cstc.streamChunkSize()
+	if err != nil {
+		return ErrDecryption
+	}
+	ctx := cstc
+	return forEachChunk(r, chunkSize, func(chunk []byte, last bool) error {
+		if last {
+			decrypted, err := ctx.Decrypt(chunk)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(decrypted)
+			return err
+		}
+		p := ctx.modeParams()
+		decrypted, err := blockMode.Decrypt(p, chunk)
+		if err != nil {
+			return ErrDecryption
+		}
+		iv, err := chained.NextIV(p, decrypted, chunk)
+		if err != nil {
+			return ErrDecryption
+		}
+		ctx = cstc.withIV(iv)
+		_, err = w.Write(decrypted)
+		return err
+	})
 }
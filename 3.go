@@ -1,7 +1,7 @@
 package main
 
 import (
-	"errors"
+	"fmt"
 )
 
 // FeistelNetwork представляет реализацию сети Фейстеля.
@@ -10,6 +10,8 @@ type FeistelNetwork struct {
 	KeyRounds KeyRound
 	Transform CipherTransform
 	roundKeys [][]byte
+	// trace вызывается после каждого раунда шифрования; используется только для отладки
+	trace func(round int, roundKey, left, right []byte)
 }
 
 // Конструктор FeistelNetwork
@@ -21,12 +23,23 @@ func NewFeistelNetwork(rounds int, KeyRounds KeyRound, Transforms CipherTransfor
 	}
 }
 
+// RoundKeyPreparer - необязательный интерфейс раундовой функции, которой нужно
+// подготовить состояние для раундовых ключей заранее, а не при каждом вызове
+type RoundKeyPreparer interface {
+	PrepareRoundKeys(roundKeys [][]byte) error
+}
+
 // Метод для установки ключа и генерации раундовых ключей
 func (fn *FeistelNetwork) SetKey(key []byte) error {
 	roundKeys, err := fn.KeyRounds.GenerateKeys(key)
 	if err != nil {
 		return err
 	}
+	if preparer, ok := fn.Transform.(RoundKeyPreparer); ok {
+		if err := preparer.PrepareRoundKeys(roundKeys); err != nil {
+			return err
+		}
+	}
 	fn.roundKeys = roundKeys
 	return nil
 }
@@ -34,7 +47,7 @@ func (fn *FeistelNetwork) SetKey(key []byte) error {
 // Метод шифрования
 func (fn *FeistelNetwork) Encrypt(block []byte) ([]byte, error) {
 	if len(block)%2 != 0 {
-		return nil, errors.New("block size must be even")
+		return nil, fmt.Errorf("%w: Feistel block length must be even, got %d", ErrInvalidBlockSize, len(block))
 	}
 
 	left := block[:len(block)/2]
@@ -44,22 +57,25 @@ func (fn *FeistelNetwork) Encrypt(block []byte) ([]byte, error) {
 		roundKey := fn.roundKeys[i]
 		fOutput, err := fn.Transform.Encryption(right, roundKey)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("round %d: %w", i+1, err)
 		}
 
 		newLeft := xorBytes(left, fOutput)
 		left, right = right, newLeft
+
+		if fn.trace != nil {
+			fn.trace(i+1, roundKey, left, right)
+		}
 	}
 
-	// Объединение левой и правой частей
-	ciphertext := append(left, right...)
-	return ciphertext, nil
+	// Объединение левой и правой частей в новый срез, чтобы не писать в массив вызывающей стороны
+	return joinHalves(left, right), nil
 }
 
 // Метод дешифрования
 func (fn *FeistelNetwork) Decrypt(block []byte) ([]byte, error) {
 	if len(block)%2 != 0 {
-		return nil, errors.New("block size must be even")
+		return nil, fmt.Errorf("%w: Feistel block length must be even, got %d", ErrInvalidBlockSize, len(block))
 	}
 
 	left := block[:len(block)/2]
@@ -69,16 +85,22 @@ func (fn *FeistelNetwork) Decrypt(block []byte) ([]byte, error) {
 		roundKey := fn.roundKeys[i]
 		fOutput, err := fn.Transform.Encryption(left, roundKey)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("round %d: %w", i+1, err)
 		}
 
 		newRight := xorBytes(right, fOutput)
 		right, left = left, newRight
 	}
 
-	// Объединение левой и правой частей
-	plaintext := append(left, right...)
-	return plaintext, nil
+	// Объединение левой и правой частей в новый срез, чтобы не писать в массив вызывающей стороны
+	return joinHalves(left, right), nil
+}
+
+// joinHalves объединяет половины блока в новый срез
+func joinHalves(left, right []byte) []byte {
+	block := make([]byte, 0, len(left)+len(right))
+	block = append(block, left...)
+	return append(block, right...)
 }
 
 // Вспомогательная функция для XOR двух срезов байтов
@@ -10,75 +10,164 @@ import (
 type DES struct {
 	feistel   *FeistelNetwork
 	blockSize int
+	keyPolicy DESKeyPolicy
 }
 
 // NewDES создает новый экземпляр DES
 func NewDES() (*DES, error) {
-	keySchedule := &DESKeySchedule{}
+	return NewDESWithKeyPolicy(DESKeyPolicy{})
+}
+
+// NewDESWithKeyPolicy создает DES, который отвергает в SetKey ключи, запрещенные policy
+func NewDESWithKeyPolicy(policy DESKeyPolicy) (*DES, error) {
+	keySchedule := &DESKeySchedule{Policy: policy}
 	roundFunction := &DESRoundFunction{}
 
 	feistel := NewFeistelNetwork(16, keySchedule, roundFunction)
 	des := &DES{
 		feistel:   feistel,
-		blockSize: 16,
+		blockSize: 8,
+		keyPolicy: policy,
 	}
 
 	return des, nil
 }
 
+// Name возвращает имя алгоритма
+func (des *DES) Name() string { return "DES" }
+
+// BlockSize возвращает размер блока DES - 8 байт
+func (des *DES) BlockSize() int { return des.blockSize }
+
+// KeySizes возвращает длину ключа DES: 8 байт вместе с битами четности
+func (des *DES) KeySizes() []int { return []int{8} }
+
 // SetKey устанавливает ключ для алгоритма DES
 func (des *DES) SetKey(key []byte) error {
 	return des.feistel.SetKey(key)
 }
 
+// Начальная перестановка IP
+var desInitialPermutation = []int{
+	58, 50, 42, 34, 26, 18, 10, 2,
+	60, 52, 44, 36, 28, 20, 12, 4,
+	62, 54, 46, 38, 30, 22, 14, 6,
+	64, 56, 48, 40, 32, 24, 16, 8,
+	57, 49, 41, 33, 25, 17, 9, 1,
+	59, 51, 43, 35, 27, 19, 11, 3,
+	61, 53, 45, 37, 29, 21, 13, 5,
+	63, 55, 47, 39, 31, 23, 15, 7,
+}
+
+// Конечная перестановка IP^-1
+var desFinalPermutation = []int{
+	40, 8, 48, 16, 56, 24, 64, 32,
+	39, 7, 47, 15, 55, 23, 63, 31,
+	38, 6, 46, 14, 54, 22, 62, 30,
+	37, 5, 45, 13, 53, 21, 61, 29,
+	36, 4, 44, 12, 52, 20, 60, 28,
+	35, 3, 43, 11, 51, 19, 59, 27,
+	34, 2, 42, 10, 50, 18, 58, 26,
+	33, 1, 41, 9, 49, 17, 57, 25,
+}
+
+// NewKeyed возвращает отдельный экземпляр DES с ключом key; сам des не изменяется
+func (des *DES) NewKeyed(key []byte) (BlockCipher, error) {
+	keyed, err := NewDESWithKeyPolicy(des.keyPolicy)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyedBlock(keyed, key)
+}
+
 // Encrypt шифрует блок данных
 func (des *DES) Encrypt(block []byte) ([]byte, error) {
-	if len(block) != 8 {
-		return nil, errors.New("block size must be 8 bytes")
+	if len(block) != des.blockSize {
+		return nil, blockSizeError(des.blockSize, len(block))
+	}
+
+	permuted, err := PermuteBits(block, desInitialPermutation, true, 1)
+	if err != nil {
+		return nil, err
+	}
+	output, err := des.feistel.Encrypt(permuted)
+	if err != nil {
+		return nil, err
 	}
-	return des.feistel.Encrypt(block)
+
+	// После 16 раундов половины меняются местами (R16 L16) перед IP^-1
+	return PermuteBits(swapHalves(output), desFinalPermutation, true, 1)
 }
 
 // Decrypt дешифрует блок данных
 func (des *DES) Decrypt(block []byte) ([]byte, error) {
-	if len(block) != 8 {
-		return nil, errors.New("block size must be 8 bytes")
+	if len(block) != des.blockSize {
+		return nil, blockSizeError(des.blockSize, len(block))
+	}
+
+	permuted, err := PermuteBits(block, desInitialPermutation, true, 1)
+	if err != nil {
+		return nil, err
 	}
-	return des.feistel.Decrypt(block)
+	output, err := des.feistel.Decrypt(swapHalves(permuted))
+	if err != nil {
+		return nil, err
+	}
+	return PermuteBits(output, desFinalPermutation, true, 1)
+}
+
+// swapHalves возвращает новый срез с переставленными левой и правой половинами
+func swapHalves(block []byte) []byte {
+	half := len(block) / 2
+	swapped := make([]byte, 0, len(block))
+	swapped = append(swapped, block[half:]...)
+	return append(swapped, block[:half]...)
 }
 
 // DESKeySchedule реализует интерфейс KeyRound для DES
-type DESKeySchedule struct{}
+type DESKeySchedule struct {
+	// Policy - какие ключи отвергать; нулевое значение принимает любые 8 байт
+	Policy DESKeyPolicy
+}
+
+// Перестановка PC-1 (удаление битов четности)
+var desPC1 = []int{
+	57, 49, 41, 33, 25, 17, 9,
+	1, 58, 50, 42, 34, 26, 18,
+	10, 2, 59, 51, 43, 35, 27,
+	19, 11, 3, 60, 52, 44, 36,
+
+	63, 55, 47, 39, 31, 23, 15,
+	7, 62, 54, 46, 38, 30, 22,
+	14, 6, 61, 53, 45, 37, 29,
+	21, 13, 5, 28, 20, 12, 4,
+}
+
+// desKeyHalves возвращает половины C и D ключа после PC-1
+func desKeyHalves(key []byte) ([]int, []int, error) {
+	permutedKeyBits, err := PermuteBitsToBits(key, desPC1, true, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return permutedKeyBits[:28], permutedKeyBits[28:], nil
+}
 
 // GenerateKeys генерирует раундовые ключи для DES
 func (ks *DESKeySchedule) GenerateKeys(inputKey []byte) ([][]byte, error) {
 	if len(inputKey) != 8 {
-		return nil, errors.New("key must be 8 bytes (64 bits)")
+		return nil, &KeySizeError{Algorithm: "DES", Size: len(inputKey), Valid: []int{8}}
 	}
 
-	// Перестановка PC-1 (удаление битов четности)
-	pc1 := []int{
-		57, 49, 41, 33, 25, 17, 9,
-		1, 58, 50, 42, 34, 26, 18,
-		10, 2, 59, 51, 43, 35, 27,
-		19, 11, 3, 60, 52, 44, 36,
-
-		63, 55, 47, 39, 31, 23, 15,
-		7, 62, 54, 46, 38, 30, 22,
-		14, 6, 61, 53, 45, 37, 29,
-		21, 13, 5, 28, 20, 12, 4,
+	if err := ks.Policy.Check(inputKey); err != nil {
+		return nil, err
 	}
 
-	// Применяем перестановку PC-1
-	permutedKeyBits, err := PermuteBitsToBits(inputKey, pc1, false, 1)
+	// Применяем перестановку PC-1 и разделяем результат на левую (C) и правую (D) части по 28 бит
+	c, d, err := desKeyHalves(inputKey)
 	if err != nil {
 		return nil, err
 	}
 
-	// Разделяем на левую (C) и правую (D) части по 28 бит
-	c := permutedKeyBits[:28]
-	d := permutedKeyBits[28:]
-
 	// Количество сдвигов для каждого раунда
 	shiftSchedule := []int{
 		1, 1, 2, 2, 2, 2, 2, 2,
@@ -137,7 +226,7 @@ func (rf *DESRoundFunction) Encryption(rightHalf, roundKey []byte) ([]byte, erro
 	}
 
 	// Применяем расширение E
-	expandedRightBits, err := PermuteBitsToBits(rightHalf, eTable, false, 1)
+	expandedRightBits, err := PermuteBitsToBits(rightHalf, eTable, true, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -1,174 +1,1115 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	mathrand "math/rand"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
-var cipherModes = map[string]CipherMode{
-	"ECB":         ECB,
-	"CBC":         CBC,
-	"PCBC":        PCBC,
-	"CFB":         CFB,
-	"OFB":         OFB,
-	"CTR":         CTR,
-	"RandomDelta": RandomDelta,
+// cliCommand - подкоманда CLI
+type cliCommand struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
 }
 
-var paddingModes = map[string]PaddingMode{
-	"Zeros":    Zeros,
-	"ANSIX923": ANSIX923,
-	"PKCS7":    PKCS7,
-	"ISO10126": ISO10126,
+// cliCommands перечислены в порядке вывода в справке
+var cliCommands []cliCommand
+
+func init() {
+	cliCommands = []cliCommand{
+		{"encrypt", "encrypt -key HEX [флаги]", "зашифровать файл, каталог или стандартный ввод", func(args []string) error { return runCryptCommand("encrypt", args) }},
+		{"decrypt", "decrypt -key HEX [флаги]", "дешифровать файл, каталог или стандартный ввод", func(args []string) error { return runCryptCommand("decrypt", args) }},
+		{"keygen", "keygen [-algorithm] [-size] [-mode]", "сгенерировать ключ и векторы инициализации", runKeyGenCommand},
+		{"kcv", "kcv -key HEX [-algorithm] [-input] [-armor]", "вычислить контрольное значение ключа (KCV) и сверить с файлом", runKCVCommand},
+		{"bench", "bench [-algorithm] [-mode] [-size] [-workers] [-format]", "измерить скорость шифрования (MB/s, выделения памяти)", runBenchCommand},
+		{"selftest", "selftest [-v] [-suite]", "известные ответы, сверка с crypto/* и проверки на соответствие", runSelfTestCommand},
+		{"keycheck", "keycheck -key HEX | -list CLASS", "проверить ключ на слабость для DES, 3DES и раундовых ключей DEAL", func(args []string) error { return runKeyCheck("keycheck", args) }},
+		{"inspect", "inspect key|errorprop|paddingoracle|registry [флаги]", "анализ ключей и свойств режимов", runInspectCommand},
+	}
+}
+
+// Коды завершения по классам ошибок
+const (
+	exitOK          = 0
+	exitFailure     = 1   // прочие ошибки
+	exitUsage       = 2   // неверные аргументы
+	exitIO          = 3   // ошибка чтения или записи
+	exitDecryption  = 4   // шифротекст не расшифровывается
+	exitCheckFailed = 5   // самопроверка или проверка ключа не пройдена
+	exitInterrupted = 130 // прервано сигналом, как принято в shell для SIGINT
+)
+
+// usageError - ошибка в аргументах командной строки; исходная ошибка доступна через errors.Is/As
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+
+func (e *usageError) Unwrap() error { return e.err }
+
+// newUsageError форматирует сообщение как fmt.Errorf, поэтому %w сохраняет исходную ошибку
+func newUsageError(format string, args ...any) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+// errInterrupted - команда прервана сигналом
+var errInterrupted = errors.New("interrupted")
+
+// checkFailedError - проверка выполнена, но не пройдена
+type checkFailedError struct {
+	msg string
+}
+
+func (e *checkFailedError) Error() string { return e.msg }
+
+// exitCode сопоставляет ошибке код завершения. Ошибки параметров шифра из библиотеки
+// считаются ошибками использования, ошибки набивки и проверки шифротекста - ошибками дешифрования.
+func exitCode(err error) int {
+	var usage *usageError
+	var check *checkFailedError
+	var pathErr *fs.PathError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &check), errors.Is(err, ErrManifestMismatch):
+		return exitCheckFailed
+	case errors.Is(err, ErrAuthenticationFailed), errors.Is(err, ErrInvalidPadding):
+		return exitDecryption
+	case errors.Is(err, ErrInvalidKeySize), errors.Is(err, ErrInvalidIV),
+		errors.Is(err, ErrInvalidBlockSize), errors.Is(err, ErrUnsupportedMode),
+		errors.Is(err, ErrUnsupportedPadding), errors.Is(err, ErrInvalidArgument):
+		return exitUsage
+	case errors.As(err, &pathErr), errors.Is(err, ErrOutputExists):
+		return exitIO
+	default:
+		return exitFailure
+	}
 }
 
 func main() {
-	// Определяем флаги
-	cipherFlag := flag.String("mode", "CBC", "Режим шифрования: ECB, CBC, PCBC, CFB, OFB, CTR, RandomDelta")
-	paddingFlag := flag.String("padding", "PKCS7", "Режим набивки: Zeros, ANSIX923, PKCS7, ISO10126")
-	algorithmFlag := flag.String("algorithm", "DES", "Алгоритм шифрования: DES или DEAL")
-	keyFlag := flag.String("key", "", "Ключ шифрования в шестнадцатеричном формате (например, \"0011223344556677\")")
-	ivFlag := flag.String("iv", "", "Вектор инициализации в шестнадцатеричном формате (например, \"8899aabbccddeeff\")")
-	inputFile := flag.String("input", "", "Путь к входному файлу")
-	outputFile := flag.String("output", "", "Путь к выходному файлу")
-	encryptFlag := flag.Bool("encrypt", true, "Шифровать (true) или дешифровать (false)")
-
-	flag.Parse()
-
-	// Проверяем, что задан входной и выходной файлы
-	if *inputFile == "" || *outputFile == "" {
-		fmt.Println("Необходимо указать входной и выходной файлы.")
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	// Получаем режим шифрования
-	cipherMode, ok := cipherModes[*cipherFlag]
-	if !ok {
-		fmt.Printf("Неверный режим шифрования: %s\n", *cipherFlag)
-		flag.Usage()
-		os.Exit(1)
+	// При прерывании отложенные вызовы не выполняются, поэтому временные файлы удаляются здесь
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		RemovePendingOutputs()
+		os.Exit(exitCode(errInterrupted))
+	}()
+
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// runCLI выполняет подкоманду и возвращает код завершения.
+// Данные пишутся в стандартный вывод, поэтому сообщения и ошибки идут в stderr.
+func runCLI(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		printUsage(os.Stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	for _, c := range cliCommands {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:])
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		}
+		return exitCode(err)
+	}
+
+	if strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Флаги указываются после подкоманды, например: encrypt -key HEX -input file -output file.enc")
+	} else {
+		fmt.Fprintf(os.Stderr, "Неизвестная команда: %s\n", args[0])
+	}
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+// printUsage печатает справку; списки алгоритмов, режимов и набивок берутся из реестров
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Использование: cryptolab <команда> [флаги]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Команды:")
+	for _, c := range cliCommands {
+		fmt.Fprintf(w, "  %-52s %s\n", c.usage, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Алгоритмы: %s\n", strings.Join(AlgorithmNames(), ", "))
+	fmt.Fprintf(w, "Режимы:    %s\n", strings.Join(BlockModeNames(), ", "))
+	fmt.Fprintf(w, "Набивки:   %s\n", paddingListing())
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Путь \"-\" означает стандартный ввод или вывод.")
+	fmt.Fprintf(w, "Коды завершения: %d - успех, %d - ошибка, %d - неверные аргументы, %d - ошибка ввода-вывода,\n",
+		exitOK, exitFailure, exitUsage, exitIO)
+	fmt.Fprintf(w, "  %d - шифротекст не расшифровывается, %d - проверка не пройдена\n", exitDecryption, exitCheckFailed)
+}
+
+// paddingListing перечисляет схемы набивки; псевдонимы указываются в скобках после схемы
+func paddingListing() string {
+	names := PaddingNames()
+	for i, name := range names {
+		if aliases := PaddingAliases(name); len(aliases) > 0 {
+			names[i] = fmt.Sprintf("%s (псевдоним: %s)", name, strings.Join(aliases, ", "))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// newFlagSet создает набор флагов подкоманды; ошибки разбора считаются ошибками использования
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{err: err}
+	}
+	if fs.NArg() > 0 {
+		return newUsageError("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return nil
+}
+
+// readInput читает весь файл или стандартный ввод для "-"
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput записывает данные в файл или в стандартный вывод для "-".
+// Файл заменяется атомарно; существующий файл перезаписывается только с force.
+func writeOutput(path string, data []byte, force bool) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return WriteFileAtomic(path, data, 0o644, force)
+}
+
+// addLogFlags добавляет флаги журнала диагностики; возвращаемая функция настраивает
+// логгер библиотеки после разбора флагов
+func addLogFlags(fs *flag.FlagSet) func() error {
+	level := fs.String("log-level", "off", "Журнал диагностики в stderr: off, debug, info, warn или error")
+	secrets := fs.Bool("log-secrets", false, "Показывать ключи и IV в журнале открытым текстом (только с -log-level debug, для занятий)")
+	return func() error {
+		if *level == "off" {
+			if *secrets {
+				return newUsageError("-log-secrets requires -log-level debug")
+			}
+			SetLogger(nil)
+			return nil
+		}
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(*level)); err != nil {
+			return newUsageError("invalid -log-level: %s", *level)
+		}
+		if *secrets && lvl != slog.LevelDebug {
+			return newUsageError("-log-secrets requires -log-level debug")
+		}
+		SetLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl})))
+		SetLogSecrets(*secrets)
+		return nil
+	}
+}
+
+// runCryptCommand выполняет encrypt и decrypt
+func runCryptCommand(name string, args []string) error {
+	encrypt := name == "encrypt"
+
+	fs := newFlagSet(name)
+	algorithmFlag := fs.String("algorithm", "DES", "Алгоритм: "+strings.Join(AlgorithmNames(), ", "))
+	modeFlag := fs.String("mode", "CBC", "Режим шифрования: "+strings.Join(BlockModeNames(), ", "))
+	paddingFlag := fs.String("padding", "PKCS7", "Режим набивки: "+paddingListing())
+	keyFlag := fs.String("key", "", "Ключ в шестнадцатеричном формате (например, \"0011223344556677\")")
+	ivFlag := fs.String("iv", "", "Вектор инициализации в шестнадцатеричном формате (например, \"8899aabbccddeeff\")")
+	randomIVFlag := fs.Bool("random-iv", false, "Шифрование: сгенерировать IV и записать его перед шифротекстом; дешифрование: прочитать IV из начала данных")
+	inputFlag := fs.String("input", "-", "Входной файл или \"-\" для стандартного ввода")
+	outputFlag := fs.String("output", "-", "Выходной файл или \"-\" для стандартного вывода")
+	forceFlag := fs.Bool("force", false, "Перезаписать существующий выходной файл")
+	var kcvFlag *bool
+	if encrypt {
+		kcvFlag = fs.Bool("kcv", true, "Записать контрольное значение ключа (KCV) в заголовок шифротекста")
+	}
+	textFlag := fs.String("text", "", "Данные в аргументе вместо -input: открытый текст при шифровании, обернутый шифротекст при дешифровании")
+	var armorFlag *string
+	if encrypt {
+		armorFlag = fs.String("armor", "", "Обертка шифротекста: raw, base64, hex или pem (с -text по умолчанию base64, иначе raw)")
+	} else {
+		armorFlag = fs.String("armor", "auto", "Обертка шифротекста: auto, raw, base64, hex или pem (auto: блок PEM, иначе raw, а с -text - base64)")
+	}
+	selfTestFlag := fs.Bool("selftest", false, "Проверить DES и режимы на векторах NIST перед обработкой")
+	jobsFlag := fs.Int("jobs", 0, "Каталоги: число файлов, обрабатываемых параллельно (0 - по числу процессоров)")
+	segmentFlag := fs.Int("segment-size", 0, "CFB: размер сегмента в байтах (1 - CFB-8); по умолчанию размер блока")
+	counterFlag := fs.Int("counter-size", 0, "CTR: длина счетчика в байтах в конце блока, остальное - nonce; 0 - весь блок")
+	var encryptNamesFlag, verifyFlag, allowNoKCVFlag, rejectWeakFlag *bool
+	if encrypt {
+		encryptNamesFlag = fs.Bool("encrypt-names", false, "Каталоги: заменить имена файлов случайными, пути сохранить только в манифесте")
+		rejectWeakFlag = fs.Bool("reject-weak-keys", false, "Отвергнуть слабый или полуслабый ключ DES (для DEAL - среди раундовых ключей)")
+	} else {
+		verifyFlag = fs.Bool("verify", false, "Каталоги: только проверить файлы по манифесту, ничего не записывая")
+		allowNoKCVFlag = fs.Bool("allow-no-kcv", false, "Дешифровать шифротекст без заголовка KCV (записанный с -kcv=false или до появления KCV)")
+	}
+	setupLog := addLogFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := setupLog(); err != nil {
+		return err
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if *selfTestFlag && !SelfTestPassed(RunSelfTest(os.Stderr, false)) {
+		return &checkFailedError{msg: "self-test failed"}
+	}
+
+	// Если -input - каталог, шифруется все дерево; каждый файл получает свой случайный IV
+	dirMode := false
+	if *inputFlag != "-" && !explicit["text"] {
+		if info, err := os.Stat(*inputFlag); err == nil && info.IsDir() {
+			dirMode = true
+		}
+	}
+	if dirMode {
+		for _, name := range []string{"iv", "random-iv", "armor"} {
+			if explicit[name] {
+				return newUsageError("-%s cannot be used with a directory", name)
+			}
+		}
+		if *outputFlag == "-" && !(verifyFlag != nil && *verifyFlag) {
+			return newUsageError("-output directory is required when -input is a directory")
+		}
+	} else {
+		for _, name := range []string{"jobs", "encrypt-names", "verify"} {
+			if explicit[name] {
+				return newUsageError("-%s requires -input to be a directory", name)
+			}
+		}
 	}
 
-	// Получаем режим набивки
-	paddingMode, ok := paddingModes[*paddingFlag]
+	// Входные данные: -text или файл/стандартный ввод
+	var data []byte
+	var err error
+	if dirMode {
+		// файлы читаются при обходе дерева
+	} else if explicit["text"] {
+		if explicit["input"] {
+			return newUsageError("-text and -input are mutually exclusive")
+		}
+		data = []byte(*textFlag)
+	} else {
+		input, err := readInput(*inputFlag)
+		if err != nil {
+			return err
+		}
+		data = input
+	}
+
+	var armor ArmorFormat
+	if encrypt && !dirMode {
+		if armor, err = cliArmorFormat(*armorFlag, explicit["text"]); err != nil {
+			return err
+		}
+	}
+
+	// При дешифровании обертка снимается до создания контекста:
+	// заголовки PEM задают алгоритм, режим, набивку и IV, если они не указаны явно
+	var msg *ArmoredMessage
+	ivHex := *ivFlag
+	if !encrypt && !dirMode {
+		if msg, err = cliDearmor(data, *armorFlag, explicit["text"]); err != nil {
+			return err
+		}
+		for flagName, header := range map[string]string{"algorithm": armorHeaderAlgorithm, "mode": armorHeaderMode, "padding": armorHeaderPadding} {
+			if value, ok := msg.Headers[header]; ok && !explicit[flagName] {
+				fs.Set(flagName, value)
+			}
+		}
+		if value, ok := msg.Headers[armorHeaderIV]; ok && !explicit["iv"] && !*randomIVFlag {
+			ivHex = value
+		}
+	}
+
+	spec, ok := LookupAlgorithm(*algorithmFlag)
+	if !ok {
+		return newUsageError("unknown algorithm: %s", *algorithmFlag)
+	}
+	cipherMode, ok := LookupBlockMode(*modeFlag)
+	if !ok {
+		return newUsageError("unknown mode: %s", *modeFlag)
+	}
+	paddingMode, ok := LookupPadding(*paddingFlag)
 	if !ok {
-		fmt.Printf("Неверный режим набивки: %s\n", *paddingFlag)
-		flag.Usage()
-		os.Exit(1)
+		return newUsageError("unknown padding: %s", *paddingFlag)
+	}
+
+	if *keyFlag == "" {
+		return newUsageError("-key is required")
+	}
+	key, err := hex.DecodeString(*keyFlag)
+	if err != nil {
+		return newUsageError("invalid key: %w", err)
+	}
+	if !containsInt(spec.KeySizes, len(key)) {
+		return newUsageError("%s key must be one of %v bytes, got %d", spec.Name, spec.KeySizes, len(key))
+	}
+	if rejectWeakFlag != nil && *rejectWeakFlag {
+		if err := (DESKeyPolicy{RejectWeak: true}).CheckAlgorithmKey(spec.Name, key); err != nil {
+			return newUsageError("%w", err)
+		}
+	}
+
+	// IV контекста для каталогов не используется, но должен иметь нужную режиму длину
+	iv, err := cliIV(cipherMode, spec.BlockSize, ivHex, *randomIVFlag || dirMode)
+	if err != nil {
+		return err
+	}
+
+	cipher, err := spec.New()
+	if err != nil {
+		return err
+	}
+	opts := []ContextOption{WithMode(cipherMode), WithPadding(paddingMode), WithIV(iv)}
+	if explicit["segment-size"] {
+		opts = append(opts, WithSegmentSize(*segmentFlag))
+	}
+	if explicit["counter-size"] {
+		opts = append(opts, WithCounterLayout(CounterLayout{Size: *counterFlag}))
+	}
+	if allowNoKCVFlag != nil && *allowNoKCVFlag {
+		opts = append(opts, WithAllowMissingKCV(true))
+	}
+	ctx, err := NewContext(key, cipher, opts...)
+	if err != nil {
+		return newUsageError("%w", err)
 	}
 
-	// Выбираем алгоритм шифрования
-	var cipher SymmetricAlgorithm
-	var blockSize int
+	if dirMode {
+		return runDirCrypt(ctx, encrypt, *inputFlag, *outputFlag, DirOptions{
+			EncryptNames: encryptNamesFlag != nil && *encryptNamesFlag,
+			Workers:      *jobsFlag,
+			Overwrite:    *forceFlag,
+		}, verifyFlag != nil && *verifyFlag)
+	}
+
+	var result []byte
+	switch {
+	case encrypt && armor == ArmorPEM:
+		// IV и KCV записываются в заголовки, а не перед шифротекстом
+		var headers map[string]string
+		var ciphertext []byte
+		if headers, err = ctx.armorHeaders(); err != nil {
+			return err
+		}
+		if !*kcvFlag {
+			delete(headers, armorHeaderKCV)
+		}
+		if ciphertext, err = ctx.Encrypt(data); err == nil {
+			result, err = Armor(ciphertext, ArmorPEM, headers)
+		}
+	case encrypt:
+		var ciphertext []byte
+		if *randomIVFlag {
+			ciphertext, err = ctx.EncryptWithIVPrefix(data)
+		} else {
+			ciphertext, err = ctx.Encrypt(data)
+		}
+		if err == nil && *kcvFlag {
+			ciphertext, err = ctx.withKCVHeader(ciphertext)
+		}
+		if err == nil {
+			result, err = Armor(ciphertext, armor, nil)
+		}
+	default:
+		if ctx, err = ctx.forArmoredMessage(msg); err != nil {
+			return newUsageError("%w", err)
+		}
+		// KCV проверяется до дешифрования; шифротекст без заголовка - только с -allow-no-kcv
+		var ciphertext []byte
+		if ciphertext, err = ctx.messageCiphertext(msg); err != nil {
+			if errors.Is(err, ErrMissingKCV) {
+				return fmt.Errorf("%w (use -allow-no-kcv to decrypt it without the check)", err)
+			}
+			return err
+		}
+		if *randomIVFlag && msg.Format != ArmorPEM {
+			result, err = ctx.DecryptWithIVPrefix(ciphertext)
+		} else {
+			result, err = ctx.Decrypt(ciphertext)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := writeOutput(*outputFlag, result, *forceFlag); err != nil {
+		return err
+	}
+	if *outputFlag != "-" {
+		if encrypt {
+			fmt.Fprintln(os.Stderr, "Шифрование завершено успешно.")
+		} else {
+			fmt.Fprintln(os.Stderr, "Дешифрование завершено успешно.")
+		}
+	}
+	return nil
+}
+
+// runDirCrypt шифрует, восстанавливает или проверяет дерево каталогов
+func runDirCrypt(ctx *CryptoSymmetricContext, encrypt bool, input, output string, opts DirOptions, verify bool) error {
+	var manifest *Manifest
 	var err error
+	switch {
+	case encrypt:
+		manifest, err = ctx.EncryptDir(input, output, opts)
+	case verify:
+		manifest, err = ctx.VerifyDir(input, opts)
+	default:
+		manifest, err = ctx.DecryptDir(input, output, opts)
+	}
+	if err != nil {
+		return err
+	}
 
-	switch *algorithmFlag {
-	case "DES":
-		cipher, err = NewDES()
+	files := 0
+	var size int64
+	for _, e := range manifest.Entries {
+		if !e.Dir {
+			files++
+			size += e.Size
+		}
+	}
+	switch {
+	case encrypt:
+		fmt.Fprintf(os.Stderr, "Зашифровано файлов: %d (%d байт).\n", files, size)
+	case verify:
+		fmt.Fprintf(os.Stderr, "Проверено файлов: %d (%d байт), расхождений нет.\n", files, size)
+	default:
+		fmt.Fprintf(os.Stderr, "Восстановлено файлов: %d (%d байт).\n", files, size)
+	}
+	return nil
+}
+
+// cliArmorFormat выбирает обертку при шифровании: без -armor текст из -text
+// оборачивается в Base64, а файлы пишутся как есть
+func cliArmorFormat(name string, text bool) (ArmorFormat, error) {
+	if name == "" {
+		if text {
+			return ArmorBase64, nil
+		}
+		return ArmorRaw, nil
+	}
+	format, err := ParseArmorFormat(name)
+	if err != nil {
+		return ArmorRaw, newUsageError("%w", err)
+	}
+	return format, nil
+}
+
+// cliDearmor снимает обертку при дешифровании. auto распознает только блок PEM:
+// остальные данные считаются сырым шифротекстом, а текст из -text - Base64, как при шифровании.
+// Hex и Base64 в файлах не угадываются, их нужно указать в -armor явно.
+func cliDearmor(data []byte, name string, text bool) (*ArmoredMessage, error) {
+	if name == "auto" {
+		msg, err := Dearmor(data)
+		if err == nil && text && msg.Format == ArmorRaw {
+			msg, err = DearmorAs(data, ArmorBase64)
+		}
 		if err != nil {
-			panic(err)
+			return nil, newUsageError("%w", err)
+		}
+		return msg, nil
+	}
+	format, err := ParseArmorFormat(name)
+	if err != nil {
+		return nil, newUsageError("%w", err)
+	}
+	msg, err := DearmorAs(data, format)
+	if err != nil {
+		return nil, newUsageError("%w", err)
+	}
+	return msg, nil
+}
+
+// cliIV разбирает -iv или, при -random-iv, генерирует IV. При дешифровании с -random-iv
+// IV читается из данных, а сгенерированный нужен лишь для создания контекста.
+func cliIV(mode CipherMode, blockSize int, ivHex string, random bool) ([]byte, error) {
+	if random {
+		if ivHex != "" {
+			return nil, newUsageError("-iv and -random-iv are mutually exclusive")
+		}
+		return GenerateIV(mode, blockSize, rand.Reader)
+	}
+
+	blockMode, err := mode.BlockMode()
+	if err != nil {
+		return nil, err
+	}
+	ivSize := blockMode.IVSize(blockSize)
+	if ivSize == 0 {
+		return nil, nil
+	}
+	if ivHex == "" {
+		return nil, newUsageError("%s mode requires -iv or -random-iv", blockMode.Name())
+	}
+	iv, err := hex.DecodeString(ivHex)
+	if err != nil {
+		return nil, newUsageError("invalid IV: %w", err)
+	}
+	if len(iv) != ivSize {
+		return nil, newUsageError("IV must be %d bytes (%d hex characters)", ivSize, ivSize*2)
+	}
+	return iv, nil
+}
+
+func runKeyGenCommand(args []string) error {
+	fs := newFlagSet("keygen")
+	algorithm := fs.String("algorithm", "DES", "Алгоритм: "+strings.Join(AlgorithmNames(), ", "))
+	size := fs.Int("size", 0, "Длина ключа в байтах (0 - по умолчанию для алгоритма)")
+	mode := fs.String("mode", "", "Режим, для которого нужен IV; по умолчанию IV выводится для всех режимов")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	spec, ok := LookupAlgorithm(*algorithm)
+	if !ok {
+		return newUsageError("unknown algorithm: %s", *algorithm)
+	}
+	if *size != 0 && !containsInt(spec.KeySizes, *size) {
+		return newUsageError("%s key must be one of %v bytes, got %d", spec.Name, spec.KeySizes, *size)
+	}
+	key, err := GenerateKey(spec.Name, *size, rand.Reader)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("key: %x\n", key)
+	cipher, err := spec.New()
+	if err != nil {
+		return err
+	}
+	kcv, err := KeyCheckValue(cipher, key, spec.BlockSize)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("kcv: %s\n", FormatKCV(kcv))
+
+	modeNames := BlockModeNames()
+	if *mode != "" {
+		modeNames = []string{*mode}
+	}
+	for _, name := range modeNames {
+		cipherMode, ok := LookupBlockMode(name)
+		if !ok {
+			return newUsageError("unknown mode: %s", name)
 		}
-		blockSize = 8
-	case "DEAL":
-		cipher, err = NewDEAL()
+		iv, err := GenerateIV(cipherMode, spec.BlockSize, rand.Reader)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		blockSize = 16
-	default:
-		fmt.Printf("Неверный алгоритм шифрования: %s\n", *algorithmFlag)
-		flag.Usage()
-		os.Exit(1)
+		if iv == nil {
+			fmt.Printf("iv (%s): не требуется\n", name)
+			continue
+		}
+		fmt.Printf("iv (%s): %x\n", name, iv)
 	}
+	return nil
+}
 
-	// Получаем ключ
-	if *keyFlag == "" {
-		fmt.Println("Необходимо указать ключ шифрования через параметр -key.")
-		flag.Usage()
-		os.Exit(1)
+// runKCVCommand печатает KCV ключа; с -input сверяет его с KCV из заголовка файла
+func runKCVCommand(args []string) error {
+	fs := newFlagSet("kcv")
+	algorithm := fs.String("algorithm", "DES", "Алгоритм: "+strings.Join(AlgorithmNames(), ", "))
+	keyHex := fs.String("key", "", "Ключ в шестнадцатеричном формате")
+	input := fs.String("input", "", "Зашифрованный файл (или \"-\"), с KCV которого сверить ключ")
+	armor := fs.String("armor", "auto", "Обертка -input: auto (блок PEM, иначе raw), raw, base64, hex или pem")
+	if err := parseFlags(fs, args); err != nil {
+		return err
 	}
 
-	key, err := hex.DecodeString(*keyFlag)
+	spec, ok := LookupAlgorithm(*algorithm)
+	if !ok {
+		return newUsageError("unknown algorithm: %s", *algorithm)
+	}
+	if *keyHex == "" {
+		return newUsageError("-key is required")
+	}
+	key, err := hex.DecodeString(*keyHex)
+	if err != nil {
+		return newUsageError("invalid key: %w", err)
+	}
+	if !containsInt(spec.KeySizes, len(key)) {
+		return newUsageError("%s key must be one of %v bytes, got %d", spec.Name, spec.KeySizes, len(key))
+	}
+	cipher, err := spec.New()
+	if err != nil {
+		return err
+	}
+	kcv, err := KeyCheckValue(cipher, key, spec.BlockSize)
 	if err != nil {
-		fmt.Printf("Неверный формат ключа: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+	fmt.Printf("kcv: %s\n", FormatKCV(kcv))
+	if *input == "" {
+		return nil
+	}
+
+	data, err := readInput(*input)
+	if err != nil {
+		return err
+	}
+	stored, err := storedKCV(data, *armor)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return &checkFailedError{msg: "input has no key check value"}
+	}
+	fmt.Printf("kcv (%s): %s\n", *input, FormatKCV(stored))
+	if !bytes.Equal(kcv, stored) {
+		return &checkFailedError{msg: "key does not match the key check value of the input"}
+	}
+	fmt.Println("Ключ совпадает.")
+	return nil
+}
+
+// storedKCV извлекает KCV из заголовка PEM или двоичного заголовка; nil, если KCV не записан
+func storedKCV(data []byte, armor string) ([]byte, error) {
+	msg, err := cliDearmor(data, armor, false)
+	if err != nil {
+		return nil, err
+	}
+	if kcvHex, ok := msg.Headers[armorHeaderKCV]; ok {
+		return parseKCVHex(kcvHex)
+	}
+	kcv, _, err := ParseKCVHeader(msg.Ciphertext)
+	return kcv, err
+}
+
+// runBenchCommand измеряет скорость шифрования для комбинаций алгоритмов, режимов и размеров
+func runBenchCommand(args []string) error {
+	var workerNames []string
+	for _, w := range DefaultBenchWorkers() {
+		workerNames = append(workerNames, fmt.Sprint(w))
+	}
+
+	fs := newFlagSet("bench")
+	algorithmsFlag := fs.String("algorithm", strings.Join(AlgorithmNames(), ","), "Алгоритмы через запятую")
+	modesFlag := fs.String("mode", strings.Join(BlockModeNames(), ","), "Режимы через запятую")
+	sizesFlag := fs.String("size", "64,4096,65536", "Размеры сообщений в байтах через запятую")
+	workersFlag := fs.String("workers", strings.Join(workerNames, ","), "Числа горутин для параллельных режимов через запятую")
+	benchTime := fs.Duration("benchtime", 200*time.Millisecond, "Минимальная длительность одного измерения")
+	formatFlag := fs.String("format", "table", "Формат результата: table, csv или json")
+	outputFlag := fs.String("output", "-", "Файл для результата или \"-\" для стандартного вывода")
+	forceFlag := fs.Bool("force", false, "Перезаписать существующий файл результата")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	writers := map[string]func(io.Writer, []BenchResult) error{
+		"table": WriteBenchTable,
+		"csv":   WriteBenchCSV,
+		"json":  WriteBenchJSON,
+	}
+	write, ok := writers[*formatFlag]
+	if !ok {
+		return newUsageError("unknown format: %s", *formatFlag)
+	}
+	sizes, err := parseIntList(*sizesFlag)
+	if err != nil {
+		return newUsageError("invalid -size: %w", err)
+	}
+	workers, err := parseIntList(*workersFlag)
+	if err != nil {
+		return newUsageError("invalid -workers: %w", err)
+	}
+	cfg := BenchConfig{
+		Algorithms: splitList(*algorithmsFlag),
+		Modes:      splitList(*modesFlag),
+		Sizes:      sizes,
+		Workers:    workers,
+		Duration:   *benchTime,
+	}
+	for _, name := range cfg.Algorithms {
+		if _, ok := LookupAlgorithm(name); !ok {
+			return newUsageError("unknown algorithm: %s", name)
+		}
+	}
+	for _, name := range cfg.Modes {
+		if _, ok := LookupBlockMode(name); !ok {
+			return newUsageError("unknown mode: %s", name)
+		}
 	}
 
-	// Проверяем длину ключа
-	expectedKeyLength := blockSize // Для DES это 8 байт, для DEAL может быть 16 байт
-	if len(key) != expectedKeyLength {
-		fmt.Printf("Ключ должен быть длиной %d байт (hex string длиной %d символов)\n", expectedKeyLength, expectedKeyLength*2)
-		os.Exit(1)
+	results, err := RunBenchmarks(cfg, func(r BenchResult) {
+		fmt.Fprintf(os.Stderr, "%s/%s/%d/%s: %.2f MB/s\n", r.Algorithm, r.Mode, r.Size, formatBenchWorkers(r.Workers), r.MBPerSec)
+	})
+	if err != nil {
+		return err
 	}
 
-	// Устанавливаем ключ в шифр
-	err = cipher.SetKey(key)
+	if *outputFlag == "-" {
+		return write(os.Stdout, results)
+	}
+	f, err := CreateAtomic(*outputFlag, 0o644, *forceFlag)
 	if err != nil {
-		fmt.Printf("Ошибка при установке ключа: %v\n", err)
-		os.Exit(1)
+		return err
 	}
+	defer f.Abort()
+	if err := write(f, results); err != nil {
+		return err
+	}
+	return f.Commit()
+}
 
-	// Получаем IV, если требуется
-	var iv []byte
-	if cipherMode != ECB {
-		if *ivFlag == "" {
-			fmt.Println("Необходимо указать вектор инициализации (IV) через параметр -iv.")
-			flag.Usage()
-			os.Exit(1)
+// splitList разбивает список через запятую, пропуская пустые элементы
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-		iv, err = hex.DecodeString(*ivFlag)
+	}
+	return items
+}
+
+// parseIntList разбирает список положительных чисел через запятую
+func parseIntList(s string) ([]int, error) {
+	var values []int
+	for _, item := range splitList(s) {
+		v, err := strconv.Atoi(item)
 		if err != nil {
-			fmt.Printf("Неверный формат IV: %v\n", err)
-			os.Exit(1)
+			return nil, err
 		}
+		if v <= 0 {
+			return nil, fmt.Errorf("value must be positive: %d", v)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// selfTestSuites - наборы самопроверки в порядке выполнения
+var selfTestSuites = []struct {
+	name string
+	run  func(verbose bool) error
+}{
+	{"kat", runKnownAnswerSuite},
+	{"desdiff", runDESDiffSuite},
+	{"crosscheck", runCrossCheckSuite},
+	{"conformance", runConformanceSuite},
+}
+
+// runSelfTestCommand выполняет выбранные наборы самопроверки
+func runSelfTestCommand(args []string) error {
+	var names []string
+	for _, s := range selfTestSuites {
+		names = append(names, s.name)
+	}
+
+	fs := newFlagSet("selftest")
+	verbose := fs.Bool("v", false, "Печатать результат каждого вектора")
+	suites := fs.String("suite", strings.Join(names, ","), "Наборы через запятую: "+strings.Join(names, ", "))
+	seed := fs.String("seed", "", "Зерно HMAC_DRBG для случайных ключей и данных проверок; по умолчанию crypto/rand")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	setCheckSeed(*seed)
+
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(*suites, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !containsString(names, name) {
+			return newUsageError("unknown suite: %s", name)
+		}
+		selected[name] = true
+	}
 
-		// Проверяем длину IV
-		if len(iv) != blockSize {
-			fmt.Printf("IV должен быть длиной %d байт (hex string длиной %d символов)\n", blockSize, blockSize*2)
-			os.Exit(1)
+	var failed []string
+	for _, s := range selfTestSuites {
+		if !selected[s.name] {
+			continue
+		}
+		fmt.Printf("== %s\n", s.name)
+		if err := s.run(*verbose); err != nil {
+			fmt.Printf("%s: %v\n", s.name, err)
+			failed = append(failed, s.name)
 		}
 	}
+	if len(failed) > 0 {
+		return &checkFailedError{msg: "self-test failed: " + strings.Join(failed, ", ")}
+	}
+	return nil
+}
+
+// runKnownAnswerSuite проверяет DES и режимы на векторах NIST SP 800-17 и FIPS 81
+func runKnownAnswerSuite(verbose bool) error {
+	if !SelfTestPassed(RunSelfTest(os.Stdout, verbose)) {
+		return errors.New("known-answer tests failed")
+	}
+	return nil
+}
 
-	// Создаем контекст шифрования
-	cryptoContext, err := NewCryptoSymmetricContext(
-		key,
-		cipher,
-		cipherMode,
-		paddingMode,
-		iv,
-		blockSize,
-	)
+// runDESDiffSuite сравнивает DES с crypto/des на случайных ключах и блоках
+func runDESDiffSuite(verbose bool) error {
+	const iterations = 2000
+	// Зерно math/rand берется из checkRandom, поэтому с selftest -seed прогон воспроизводим
+	seedBytes, err := randomBytes(checkRandom, 8)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	seed := int64(binary.BigEndian.Uint64(seedBytes))
+	mismatch, err := DifferentialTestDES(mathrand.New(mathrand.NewSource(seed)), iterations)
+	if err != nil {
+		return err
+	}
+	if mismatch != nil {
+		fmt.Printf("seed: %d\n", seed)
+		mismatch.Write(os.Stdout)
+		return errors.New("DES differs from crypto/des")
 	}
+	fmt.Printf("DES совпадает с crypto/des на %d парах ключ/блок (seed %d)\n", iterations, seed)
+	return nil
+}
 
-	// Выполняем шифрование или дешифрование файла
-	var errChan <-chan error
-	if *encryptFlag {
-		errChan = cryptoContext.EncryptFileAsync(*inputFile, *outputFile)
-	} else {
-		errChan = cryptoContext.DecryptFileAsync(*inputFile, *outputFile)
+// runCrossCheckSuite сверяет режимы CBC, CFB, OFB и CTR с crypto/cipher для каждого алгоритма
+func runCrossCheckSuite(verbose bool) error {
+	failed := false
+	for _, name := range AlgorithmNames() {
+		spec, _ := LookupAlgorithm(name)
+		cipher, err := spec.New()
+		if err != nil {
+			return err
+		}
+		err = CrossCheckStdlibModes(cipher, generateRandomBytes(spec.KeySizes[0]), generateRandomBytes(spec.BlockSize), spec.BlockSize)
+		if err != nil {
+			failed = true
+			fmt.Printf("%s: FAIL: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%s: PASS\n", name)
 	}
-	// Ожидаем завершения операции
-	if err := <-errChan; err != nil {
-		fmt.Printf("Ошибка при обработке файла: %v\n", err)
-		os.Exit(1)
+	if failed {
+		return errors.New("cross-check against crypto/cipher failed")
 	}
+	return nil
+}
 
-	if *encryptFlag {
-		fmt.Println("Шифрование завершено успешно.")
-	} else {
-		fmt.Println("Дешифрование завершено успешно.")
+// conformanceLog собирает нарушения, найденные проверками на соответствие
+type conformanceLog struct {
+	failures []string
+}
+
+func (l *conformanceLog) Errorf(format string, args ...any) {
+	l.failures = append(l.failures, fmt.Sprintf(format, args...))
+}
+
+// runConformanceSuite прогоняет проверки на соответствие для алгоритмов, расписаний ключей,
+// раундовых функций и набивок из реестров; те же проверки выполняет TestConformance
+func runConformanceSuite(verbose bool) error {
+	checks := ConformanceChecks()
+	failed := false
+	for _, c := range checks {
+		log := &conformanceLog{}
+		c.Run(log)
+		if len(log.failures) == 0 {
+			fmt.Printf("%s: PASS\n", c.Name)
+			continue
+		}
+		failed = true
+		fmt.Printf("%s: FAIL\n", c.Name)
+		for _, f := range log.failures {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+	if failed {
+		return errors.New("conformance checks failed")
+	}
+	return nil
+}
+
+// inspectTopics - разделы команды inspect
+var inspectTopics = []struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}{
+	{"key", "классы ключа для DES, 3DES и раундовых ключей DEAL (то же, что keycheck)", func(args []string) error { return runKeyCheck("inspect key", args) }},
+	{"errorprop", "таблица распространения ошибок по режимам", runInspectErrorPropagation},
+	{"paddingoracle", "демонстрация атаки оракула набивки на CBC", func(args []string) error {
+		if err := parseFlags(newFlagSet("inspect paddingoracle"), args); err != nil {
+			return err
+		}
+		return RunPaddingOracleDemo(os.Stdout)
+	}},
+	{"registry", "зарегистрированные алгоритмы, режимы и набивки", runInspectRegistry},
+}
+
+func runInspectCommand(args []string) error {
+	if len(args) == 0 {
+		var lines []string
+		for _, t := range inspectTopics {
+			lines = append(lines, fmt.Sprintf("  %-14s %s", t.name, t.summary))
+		}
+		return newUsageError("inspect requires a topic:\n%s", strings.Join(lines, "\n"))
+	}
+	for _, t := range inspectTopics {
+		if t.name == args[0] {
+			return t.run(args[1:])
+		}
+	}
+	return newUsageError("unknown inspect topic: %s", args[0])
+}
+
+// runKeyCheck выполняет keycheck и inspect key: проверяет ключ на слабость или перечисляет ключи класса
+func runKeyCheck(name string, args []string) error {
+	fs := newFlagSet(name)
+	keyHex := fs.String("key", "", "Ключ в шестнадцатеричном формате: 8 байт (DES), 16/24 (3DES и DEAL) или 32 (DEAL)")
+	listClass := fs.String("list", "", "Вывести все ключи класса: weak, semi-weak или possibly-weak")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *listClass != "" {
+		for _, class := range []DESKeyClass{DESKeyWeak, DESKeySemiWeak, DESKeyPossiblyWeak} {
+			if strings.ReplaceAll(class.String(), " ", "-") == *listClass {
+				for _, key := range DESKeysOfClass(class) {
+					fmt.Printf("%x\n", key)
+				}
+				return nil
+			}
+		}
+		return newUsageError("unknown key class %q", *listClass)
+	}
+
+	if *keyHex == "" {
+		return newUsageError("-key or -list is required")
+	}
+	key, err := hex.DecodeString(*keyHex)
+	if err != nil {
+		return newUsageError("invalid key: %w", err)
+	}
+	reports, warnings, err := CheckKeyForAlgorithms(key)
+	if err != nil {
+		return newUsageError("%w", err)
+	}
+	if WriteDESKeyReports(os.Stdout, reports, warnings) {
+		return &checkFailedError{msg: "key is weak for at least one algorithm"}
+	}
+	return nil
+}
+
+// runInspectErrorPropagation печатает таблицу распространения ошибок для всех режимов
+func runInspectErrorPropagation(args []string) error {
+	fs := newFlagSet("inspect errorprop")
+	algorithmFlag := fs.String("algorithm", "DES", "Алгоритм: "+strings.Join(AlgorithmNames(), ", "))
+	blocksFlag := fs.Int("blocks", 6, "Длина сообщения в блоках")
+	targetFlag := fs.Int("target", 2, "Номер искажаемого блока")
+	bitFlag := fs.Int("bit", 3, "Номер инвертируемого бита в искажаемом блоке (0 - старший бит первого байта)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	spec, ok := LookupAlgorithm(*algorithmFlag)
+	if !ok {
+		return newUsageError("unknown algorithm: %s", *algorithmFlag)
+	}
+	cipher, err := spec.New()
+	if err != nil {
+		return err
+	}
+
+	var modes []CipherMode
+	for _, name := range BlockModeNames() {
+		mode, _ := LookupBlockMode(name)
+		modes = append(modes, mode)
+	}
+
+	results, err := AnalyzeErrorPropagation(PropagationConfig{
+		Key:         generateRandomBytes(spec.KeySizes[0]),
+		Cipher:      cipher,
+		IV:          generateRandomBytes(spec.BlockSize),
+		BlockSize:   spec.BlockSize,
+		Blocks:      *blocksFlag,
+		TargetBlock: *targetFlag,
+		Bit:         *bitFlag,
+	}, modes)
+	if err != nil {
+		return newUsageError("%w", err)
+	}
+	WritePropagationTable(os.Stdout, results)
+	fmt.Println()
+	WritePropagationBits(os.Stdout, results)
+	return nil
+}
+
+// runInspectRegistry печатает содержимое реестров
+func runInspectRegistry(args []string) error {
+	if err := parseFlags(newFlagSet("inspect registry"), args); err != nil {
+		return err
+	}
+	fmt.Println("Алгоритмы:")
+	for _, name := range AlgorithmNames() {
+		spec, _ := LookupAlgorithm(name)
+		fmt.Printf("  %-6s блок %2d байт, ключ %v байт\n", spec.Name, spec.BlockSize, spec.KeySizes)
+	}
+	fmt.Println("Режимы:")
+	for _, name := range BlockModeNames() {
+		mode, _ := LookupBlockMode(name)
+		bm, _ := mode.BlockMode()
+		fmt.Printf("  %-12s IV %2d байт при блоке 8, набивка обязательна: %-5v параллельный: %v\n",
+			name, bm.IVSize(8), bm.NeedsPadding(), bm.Parallelizable())
+	}
+	fmt.Printf("Набивки:\n  %s\n", paddingListing())
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
 	}
+	return false
 }
 
 func generateRandomBytes(size int) []byte {
-	data := make([]byte, size)
-	_, err := rand.Read(data)
+	data, err := randomBytes(checkRandom, size)
 	if err != nil {
 		panic("Не удалось сгенерировать случайные данные")
 	}
@@ -5,6 +5,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
 )
 
 // DEAL структура, представляющая алгоритм DEAL
@@ -28,25 +30,49 @@ func NewDEAL() (*DEAL, error) {
 	return deal, nil
 }
 
+// Name возвращает имя алгоритма
+func (deal *DEAL) Name() string { return "DEAL" }
+
+// BlockSize возвращает размер блока DEAL - 16 байт
+func (deal *DEAL) BlockSize() int { return deal.blockSize }
+
+// KeySizes возвращает длины ключа DEAL: 128, 192 и 256 бит
+func (deal *DEAL) KeySizes() []int { return []int{16, 24, 32} }
+
 // SetKey устанавливает ключ для алгоритма DEAL
 func (deal *DEAL) SetKey(key []byte) error {
 	return deal.feistel.SetKey(key)
 }
 
+// NewKeyed возвращает отдельный экземпляр DEAL с ключом key; сам deal не изменяется
+func (deal *DEAL) NewKeyed(key []byte) (BlockCipher, error) {
+	keyed, err := NewDEAL()
+	if err != nil {
+		return nil, err
+	}
+	// Копия ключа: экземпляр живет в кэше расписаний и не должен зависеть от среза вызывающей стороны
+	return newKeyedBlock(keyed, append([]byte(nil), key...))
+}
+
 // Encrypt шифрует блок данных
 func (deal *DEAL) Encrypt(block []byte) ([]byte, error) {
+	if len(block) != deal.blockSize {
+		return nil, blockSizeError(deal.blockSize, len(block))
+	}
 	return deal.feistel.Encrypt(block)
 }
 
 // Decrypt дешифрует блок данных
 func (deal *DEAL) Decrypt(block []byte) ([]byte, error) {
+	if len(block) != deal.blockSize {
+		return nil, blockSizeError(deal.blockSize, len(block))
+	}
 	return deal.feistel.Decrypt(block)
 }
 
 // DEALKeySchedule реализует интерфейс KeyRound для DEAL
 type DEALKeySchedule struct{}
 
-// GenerateKeys генерирует раундовые ключи для DEAL
 // GenerateKeys генерирует раундовые ключи для DEAL
 func (ks *DEALKeySchedule) GenerateKeys(inputKey []byte) ([][]byte, error) {
 	keyLength := len(inputKey)
@@ -61,7 +87,7 @@ func (ks *DEALKeySchedule) GenerateKeys(inputKey []byte) ([][]byte, error) {
 	case 32:
 		numRounds = 12
 	default:
-		return nil, errors.New("key must be 16, 24, or 32 bytes (128, 192, or 256 bits)")
+		return nil, &KeySizeError{Algorithm: "DEAL", Size: len(inputKey), Valid: []int{16, 24, 32}}
 	}
 
 	// Генерируем раундовые ключи
@@ -75,61 +101,98 @@ func (ks *DEALKeySchedule) GenerateKeys(inputKey []byte) ([][]byte, error) {
 			end = keyLength
 		}
 
-		// Берём часть ключа и дополняем, если она меньше 8 байт
-		part := inputKey[start:end]
-		if len(part) < 8 {
-			padding := make([]byte, 8-len(part))
-			part = append(part, padding...)
-		}
+		// Копируем часть ключа в новый срез; если она меньше 8 байт, остаток заполняется нулями.
+		// Раундовые ключи не должны ссылаться на inputKey, иначе его изменение поменяет шифр.
+		part := make([]byte, 8)
+		copy(part, inputKey[start:end])
 
 		roundKeys[i] = part
 
-		// Отладочный вывод
-		fmt.Printf("Round %d Key: %x (Length: %d bytes)\n", i+1, part, len(part))
+		// Раундовый ключ - секрет: в журнал он попадает только при SetLogSecrets(true)
+		logDebug(nil, "DEAL round key", func() []slog.Attr {
+			return []slog.Attr{slog.Int("round", i+1), secretAttr("key", part)}
+		})
 	}
 
 	return roundKeys, nil
 }
 
-// DEALRoundFunction реализует интерфейс CipherTransform для DEAL
+// DEALRoundFunction реализует интерфейс CipherTransform для DEAL.
+// Для каждого раундового ключа заранее создается свой экземпляр DES с готовым расписанием ключей,
+// после чего экземпляры только читаются, поэтому раундовую функцию можно вызывать из разных горутин.
 type DEALRoundFunction struct {
-	des *DES
+	mu        sync.RWMutex
+	schedules map[string]*DES
 }
 
-// NewDEALRoundFunction создает новый адаптер DES для DEAL
+// NewDEALRoundFunction создает адаптер DES для DEAL
 func NewDEALRoundFunction() *DEALRoundFunction {
-	des, _ := NewDES()
-	return &DEALRoundFunction{des: des}
+	return &DEALRoundFunction{}
 }
 
-func (rf *DEALRoundFunction) Encryption(inputBlock, roundKey []byte) ([]byte, error) {
+// PrepareRoundKeys вызывается FeistelNetwork.SetKey и строит расписания DES для всех раундов
+func (rf *DEALRoundFunction) PrepareRoundKeys(roundKeys [][]byte) error {
+	schedules := make(map[string]*DES, len(roundKeys))
+	for _, roundKey := range roundKeys {
+		if _, ok := schedules[string(roundKey)]; ok {
+			continue
+		}
+		des, err := newKeyedDES(roundKey)
+		if err != nil {
+			return err
+		}
+		schedules[string(roundKey)] = des
+	}
+
+	rf.mu.Lock()
+	rf.schedules = schedules
+	rf.mu.Unlock()
+	return nil
+}
+
+// desFor возвращает DES для раундового ключа из набора, подготовленного PrepareRoundKeys
+func (rf *DEALRoundFunction) desFor(roundKey []byte) (*DES, error) {
 	if len(roundKey) != 8 {
-		return nil, errors.New("round key must be 8 bytes (64 bits)")
+		return nil, fmt.Errorf("%w: DEAL round key must be 8 bytes, got %d", ErrInvalidKeySize, len(roundKey))
+	}
+	rf.mu.RLock()
+	des, ok := rf.schedules[string(roundKey)]
+	rf.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("DEAL round key was not prepared by PrepareRoundKeys")
+	}
+	return des, nil
+}
+
+func newKeyedDES(key []byte) (*DES, error) {
+	des, err := NewDES()
+	if err != nil {
+		return nil, err
+	}
+	if err := des.SetKey(key); err != nil {
+		return nil, err
 	}
+	return des, nil
+}
 
-	// Устанавливаем раундовый ключ для DES
-	err := rf.des.SetKey(roundKey)
+func (rf *DEALRoundFunction) Encryption(inputBlock, roundKey []byte) ([]byte, error) {
+	des, err := rf.desFor(roundKey)
 	if err != nil {
 		return nil, err
 	}
 
 	// Шифруем входной блок с помощью DES
-	return rf.des.Encrypt(inputBlock)
+	return des.Encrypt(inputBlock)
 }
 
 func (rf *DEALRoundFunction) Decryption(inputBlock, roundKey []byte) ([]byte, error) {
-	if len(roundKey) != 8 {
-		return nil, errors.New("round key must be 8 bytes (64 bits)")
-	}
-
-	// Устанавливаем раундовый ключ для DES
-	err := rf.des.SetKey(roundKey)
+	des, err := rf.desFor(roundKey)
 	if err != nil {
 		return nil, err
 	}
 
 	// Дешифруем входной блок с помощью DES
-	return rf.des.Decrypt(inputBlock)
+	return des.Decrypt(inputBlock)
 }
 
 // EncryptAsync выполняет асинхронное шифрование данных
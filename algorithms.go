@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Реестр алгоритмов: по имени из него создаются экземпляры для CLI,
+// генерации ключей и проверок на соответствие
+
+// AlgorithmSpec описывает алгоритм
+type AlgorithmSpec struct {
+	Name string
+	// New создает новый экземпляр без ключа
+	New func() (SymmetricAlgorithm, error)
+	// KeySizes и BlockSize можно не задавать: RegisterAlgorithm берет их у экземпляра из New
+	KeySizes  []int
+	BlockSize int
+	// DESKeys - ключ состоит из ключей DES: при генерации выставляется четность
+	// и исключаются слабые ключи
+	DESKeys bool
+}
+
+var (
+	algorithmRegistryMu sync.RWMutex
+	algorithmRegistry   []AlgorithmSpec
+)
+
+func init() {
+	builtin := []AlgorithmSpec{
+		{Name: "DES", New: func() (SymmetricAlgorithm, error) { return NewDES() }, DESKeys: true},
+		{Name: "DEAL", New: func() (SymmetricAlgorithm, error) { return NewDEAL() }, DESKeys: true},
+		{Name: "AES", New: func() (SymmetricAlgorithm, error) { return NewAES() }},
+	}
+	for _, spec := range builtin {
+		if err := RegisterAlgorithm(spec); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterAlgorithm добавляет алгоритм в реестр
+func RegisterAlgorithm(spec AlgorithmSpec) error {
+	if spec.Name == "" {
+		return errors.New("algorithm name is empty")
+	}
+	if spec.New == nil {
+		return fmt.Errorf("algorithm %q must have a constructor", spec.Name)
+	}
+	if err := spec.fillSizes(); err != nil {
+		return err
+	}
+
+	algorithmRegistryMu.Lock()
+	defer algorithmRegistryMu.Unlock()
+
+	for _, existing := range algorithmRegistry {
+		if existing.Name == spec.Name {
+			return fmt.Errorf("algorithm %q is already registered", spec.Name)
+		}
+	}
+	spec.KeySizes = append([]int(nil), spec.KeySizes...)
+	algorithmRegistry = append(algorithmRegistry, spec)
+	return nil
+}
+
+// fillSizes заполняет размеры блока и ключа из экземпляра алгоритма;
+// заданные в spec явно размеры должны совпадать с тем, что сообщает экземпляр
+func (spec *AlgorithmSpec) fillSizes() error {
+	alg, err := spec.New()
+	if err != nil {
+		return fmt.Errorf("algorithm %q: %w", spec.Name, err)
+	}
+	blockSize, keySizes := alg.BlockSize(), alg.KeySizes()
+	if blockSize <= 0 || len(keySizes) == 0 {
+		return fmt.Errorf("algorithm %q must report key sizes and a positive block size", spec.Name)
+	}
+	if spec.BlockSize != 0 && spec.BlockSize != blockSize {
+		return fmt.Errorf("algorithm %q: block size %d does not match the reported %d", spec.Name, spec.BlockSize, blockSize)
+	}
+	if len(spec.KeySizes) != 0 && !equalInts(spec.KeySizes, keySizes) {
+		return fmt.Errorf("algorithm %q: key sizes %v do not match the reported %v", spec.Name, spec.KeySizes, keySizes)
+	}
+	spec.BlockSize, spec.KeySizes = blockSize, keySizes
+	return nil
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LookupAlgorithm ищет алгоритм по имени
+func LookupAlgorithm(name string) (AlgorithmSpec, bool) {
+	algorithmRegistryMu.RLock()
+	defer algorithmRegistryMu.RUnlock()
+	for _, spec := range algorithmRegistry {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return AlgorithmSpec{}, false
+}
+
+// AlgorithmNames возвращает имена всех зарегистрированных алгоритмов в порядке регистрации
+func AlgorithmNames() []string {
+	algorithmRegistryMu.RLock()
+	defer algorithmRegistryMu.RUnlock()
+	names := make([]string, len(algorithmRegistry))
+	for i, spec := range algorithmRegistry {
+		names[i] = spec.Name
+	}
+	return names
+}
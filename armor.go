@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Текстовая обертка (armor) шифротекста: Base64, hex или блок в стиле PEM
+// с заголовками алгоритма, режима, набивки и IV. Удобна для коротких секретов
+// в конфигурационных файлах.
+
+// ArmorFormat - формат обертки
+type ArmorFormat int
+
+const (
+	ArmorRaw ArmorFormat = iota
+	ArmorBase64
+	ArmorHex
+	ArmorPEM
+)
+
+// armorPEMType - тип блока PEM
+const armorPEMType = "CRYPTOLAB MESSAGE"
+
+// Заголовки блока PEM
+const (
+	armorHeaderAlgorithm = "Algorithm"
+	armorHeaderMode      = "Mode"
+	armorHeaderPadding   = "Padding"
+	armorHeaderIV        = "IV"
+)
+
+var armorFormatNames = []string{"raw", "base64", "hex", "pem"}
+
+func (f ArmorFormat) String() string {
+	if f < 0 || int(f) >= len(armorFormatNames) {
+		return fmt.Sprintf("ArmorFormat(%d)", int(f))
+	}
+	return armorFormatNames[f]
+}
+
+// ParseArmorFormat ищет формат по имени: raw, base64, hex или pem
+func ParseArmorFormat(name string) (ArmorFormat, error) {
+	for i, n := range armorFormatNames {
+		if strings.EqualFold(n, name) {
+			return ArmorFormat(i), nil
+		}
+	}
+	return ArmorRaw, fmt.Errorf("unknown armor format %q (supported: %s)", name, strings.Join(armorFormatNames, ", "))
+}
+
+// ArmoredMessage - разобранное сообщение; Headers заполняются только для PEM
+type ArmoredMessage struct {
+	Format     ArmorFormat
+	Headers    map[string]string
+	Ciphertext []byte
+}
+
+// Armor оборачивает шифротекст; заголовки используются только в формате PEM.
+// Текстовые форматы завершаются переводом строки.
+func Armor(ciphertext []byte, format ArmorFormat, headers map[string]string) ([]byte, error) {
+	switch format {
+	case ArmorRaw:
+		return append([]byte(nil), ciphertext...), nil
+	case ArmorBase64:
+		return []byte(base64.StdEncoding.EncodeToString(ciphertext) + "\n"), nil
+	case ArmorHex:
+		return []byte(hex.EncodeToString(ciphertext) + "\n"), nil
+	case ArmorPEM:
+		return pem.EncodeToMemory(&pem.Block{Type: armorPEMType, Headers: headers, Bytes: ciphertext}), nil
+	default:
+		return nil, fmt.Errorf("unsupported armor format: %s", format)
+	}
+}
+
+// Dearmor снимает обертку PEM, если данные начинаются с блока PEM; все остальное
+// возвращается как сырой шифротекст. Hex и Base64 не распознаются: двоичный шифротекст
+// может случайно состоять только из таких символов, поэтому эти форматы задаются явно
+// через DearmorAs.
+func Dearmor(data []byte) (*ArmoredMessage, error) {
+	if text := bytes.TrimSpace(data); bytes.HasPrefix(text, []byte("-----BEGIN ")) {
+		return dearmorPEM(text)
+	}
+	return &ArmoredMessage{Format: ArmorRaw, Ciphertext: data}, nil
+}
+
+// DearmorAs снимает обертку заданного формата; данные в другом формате - ошибка
+func DearmorAs(data []byte, format ArmorFormat) (*ArmoredMessage, error) {
+	text := bytes.TrimSpace(data)
+	switch format {
+	case ArmorRaw:
+		return &ArmoredMessage{Format: ArmorRaw, Ciphertext: data}, nil
+	case ArmorBase64:
+		ciphertext, err := base64.StdEncoding.DecodeString(string(removeWhitespace(text)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 armor: %w", err)
+		}
+		return &ArmoredMessage{Format: ArmorBase64, Ciphertext: ciphertext}, nil
+	case ArmorHex:
+		if !isHexText(removeWhitespace(text)) {
+			return nil, errors.New("invalid hex armor: unexpected non-hex characters")
+		}
+		ciphertext, err := hex.DecodeString(string(removeWhitespace(text)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex armor: %w", err)
+		}
+		return &ArmoredMessage{Format: ArmorHex, Ciphertext: ciphertext}, nil
+	case ArmorPEM:
+		if !bytes.HasPrefix(text, []byte("-----BEGIN ")) {
+			return nil, errors.New("input is not PEM armor")
+		}
+		return dearmorPEM(text)
+	default:
+		return nil, fmt.Errorf("unsupported armor format: %s", format)
+	}
+}
+
+// dearmorPEM разбирает единственный блок PEM с типом armorPEMType
+func dearmorPEM(text []byte) (*ArmoredMessage, error) {
+	block, rest := pem.Decode(text)
+	if block == nil {
+		return nil, errors.New("malformed PEM armor")
+	}
+	if block.Type != armorPEMType {
+		return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return nil, errors.New("unexpected data after PEM armor")
+	}
+	return &ArmoredMessage{Format: ArmorPEM, Headers: block.Headers, Ciphertext: block.Bytes}, nil
+}
+
+func isHexText(text []byte) bool {
+	for _, c := range text {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func removeWhitespace(text []byte) []byte {
+	return bytes.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// armorHeaders - заголовки PEM для контекста; IV добавляется, только если режим его использует
+func (cstc *CryptoSymmetricContext) armorHeaders() (map[string]string, error) {
+	headers := map[string]string{
+		armorHeaderMode:    cstc.mode.String(),
+		armorHeaderPadding: cstc.padding.String(),
+	}
+	if named, ok := cstc.cipher.(interface{ Name() string }); ok && named.Name() != "" {
+		headers[armorHeaderAlgorithm] = named.Name()
+	}
+	ivSize, err := cstc.ivSize()
+	if err != nil {
+		return nil, err
+	}
+	if ivSize > 0 {
+		headers[armorHeaderIV] = hex.EncodeToString(cstc.iv)
+	}
+	kcv, err := cstc.KCV()
+	if err != nil {
+		return nil, err
+	}
+	headers[armorHeaderKCV] = FormatKCV(kcv)
+	return headers, nil
+}
+
+// EncryptArmored шифрует данные и оборачивает шифротекст в выбранный формат.
+// В формате PEM алгоритм, режим, набивка, IV и KCV записываются в заголовки,
+// в остальных форматах KCV записывается в двоичном заголовке перед шифротекстом.
+func (cstc *CryptoSymmetricContext) EncryptArmored(data []byte, format ArmorFormat) ([]byte, error) {
+	ciphertext, err := cstc.Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	var headers map[string]string
+	if format == ArmorPEM {
+		headers, err = cstc.armorHeaders()
+	} else {
+		ciphertext, err = cstc.withKCVHeader(ciphertext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Armor(ciphertext, format, headers)
+}
+
+// DecryptArmored снимает обертку PEM (см. Dearmor), после чего дешифрует; данные без PEM
+// считаются сырым шифротекстом. Если в заголовках PEM указан IV, используется он;
+// алгоритм, режим и набивка из заголовков должны совпадать с настройками контекста.
+func (cstc *CryptoSymmetricContext) DecryptArmored(data []byte) ([]byte, error) {
+	msg, err := Dearmor(data)
+	if err != nil {
+		return nil, err
+	}
+	return cstc.decryptArmoredMessage(msg)
+}
+
+// DecryptArmoredAs снимает обертку заданного формата и дешифрует
+func (cstc *CryptoSymmetricContext) DecryptArmoredAs(data []byte, format ArmorFormat) ([]byte, error) {
+	msg, err := DearmorAs(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return cstc.decryptArmoredMessage(msg)
+}
+
+func (cstc *CryptoSymmetricContext) decryptArmoredMessage(msg *ArmoredMessage) ([]byte, error) {
+	ctx, err := cstc.forArmoredMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := ctx.messageCiphertext(msg)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.Decrypt(ciphertext)
+}
+
+// messageCiphertext проверяет KCV сообщения и возвращает шифротекст для дешифрования.
+// В PEM KCV записывается в заголовке блока, в остальных форматах - в двоичном заголовке
+// перед шифротекстом; сообщение без KCV принимается, только если контекст это разрешает.
+func (cstc *CryptoSymmetricContext) messageCiphertext(msg *ArmoredMessage) ([]byte, error) {
+	kcvHex, ok := msg.Headers[armorHeaderKCV]
+	if msg.Format != ArmorPEM || !ok {
+		return cstc.stripKCVHeader(msg.Ciphertext)
+	}
+	kcv, err := parseKCVHex(kcvHex)
+	if err != nil {
+		return nil, err
+	}
+	if err := cstc.verifyKCV(kcv); err != nil {
+		return nil, err
+	}
+	return msg.Ciphertext, nil
+}
+
+// forArmoredMessage сверяет заголовки с контекстом и возвращает контекст с IV из заголовка;
+// KCV проверяет messageCiphertext
+func (cstc *CryptoSymmetricContext) forArmoredMessage(msg *ArmoredMessage) (*CryptoSymmetricContext, error) {
+	if msg.Format != ArmorPEM {
+		return cstc, nil
+	}
+	expected, err := cstc.armorHeaders()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{armorHeaderAlgorithm, armorHeaderMode, armorHeaderPadding} {
+		got, ok := msg.Headers[name]
+		if ok && expected[name] != "" && got != expected[name] {
+			return nil, fmt.Errorf("armor header %s is %q, context uses %q", name, got, expected[name])
+		}
+	}
+	ivHex, ok := msg.Headers[armorHeaderIV]
+	if !ok {
+		return cstc, nil
+	}
+	iv, err := hex.DecodeString(ivHex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: IV header: %v", ErrInvalidIV, err)
+	}
+	ivSize, err := cstc.ivSize()
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != ivSize {
+		return nil, fmt.Errorf("%w: IV header must be %d bytes, got %d", ErrInvalidIV, ivSize, len(iv))
+	}
+	return cstc.withIV(iv), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestDearmorKeepsTextLikeCiphertextRaw: сырой шифротекст, который случайно состоит
+// из символов hex или Base64, не должен декодироваться
+func TestDearmorKeepsTextLikeCiphertextRaw(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("0123456789abcdef"),
+		[]byte("QUJDREVGR0g="),
+		[]byte("AAAAAAAAAAAAAAAA"),
+	} {
+		msg, err := Dearmor(data)
+		if err != nil {
+			t.Fatalf("Dearmor(%q): %v", data, err)
+		}
+		if msg.Format != ArmorRaw || !bytes.Equal(msg.Ciphertext, data) {
+			t.Errorf("Dearmor(%q) = %s %x, want raw input", data, msg.Format, msg.Ciphertext)
+		}
+	}
+}
+
+func TestArmorRoundTrip(t *testing.T) {
+	ciphertext := []byte("0123456789abcdef\x00\xff")
+	for _, format := range []ArmorFormat{ArmorRaw, ArmorBase64, ArmorHex, ArmorPEM} {
+		armored, err := Armor(ciphertext, format, map[string]string{armorHeaderMode: "CBC"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := DearmorAs(armored, format)
+		if err != nil {
+			t.Fatalf("DearmorAs(%s): %v", format, err)
+		}
+		if msg.Format != format || !bytes.Equal(msg.Ciphertext, ciphertext) {
+			t.Errorf("DearmorAs(%s) = %s %x, want %x", format, msg.Format, msg.Ciphertext, ciphertext)
+		}
+	}
+
+	// Dearmor распознает без подсказки только PEM
+	armored, _ := Armor(ciphertext, ArmorPEM, nil)
+	if msg, err := Dearmor(armored); err != nil || msg.Format != ArmorPEM || !bytes.Equal(msg.Ciphertext, ciphertext) {
+		t.Errorf("Dearmor(PEM) = %+v, %v", msg, err)
+	}
+}
+
+func TestDearmorAsRejectsOtherFormats(t *testing.T) {
+	pemData, _ := Armor([]byte("secret"), ArmorPEM, nil)
+	tests := []struct {
+		format ArmorFormat
+		data   []byte
+	}{
+		{ArmorHex, []byte("xyz0")},
+		{ArmorHex, []byte("abc")},
+		{ArmorHex, pemData},
+		{ArmorBase64, []byte("not base64!")},
+		{ArmorPEM, []byte("0123456789abcdef")},
+	}
+	for _, tt := range tests {
+		if msg, err := DearmorAs(tt.data, tt.format); err == nil {
+			t.Errorf("DearmorAs(%q, %s) = %+v, want error", tt.data, tt.format, msg)
+		}
+	}
+}
+
+func TestDecryptArmoredAs(t *testing.T) {
+	key := mustDecodeHex("133457799bbcdff1")
+	iv := mustDecodeHex("0001020304050607")
+	alg, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext(key, alg, WithIV(iv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("armored message")
+	for _, format := range []ArmorFormat{ArmorRaw, ArmorBase64, ArmorHex, ArmorPEM} {
+		armored, err := ctx.EncryptArmored(plaintext, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ctx.DecryptArmoredAs(armored, format)
+		if err != nil {
+			t.Fatalf("%s: DecryptArmoredAs: %v", format, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%s: DecryptArmoredAs = %q, want %q", format, got, plaintext)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Атомарная запись результата: данные пишутся во временный файл в том же каталоге,
+// сбрасываются на диск и только после успешного завершения переименовываются
+// в итоговый файл. При ошибке (неверный ключ, испорченная набивка) или прерывании
+// частично записанный файл удаляется, а существующий файл остается нетронутым.
+
+// ErrOutputExists - итоговый файл уже существует, а перезапись не разрешена
+var ErrOutputExists = errors.New("output file already exists")
+
+// pendingOutputs - незавершенные временные файлы; CLI удаляет их при получении сигнала
+var pendingOutputs = struct {
+	sync.Mutex
+	paths map[string]struct{}
+}{paths: make(map[string]struct{})}
+
+// AtomicFile - временный файл, который становится итоговым только после Commit
+type AtomicFile struct {
+	*os.File
+	path      string
+	perm      fs.FileMode
+	overwrite bool
+	done      bool
+}
+
+// CreateAtomic создает временный файл рядом с path. Без overwrite существующий
+// path считается ошибкой ErrOutputExists; проверка повторяется и при Commit.
+func CreateAtomic(path string, perm fs.FileMode, overwrite bool) (*AtomicFile, error) {
+	if !overwrite {
+		if _, err := os.Lstat(path); err == nil {
+			return nil, fmt.Errorf("%s: %w", path, ErrOutputExists)
+		}
+	}
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	pendingOutputs.Lock()
+	pendingOutputs.paths[f.Name()] = struct{}{}
+	pendingOutputs.Unlock()
+	return &AtomicFile{File: f, path: path, perm: perm, overwrite: overwrite}, nil
+}
+
+// Commit сбрасывает данные на диск, выставляет права и переименовывает временный файл в итоговый
+func (af *AtomicFile) Commit() error {
+	if af.done {
+		return errors.New("atomic file already committed or aborted")
+	}
+	tmp := af.File.Name()
+	err := af.File.Sync()
+	if err == nil {
+		// Права выставляются явно: os.CreateTemp создает файл с 0600
+		err = af.File.Chmod(af.perm)
+	}
+	if closeErr := af.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = af.publish(tmp)
+	}
+	af.done = true
+	forgetPendingOutput(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(af.path))
+	return nil
+}
+
+// publish переносит временный файл на место итогового. Без перезаписи используется
+// жесткая ссылка: в отличие от rename она не заменяет файл, появившийся после CreateAtomic.
+func (af *AtomicFile) publish(tmp string) error {
+	if af.overwrite {
+		return os.Rename(tmp, af.path)
+	}
+	err := os.Link(tmp, af.path)
+	switch {
+	case err == nil:
+		return os.Remove(tmp)
+	case errors.Is(err, fs.ErrExist):
+		return fmt.Errorf("%s: %w", af.path, ErrOutputExists)
+	}
+	// Файловая система без жестких ссылок: проверка и rename не атомарны, но лучше, чем ничего
+	if _, statErr := os.Lstat(af.path); statErr == nil {
+		return fmt.Errorf("%s: %w", af.path, ErrOutputExists)
+	}
+	return os.Rename(tmp, af.path)
+}
+
+// Abort закрывает и удаляет временный файл; после Commit ничего не делает,
+// поэтому его удобно вызывать через defer сразу после CreateAtomic
+func (af *AtomicFile) Abort() error {
+	if af.done {
+		return nil
+	}
+	af.done = true
+	tmp := af.File.Name()
+	af.File.Close()
+	forgetPendingOutput(tmp)
+	return os.Remove(tmp)
+}
+
+// WriteFileAtomic - атомарный аналог os.WriteFile
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode, overwrite bool) error {
+	af, err := CreateAtomic(path, perm, overwrite)
+	if err != nil {
+		return err
+	}
+	defer af.Abort()
+	if _, err := af.Write(data); err != nil {
+		return err
+	}
+	return af.Commit()
+}
+
+// RemovePendingOutputs удаляет все незавершенные временные файлы.
+// Вызывается при прерывании процесса, когда отложенные Abort уже не выполнятся.
+func RemovePendingOutputs() {
+	pendingOutputs.Lock()
+	defer pendingOutputs.Unlock()
+	for path := range pendingOutputs.paths {
+		os.Remove(path)
+		delete(pendingOutputs.paths, path)
+	}
+}
+
+func forgetPendingOutput(path string) {
+	pendingOutputs.Lock()
+	delete(pendingOutputs.paths, path)
+	pendingOutputs.Unlock()
+}
+
+// syncDir сбрасывает на диск запись каталога, чтобы переименование пережило сбой питания.
+// Не все системы позволяют открыть каталог для fsync, поэтому ошибки игнорируются.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dirNames возвращает имена файлов каталога, включая временные
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func pendingOutputCount() int {
+	pendingOutputs.Lock()
+	defer pendingOutputs.Unlock()
+	return len(pendingOutputs.paths)
+}
+
+func TestCreateAtomicRefusesExisting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out")
+	if err := os.WriteFile(path, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CreateAtomic(path, 0o644, false); !errors.Is(err, ErrOutputExists) {
+		t.Fatalf("CreateAtomic over an existing file: error = %v, want ErrOutputExists", err)
+	}
+	if err := WriteFileAtomic(path, []byte("replacement"), 0o644, false); !errors.Is(err, ErrOutputExists) {
+		t.Fatalf("WriteFileAtomic over an existing file: error = %v, want ErrOutputExists", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "original" {
+		t.Errorf("existing file changed to %q", data)
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("directory holds %q, want only the original file", names)
+	}
+}
+
+// TestCommitRefusesFileCreatedLater: файл, появившийся между CreateAtomic и Commit,
+// без перезаписи не заменяется, а временный файл удаляется
+func TestCommitRefusesFileCreatedLater(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out")
+	af, err := CreateAtomic(path, 0o644, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer af.Abort()
+	if _, err := af.Write([]byte("ours")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("theirs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := af.Commit(); !errors.Is(err, ErrOutputExists) {
+		t.Fatalf("Commit: error = %v, want ErrOutputExists", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "theirs" {
+		t.Errorf("file created after CreateAtomic was replaced with %q", data)
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("directory holds %q after a failed Commit", names)
+	}
+}
+
+func TestCommitOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out")
+	if err := os.WriteFile(path, []byte("original content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	af, err := CreateAtomic(path, 0o640, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer af.Abort()
+	if _, err := af.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	// До Commit итоговый файл не меняется
+	if data, _ := os.ReadFile(path); string(data) != "original content" {
+		t.Errorf("file changed before Commit: %q", data)
+	}
+	if err := af.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("file after Commit = %q, want %q", data, "new")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("mode after Commit = %v, want 0640 (%v)", info.Mode().Perm(), err)
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("directory holds %q, want only the output", names)
+	}
+
+	// Повторный Commit - ошибка, Abort после Commit ничего не удаляет
+	if err := af.Commit(); err == nil {
+		t.Error("second Commit succeeded")
+	}
+	if err := af.Abort(); err != nil {
+		t.Errorf("Abort after Commit: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Abort after Commit removed the output: %v", err)
+	}
+}
+
+func TestAbortRemovesTemporaryFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out")
+	pending := pendingOutputCount()
+	af, err := CreateAtomic(path, 0o644, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := af.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	if pendingOutputCount() != pending+1 {
+		t.Error("CreateAtomic did not register the temporary file")
+	}
+	if err := af.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if names := dirNames(t, dir); len(names) != 0 {
+		t.Errorf("directory holds %q after Abort", names)
+	}
+	if pendingOutputCount() != pending {
+		t.Error("Abort left the temporary file registered")
+	}
+	if err := af.Abort(); err != nil {
+		t.Errorf("second Abort: %v", err)
+	}
+	if err := af.Commit(); err == nil {
+		t.Error("Commit after Abort succeeded")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("output exists after Abort: %v", err)
+	}
+}
+
+func TestRemovePendingOutputs(t *testing.T) {
+	dir := t.TempDir()
+	var files []*AtomicFile
+	for _, name := range []string{"one", "two"} {
+		af, err := CreateAtomic(filepath.Join(dir, name), 0o644, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := af.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, af)
+	}
+	if names := dirNames(t, dir); len(names) != 2 {
+		t.Fatalf("directory holds %q, want two temporary files", names)
+	}
+
+	RemovePendingOutputs()
+	if names := dirNames(t, dir); len(names) != 0 {
+		t.Errorf("directory holds %q after RemovePendingOutputs", names)
+	}
+	if n := pendingOutputCount(); n != 0 {
+		t.Errorf("%d temporary files still registered", n)
+	}
+	for _, af := range files {
+		af.Abort()
+	}
+}
+
+// TestDecryptFileLeavesNoPartialOutput: при ошибке набивки в последней части файла
+// первые части уже расшифрованы, но ни итоговый, ни временный файл не остается
+func TestDecryptFileLeavesNoPartialOutput(t *testing.T) {
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "plain")
+	encryptedPath := filepath.Join(dir, "encrypted")
+	decryptedPath := filepath.Join(dir, "decrypted")
+
+	random := NewTestRand("atomicfile/decrypt")
+	data, err := randomBytes(random, 3*8*fileChunkBlocks+5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plainPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newContext := func(opts ...ContextOption) *CryptoSymmetricContext {
+		alg, err := NewDES()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, err := NewContext(mustDecodeHex("133457799bbcdff1"), alg,
+			append([]ContextOption{WithMode(CBC), WithPadding(PKCS7), WithIV(mustDecodeHex("0011223344556677"))}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ctx
+	}
+	if err := <-newContext().EncryptFileAsync(plainPath, encryptedPath); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted[len(encrypted)-1] ^= 0x01
+	if err := os.WriteFile(encryptedPath, encrypted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-newContext().DecryptFileAsync(encryptedPath, decryptedPath); !errors.Is(err, ErrDecryption) {
+		t.Fatalf("tampered file: error = %v, want ErrDecryption", err)
+	}
+	if names := dirNames(t, dir); len(names) != 2 {
+		t.Errorf("directory holds %q, want only the plain and encrypted files", names)
+	}
+
+	// С перезаписью существующий файл при ошибке тоже остается прежним
+	if err := os.WriteFile(decryptedPath, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-newContext(WithOverwrite(true)).DecryptFileAsync(encryptedPath, decryptedPath); err == nil {
+		t.Fatal("tampered file decrypted with overwrite")
+	}
+	if got, _ := os.ReadFile(decryptedPath); !bytes.Equal(got, []byte("previous")) {
+		t.Errorf("failed decrypt replaced the existing output with %d bytes", len(got))
+	}
+	if names := dirNames(t, dir); len(names) != 3 {
+		t.Errorf("directory holds %q after a failed overwrite", names)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Измерение скорости шифрования для команды bench: для каждой комбинации алгоритма,
+// режима и размера сообщения считаются MB/s и выделения памяти. Для параллельных
+// режимов дополнительно меняется число горутин. Бенчмарки для go test - в bench_test.go.
+
+// BenchConfig - набор комбинаций для измерения
+type BenchConfig struct {
+	Algorithms []string
+	Modes      []string
+	Sizes      []int
+	// Workers - числа горутин для параллельных режимов (ECB, CTR и др.)
+	Workers []int
+	// Duration - минимальная длительность одного измерения; 0 - одна секунда
+	Duration time.Duration
+}
+
+// BenchResult - результат одного измерения
+type BenchResult struct {
+	Algorithm string `json:"algorithm"`
+	Mode      string `json:"mode"`
+	Size      int    `json:"size"`
+	// Workers равен 0 для последовательных режимов
+	Workers     int     `json:"workers"`
+	Iterations  int     `json:"iterations"`
+	NsPerOp     int64   `json:"ns_per_op"`
+	MBPerSec    float64 `json:"mb_per_sec"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+}
+
+// DefaultBenchWorkers возвращает 1, 2, 4, ... до GOMAXPROCS включительно
+func DefaultBenchWorkers() []int {
+	max := runtime.GOMAXPROCS(0)
+	var workers []int
+	for w := 1; w < max; w *= 2 {
+		workers = append(workers, w)
+	}
+	return append(workers, max)
+}
+
+// defaultBenchDuration - длительность измерения по умолчанию, как у go test -bench
+const defaultBenchDuration = time.Second
+
+// maxBenchIterations ограничивает число повторов в одном измерении
+const maxBenchIterations = 1_000_000_000
+
+// benchMeasurement - итог серии из n шифрований
+type benchMeasurement struct {
+	n       int
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+// measureEncrypt шифрует data сериями, увеличивая их длину, пока серия не займет
+// не меньше d, и возвращает последнюю серию. Число повторов подбирается так же, как в go test.
+func measureEncrypt(ctx *CryptoSymmetricContext, data []byte, d time.Duration) (benchMeasurement, error) {
+	n := 1
+	for {
+		m, err := runEncryptSeries(ctx, data, n)
+		if err != nil {
+			return m, err
+		}
+		if m.elapsed >= d || n >= maxBenchIterations {
+			return m, nil
+		}
+		next := 100 * n
+		if m.elapsed > 0 {
+			next = int(int64(n) * int64(d) / int64(m.elapsed))
+		}
+		next += next / 5
+		next = min(max(next, n+1), 100*n, maxBenchIterations)
+		n = next
+	}
+}
+
+// runEncryptSeries выполняет n шифрований и считает время и выделения памяти
+func runEncryptSeries(ctx *CryptoSymmetricContext, data []byte, n int) (benchMeasurement, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if _, err := ctx.Encrypt(data); err != nil {
+			return benchMeasurement{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return benchMeasurement{
+		n:       n,
+		elapsed: elapsed,
+		allocs:  after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
+
+// benchMessage возвращает сообщение длины size для измерений
+func benchMessage(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+// RunBenchmarks выполняет все комбинации; progress, если задан, вызывается после каждого измерения
+func RunBenchmarks(cfg BenchConfig, progress func(BenchResult)) ([]BenchResult, error) {
+	if len(cfg.Sizes) == 0 {
+		return nil, errors.New("no message sizes to benchmark")
+	}
+	for _, size := range cfg.Sizes {
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid message size: %d", size)
+		}
+	}
+	duration := cfg.Duration
+	if duration <= 0 {
+		duration = defaultBenchDuration
+	}
+
+	var results []BenchResult
+	for _, algName := range cfg.Algorithms {
+		spec, ok := LookupAlgorithm(algName)
+		if !ok {
+			return nil, fmt.Errorf("unknown algorithm: %s", algName)
+		}
+		for _, modeName := range cfg.Modes {
+			mode, ok := LookupBlockMode(modeName)
+			if !ok {
+				return nil, fmt.Errorf("unknown mode: %s", modeName)
+			}
+			blockMode, err := mode.BlockMode()
+			if err != nil {
+				return nil, err
+			}
+			workerCounts := []int{0}
+			if blockMode.Parallelizable() && len(cfg.Workers) > 0 {
+				workerCounts = cfg.Workers
+			}
+
+			ctx, err := newBenchContext(spec, mode)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", spec.Name, modeName, err)
+			}
+			for _, size := range cfg.Sizes {
+				data := benchMessage(size)
+				for _, workers := range workerCounts {
+					m, err := measureEncrypt(ctx.withWorkers(workers), data, duration)
+					if err != nil {
+						return nil, fmt.Errorf("%s/%s/%d: %w", spec.Name, modeName, size, err)
+					}
+					result := BenchResult{
+						Algorithm:   spec.Name,
+						Mode:        modeName,
+						Size:        size,
+						Workers:     workers,
+						Iterations:  m.n,
+						NsPerOp:     m.elapsed.Nanoseconds() / int64(m.n),
+						MBPerSec:    float64(size) * float64(m.n) / 1e6 / m.elapsed.Seconds(),
+						AllocsPerOp: int64(m.allocs / uint64(m.n)),
+						BytesPerOp:  int64(m.bytes / uint64(m.n)),
+					}
+					results = append(results, result)
+					if progress != nil {
+						progress(result)
+					}
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// newBenchContext создает контекст со случайным ключом; набивка PKCS7 подходит для всех режимов
+func newBenchContext(spec AlgorithmSpec, mode CipherMode) (*CryptoSymmetricContext, error) {
+	cipher, err := spec.New()
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, spec.BlockSize)
+	for i := range iv {
+		iv[i] = byte(i)
+	}
+	return NewCryptoSymmetricContext(generateRandomBytes(spec.KeySizes[0]), cipher, mode, PKCS7, iv, spec.BlockSize)
+}
+
+func formatBenchWorkers(workers int) string {
+	if workers == 0 {
+		return "-"
+	}
+	return strconv.Itoa(workers)
+}
+
+// WriteBenchTable печатает результаты выровненной таблицей
+func WriteBenchTable(w io.Writer, results []BenchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "algorithm\tmode\tsize\tworkers\tns/op\tMB/s\tallocs/op\tB/op\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%.2f\t%d\t%d\t\n",
+			r.Algorithm, r.Mode, r.Size, formatBenchWorkers(r.Workers), r.NsPerOp, r.MBPerSec, r.AllocsPerOp, r.BytesPerOp)
+	}
+	return tw.Flush()
+}
+
+// WriteBenchCSV печатает результаты в CSV с заголовком
+func WriteBenchCSV(w io.Writer, results []BenchResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"algorithm", "mode", "size", "workers", "iterations", "ns_per_op", "mb_per_sec", "allocs_per_op", "bytes_per_op"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.Algorithm, r.Mode, strconv.Itoa(r.Size), strconv.Itoa(r.Workers), strconv.Itoa(r.Iterations),
+			strconv.FormatInt(r.NsPerOp, 10), strconv.FormatFloat(r.MBPerSec, 'f', 3, 64),
+			strconv.FormatInt(r.AllocsPerOp, 10), strconv.FormatInt(r.BytesPerOp, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// benchReport - JSON-отчет; окружение записывается, чтобы результаты можно было сравнивать во времени
+type benchReport struct {
+	Time       time.Time     `json:"time"`
+	GoVersion  string        `json:"go_version"`
+	GOOS       string        `json:"goos"`
+	GOARCH     string        `json:"goarch"`
+	GOMAXPROCS int           `json:"gomaxprocs"`
+	Results    []BenchResult `json:"results"`
+}
+
+// WriteBenchJSON печатает результаты вместе с описанием окружения
+func WriteBenchJSON(w io.Writer, results []BenchResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(benchReport{
+		Time:       time.Now().UTC(),
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Results:    results,
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchSizes - размеры сообщений для бенчмарков go test; команда bench задает их флагом -size
+var benchSizes = []int{64, 4096, 65536}
+
+// benchmarkEncrypt измеряет шифрование одного сообщения длины size
+func benchmarkEncrypt(b *testing.B, ctx *CryptoSymmetricContext, size int) {
+	data := benchMessage(size)
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ctx.Encrypt(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncrypt - все алгоритмы и режимы с числом горутин по умолчанию
+func BenchmarkEncrypt(b *testing.B) {
+	for _, algName := range AlgorithmNames() {
+		spec, _ := LookupAlgorithm(algName)
+		for _, modeName := range BlockModeNames() {
+			mode, _ := LookupBlockMode(modeName)
+			ctx, err := newBenchContext(spec, mode)
+			if err != nil {
+				b.Fatalf("%s/%s: %v", algName, modeName, err)
+			}
+			for _, size := range benchSizes {
+				b.Run(fmt.Sprintf("%s/%s/%d", algName, modeName, size), func(b *testing.B) {
+					benchmarkEncrypt(b, ctx, size)
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkEncryptWorkers - параллельные режимы с разным числом горутин
+func BenchmarkEncryptWorkers(b *testing.B) {
+	const size = 65536
+	for _, algName := range AlgorithmNames() {
+		spec, _ := LookupAlgorithm(algName)
+		for _, modeName := range BlockModeNames() {
+			mode, _ := LookupBlockMode(modeName)
+			if blockMode, err := mode.BlockMode(); err != nil || !blockMode.Parallelizable() {
+				continue
+			}
+			ctx, err := newBenchContext(spec, mode)
+			if err != nil {
+				b.Fatalf("%s/%s: %v", algName, modeName, err)
+			}
+			for _, workers := range DefaultBenchWorkers() {
+				b.Run(fmt.Sprintf("%s/%s/workers=%d", algName, modeName, workers), func(b *testing.B) {
+					benchmarkEncrypt(b, ctx.withWorkers(workers), size)
+				})
+			}
+		}
+	}
+}
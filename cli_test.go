@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, exitOK},
+		{"help", flag.ErrHelp, exitOK},
+		{"interrupted", errInterrupted, exitInterrupted},
+		{"usage", newUsageError("bad flag"), exitUsage},
+		{"wrapped usage", fmt.Errorf("run: %w", newUsageError("bad flag")), exitUsage},
+		{"usage wrapping a decryption error", newUsageError("%w", ErrDecryption), exitUsage},
+		{"check failed", &checkFailedError{msg: "weak key"}, exitCheckFailed},
+		{"manifest mismatch", fmt.Errorf("a.txt: %w", ErrManifestMismatch), exitCheckFailed},
+		{"decryption", ErrDecryption, exitDecryption},
+		{"authentication", ErrAuthenticationFailed, exitDecryption},
+		{"padding", ErrInvalidPadding, exitDecryption},
+		{"key mismatch", ErrKeyMismatch, exitDecryption},
+		{"key size", &KeySizeError{Algorithm: "DES", Size: 7, Valid: []int{8}}, exitUsage},
+		{"IV", fmt.Errorf("%w: too short", ErrInvalidIV), exitUsage},
+		{"block size", ErrInvalidBlockSize, exitUsage},
+		{"mode", ErrUnsupportedMode, exitUsage},
+		{"padding scheme", ErrUnsupportedPadding, exitUsage},
+		{"argument", fmt.Errorf("%w: workers", ErrInvalidArgument), exitUsage},
+		{"path", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}, exitIO},
+		{"output exists", fmt.Errorf("out: %w", ErrOutputExists), exitIO},
+		{"other", errors.New("something broke"), exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+// captureOutput перенаправляет стандартный вывод и поток ошибок в файлы на время fn
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	dir := t.TempDir()
+	open := func(name string) *os.File {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	outFile, errFile := open("stdout"), open("stderr")
+	savedOut, savedErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	defer func() {
+		os.Stdout, os.Stderr = savedOut, savedErr
+		outFile.Close()
+		errFile.Close()
+	}()
+	fn()
+
+	read := func(f *os.File) string {
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	return read(outFile), read(errFile)
+}
+
+func TestRunCLI(t *testing.T) {
+	const key = "133457799bbcdff1"
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	if err := os.WriteFile(existing, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		want   int
+		stderr string // подстрока сообщения об ошибке
+	}{
+		{"no arguments", nil, exitUsage, "Использование"},
+		{"help", []string{"help"}, exitOK, "Использование"},
+		{"--help", []string{"--help"}, exitOK, "Использование"},
+		{"flag before command", []string{"-key", key}, exitUsage, "Флаги указываются после подкоманды"},
+		{"unknown command", []string{"frobnicate"}, exitUsage, "Неизвестная команда: frobnicate"},
+		{"command help", []string{"encrypt", "-h"}, exitOK, "-key"},
+		{"unknown flag", []string{"encrypt", "-frobnicate"}, exitUsage, "frobnicate"},
+		{"extra arguments", []string{"encrypt", "-key", key, "-text", "hi", "extra"}, exitUsage, "unexpected arguments: extra"},
+		{"missing key", []string{"encrypt", "-text", "hi"}, exitUsage, "-key is required"},
+		{"invalid key hex", []string{"encrypt", "-key", "zz", "-text", "hi"}, exitUsage, "invalid key"},
+		{"wrong key size", []string{"encrypt", "-key", "0011", "-text", "hi"}, exitUsage, "DES key must be one of [8] bytes"},
+		{"unknown algorithm", []string{"encrypt", "-algorithm", "Blowfish", "-key", key, "-text", "hi"}, exitUsage, "unknown algorithm"},
+		{"unknown mode", []string{"encrypt", "-mode", "XTS", "-key", key, "-text", "hi"}, exitUsage, "unknown mode"},
+		{"text and input", []string{"encrypt", "-key", key, "-text", "hi", "-input", existing}, exitUsage, "mutually exclusive"},
+		{"missing input", []string{"encrypt", "-key", key, "-input", filepath.Join(dir, "missing")}, exitIO, "no such file"},
+		{"output exists", []string{"encrypt", "-key", key, "-mode", "ECB", "-text", "hi", "-output", existing}, exitIO, ""},
+		{"weak key rejected", []string{"encrypt", "-reject-weak-keys", "-key", "0101010101010101", "-text", "hi"}, exitUsage, "weak DES key"},
+		{"keycheck weak", []string{"keycheck", "-key", "0101010101010101"}, exitCheckFailed, "key is weak"},
+		{"keycheck normal", []string{"keycheck", "-key", key}, exitOK, ""},
+		{"inspect without topic", []string{"inspect"}, exitUsage, "inspect requires a topic"},
+		{"unknown selftest suite", []string{"selftest", "-suite", "nope"}, exitUsage, "unknown suite: nope"},
+	}
+	for _, tt := range tests {
+		var code int
+		_, stderr := captureOutput(t, func() { code = runCLI(tt.args) })
+		if code != tt.want {
+			t.Errorf("%s: runCLI(%q) = %d, want %d; stderr:\n%s", tt.name, tt.args, code, tt.want, stderr)
+		}
+		if !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%s: stderr %q does not mention %q", tt.name, stderr, tt.stderr)
+		}
+	}
+
+	data, err := os.ReadFile(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "keep" {
+		t.Errorf("existing output was overwritten: %q", data)
+	}
+}
+
+func TestRunCLIRoundTrip(t *testing.T) {
+	const key = "133457799bbcdff1"
+	var code int
+	ciphertext, stderr := captureOutput(t, func() {
+		code = runCLI([]string{"encrypt", "-key", key, "-mode", "ECB", "-text", "hello"})
+	})
+	if code != exitOK {
+		t.Fatalf("encrypt: exit code %d; stderr:\n%s", code, stderr)
+	}
+	ciphertext = strings.TrimSpace(ciphertext)
+
+	plaintext, stderr := captureOutput(t, func() {
+		code = runCLI([]string{"decrypt", "-key", key, "-mode", "ECB", "-text", ciphertext})
+	})
+	if code != exitOK || plaintext != "hello" {
+		t.Errorf("decrypt: exit code %d, output %q; stderr:\n%s", code, plaintext, stderr)
+	}
+
+	_, stderr = captureOutput(t, func() {
+		code = runCLI([]string{"decrypt", "-key", "0e329232ea6d0d73", "-mode", "ECB", "-text", ciphertext})
+	})
+	if code != exitDecryption {
+		t.Errorf("decrypt with a wrong key: exit code %d, want %d; stderr:\n%s", code, exitDecryption, stderr)
+	}
+}
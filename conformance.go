@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"cryptolab/conformance"
+)
+
+// Проверки на соответствие для всего, что есть в реестрах. Проверки отдельных алгоритмов,
+// расписаний ключей, раундовых функций и набивок - в пакете cryptolab/conformance, который
+// можно импортировать из других модулей; здесь остаются проверки, которым нужен контекст.
+
+// ConformanceReporter получает сообщения о нарушениях; *testing.T подходит напрямую
+type ConformanceReporter = conformance.Reporter
+
+// PanicError - паника, перехваченная во время проверки; всегда считается нарушением
+type PanicError = conformance.PanicError
+
+// ConformanceCheck - одна проверка на соответствие; используется командой selftest и тестами
+type ConformanceCheck struct {
+	Name string
+	Run  func(r ConformanceReporter)
+}
+
+// ConformanceChecks возвращает проверки всех алгоритмов, расписаний ключей, раундовых функций
+// и набивок репозитория, а также проверки контекста для каждого алгоритма
+func ConformanceChecks() []ConformanceCheck {
+	var checks []ConformanceCheck
+	for _, name := range AlgorithmNames() {
+		spec, _ := LookupAlgorithm(name)
+		checks = append(checks, ConformanceCheck{"Algorithm/" + spec.Name, func(r ConformanceReporter) {
+			conformance.CheckSymmetricAlgorithm(r, spec.Name, func() (conformance.Algorithm, error) { return spec.New() })
+		}})
+	}
+	keyRounds := []struct {
+		name     string
+		kr       KeyRound
+		keySizes []int
+	}{
+		{"DESKeySchedule", &DESKeySchedule{}, []int{8}},
+		{"DEALKeySchedule", &DEALKeySchedule{}, []int{16, 24, 32}},
+	}
+	for _, k := range keyRounds {
+		checks = append(checks, ConformanceCheck{"KeyRound/" + k.name, func(r ConformanceReporter) {
+			conformance.CheckKeyRound(r, k.name, k.kr, k.keySizes)
+		}})
+	}
+	transforms := []struct {
+		name                    string
+		ct                      CipherTransform
+		inputSize, roundKeySize int
+	}{
+		// Раундовая функция DES работает с половиной блока и 48-битным раундовым ключом
+		{"DESRoundFunction", &DESRoundFunction{}, 4, 6},
+		{"DEALRoundFunction", NewDEALRoundFunction(), 8, 8},
+	}
+	for _, c := range transforms {
+		checks = append(checks, ConformanceCheck{"CipherTransform/" + c.name, func(r ConformanceReporter) {
+			conformance.CheckCipherTransform(r, c.name, c.ct, c.inputSize, c.roundKeySize)
+		}})
+	}
+	for _, name := range PaddingNames() {
+		mode, _ := LookupPadding(name)
+		checks = append(checks, ConformanceCheck{"Padding/" + name, func(r ConformanceReporter) {
+			padding, err := mode.Padding()
+			if err != nil {
+				r.Errorf("%s: %v", name, err)
+				return
+			}
+			for _, blockSize := range []int{8, 16} {
+				conformance.CheckPadding(r, padding, blockSize)
+			}
+		}})
+	}
+	for _, name := range AlgorithmNames() {
+		spec, _ := LookupAlgorithm(name)
+		checks = append(checks, ConformanceCheck{"Context/" + spec.Name, func(r ConformanceReporter) {
+			CheckContextRoundTrips(r, spec, conformanceRandom(spec.KeySizes[0]))
+		}})
+	}
+	return checks
+}
+
+// CheckContextRoundTrips проверяет обратимость контекста для всех режимов и набивок
+// и отсутствие паники при дешифровании испорченных данных
+func CheckContextRoundTrips(r ConformanceReporter, spec AlgorithmSpec, key []byte) {
+	iv := conformanceRandom(spec.BlockSize)
+	for _, modeName := range BlockModeNames() {
+		mode, _ := LookupBlockMode(modeName)
+		for _, paddingName := range PaddingNames() {
+			padding, _ := LookupPadding(paddingName)
+			name := fmt.Sprintf("%s/%s/%s", spec.Name, modeName, paddingName)
+
+			alg, err := spec.New()
+			if err != nil {
+				r.Errorf("%s: New: %v", name, err)
+				return
+			}
+			ctx, err := NewCryptoSymmetricContext(key, alg, mode, padding, iv, spec.BlockSize)
+			if p, _ := padding.Padding(); !paddingSupportsBlockSize(p, spec.BlockSize) {
+				// Схема не определена для этого блока (PKCS5 с 16-байтовым): контекст отвергает ее сразу
+				if !errors.Is(err, ErrInvalidBlockSize) {
+					r.Errorf("%s: NewCryptoSymmetricContext: error = %v, want ErrInvalidBlockSize", name, err)
+				}
+				continue
+			}
+			if err != nil {
+				r.Errorf("%s: NewCryptoSymmetricContext: %v", name, err)
+				continue
+			}
+
+			var ciphertexts [][]byte
+			for length := 1; length <= 3*spec.BlockSize; length++ {
+				data := conformanceRandom(length)
+				if data[length-1] == 0 {
+					data[length-1] = 1
+				}
+				var ciphertext []byte
+				err := conformanceCall(func() error {
+					var err error
+					ciphertext, err = ctx.Encrypt(data)
+					return err
+				})
+				if err != nil {
+					if isConformancePanic(err) {
+						r.Errorf("%s: Encrypt of %d bytes: %v", name, length, err)
+					}
+					// Например, невыровненные данные без набивки
+					continue
+				}
+				ciphertexts = append(ciphertexts, ciphertext)
+
+				var decrypted []byte
+				err = conformanceCall(func() error {
+					var err error
+					decrypted, err = ctx.Decrypt(ciphertext)
+					return err
+				})
+				if err != nil {
+					r.Errorf("%s: Decrypt of %d-byte message: %v", name, length, err)
+				} else if !bytes.Equal(decrypted, data) {
+					r.Errorf("%s: round trip of %d bytes returned %x, want %x", name, length, decrypted, data)
+				}
+			}
+
+			var malformed [][]byte
+			for _, c := range ciphertexts {
+				malformed = append(malformed, c[:len(c)-1], append(append([]byte(nil), c...), 0), c[:len(c)/2])
+			}
+			for i := 0; i < 16; i++ {
+				malformed = append(malformed, conformanceRandom(i*spec.BlockSize/2))
+			}
+			for _, c := range malformed {
+				if err := conformanceCall(func() error { _, err := ctx.Decrypt(c); return err }); isConformancePanic(err) {
+					r.Errorf("%s: Decrypt(%x) %v", name, c, err)
+				}
+			}
+		}
+	}
+}
+
+// conformanceCall выполняет f и возвращает *PanicError, если f паникует
+func conformanceCall(f func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// isConformancePanic сообщает, что err - перехваченная паника, а не отказ
+func isConformancePanic(err error) bool {
+	var p *PanicError
+	return errors.As(err, &p)
+}
+
+func conformanceRandom(size int) []byte {
+	if size < 0 {
+		size = 0
+	}
+	b, err := randomBytes(checkRandom, size)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,719 @@
+// Package conformance - набор проверок на соответствие для реализаций блочных шифров,
+// расширения ключа, раундовых функций и схем набивки.
+//
+// Проверки не зависят от пакета testing: *testing.T подходит как Reporter напрямую.
+// Интерфейсы пакета повторяют интерфейсы SymmetricAlgorithm, KeyRound, CipherTransform
+// и Padding по сигнатурам методов, поэтому реализации подходят без адаптеров.
+// NewKeyed распознается по сигнатуре и может возвращать собственный интерфейсный тип
+// реализации (например, BlockCipher), если у него есть методы Block.
+// Паника в проверяемом коде всегда считается нарушением, в том числе там,
+// где ожидается отказ: отказ должен быть ошибкой, а не паникой.
+package conformance
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Reporter получает сообщения о нарушениях
+type Reporter interface {
+	Errorf(format string, args ...any)
+}
+
+// Block - блочный шифр с установленным ключом
+type Block interface {
+	Encrypt(block []byte) ([]byte, error)
+	Decrypt(block []byte) ([]byte, error)
+}
+
+// Algorithm - блочный шифр с размерами блока и ключа, установкой ключа и асинхронным API
+type Algorithm interface {
+	Block
+	// BlockSize возвращает размер блока в байтах; известен до установки ключа
+	BlockSize() int
+	// KeySizes возвращает допустимые длины ключа в байтах
+	KeySizes() []int
+	SetKey(key []byte) error
+	EncryptAsync(data []byte) (<-chan []byte, <-chan error)
+	DecryptAsync(data []byte) (<-chan []byte, <-chan error)
+}
+
+// KeyRound - расширение ключа в раундовые ключи
+type KeyRound interface {
+	GenerateKeys(inputKey []byte) ([][]byte, error)
+}
+
+// CipherTransform - раундовая функция
+type CipherTransform interface {
+	Encryption(inputBlock, roundKey []byte) ([]byte, error)
+	Decryption(inputBlock, roundKey []byte) ([]byte, error)
+}
+
+// Padding - схема набивки
+type Padding interface {
+	Name() string
+	Pad(data []byte, blockSize int) ([]byte, error)
+	Unpad(data []byte, blockSize int) ([]byte, error)
+}
+
+// Keyed - алгоритм, создающий неизменяемые экземпляры для ключа без изменения себя
+type Keyed interface {
+	NewKeyed(key []byte) (Block, error)
+}
+
+// RoundKeyPreparer - раундовая функция, которой нужно заранее получить набор раундовых ключей
+type RoundKeyPreparer interface {
+	PrepareRoundKeys(roundKeys [][]byte) error
+}
+
+var (
+	blockType = reflect.TypeOf((*Block)(nil)).Elem()
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// keyedConstructor возвращает NewKeyed алгоритма, если он есть: либо Keyed, либо метод
+// NewKeyed(key []byte) (T, error), где T реализует Block. Интерфейсы Go не ковариантны
+// по результату, поэтому NewKeyed с собственным типом экземпляра ищется по сигнатуре.
+func keyedConstructor(alg any) (func(key []byte) (Block, error), bool) {
+	if keyed, ok := alg.(Keyed); ok {
+		return keyed.NewKeyed, true
+	}
+	method := reflect.ValueOf(alg).MethodByName("NewKeyed")
+	if !method.IsValid() {
+		return nil, false
+	}
+	t := method.Type()
+	if t.NumIn() != 1 || t.In(0) != reflect.TypeOf([]byte(nil)) || t.NumOut() != 2 ||
+		!t.Out(0).Implements(blockType) || t.Out(1) != errorType {
+		return nil, false
+	}
+	return func(key []byte) (Block, error) {
+		out := method.Call([]reflect.Value{reflect.ValueOf(key)})
+		err, _ := out[1].Interface().(error)
+		if out[0].IsNil() {
+			return nil, err
+		}
+		return out[0].Interface().(Block), err
+	}, true
+}
+
+// PanicError - паника, перехваченная во время проверки
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// call выполняет f и возвращает *PanicError, если f паникует
+func call(f func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// isPanic сообщает, что err - перехваченная паника
+func isPanic(err error) bool {
+	var p *PanicError
+	return errors.As(err, &p)
+}
+
+// checkRejected сообщает о нарушении, если f не вернула ошибку или запаниковала
+func checkRejected(r Reporter, err error, format string, args ...any) {
+	what := fmt.Sprintf(format, args...)
+	switch {
+	case err == nil:
+		r.Errorf("%s was accepted", what)
+	case isPanic(err):
+		r.Errorf("%s panicked instead of returning an error: %v", what, err)
+	}
+}
+
+// CheckSymmetricAlgorithm проверяет сведения о размерах, обратимость, отказ от неверных
+// размеров ключа и блока, детерминированность, неизменность входных данных,
+// потокобезопасность и асинхронное API. newAlg создает новый экземпляр без ключа.
+func CheckSymmetricAlgorithm(r Reporter, name string, newAlg func() (Algorithm, error)) {
+	probe, err := newInstance(newAlg)
+	if err != nil {
+		r.Errorf("%s: New: %v", name, err)
+		return
+	}
+	s := &suite{r: r, name: name, newAlg: newAlg, blockSize: probe.BlockSize(), keySizes: probe.KeySizes()}
+	if s.blockSize <= 0 || len(s.keySizes) == 0 {
+		r.Errorf("%s: must report key sizes and a positive block size, got %d and %v", name, s.blockSize, s.keySizes)
+		return
+	}
+	for _, keySize := range s.keySizes {
+		key := randomBytes(keySize)
+		alg := s.newKeyed(key)
+		if alg == nil {
+			continue
+		}
+		s.checkSizeMetadata(alg, fmt.Sprintf("with %d-byte key", keySize))
+		s.checkRoundTrip(alg, keySize)
+		s.checkBlockSizeRejection(alg, keySize)
+		s.checkDeterminism(alg, key)
+		s.checkInputsUnchanged(key)
+		s.checkConcurrency(alg, keySize)
+		s.checkAsyncConsistency(alg, keySize)
+		if newKeyed, ok := keyedConstructor(probe); ok {
+			CheckKeyedInstance(r, name, newAlg, newKeyed, key)
+		}
+	}
+	s.checkKeySizeRejection()
+}
+
+// suite - состояние проверок одного алгоритма
+type suite struct {
+	r         Reporter
+	name      string
+	newAlg    func() (Algorithm, error)
+	blockSize int
+	keySizes  []int
+}
+
+// newInstance вызывает конструктор, превращая панику в ошибку
+func newInstance(newAlg func() (Algorithm, error)) (Algorithm, error) {
+	var alg Algorithm
+	err := call(func() error {
+		var err error
+		alg, err = newAlg()
+		return err
+	})
+	return alg, err
+}
+
+func (s *suite) newKeyed(key []byte) Algorithm {
+	alg, err := newInstance(s.newAlg)
+	if err != nil {
+		s.r.Errorf("%s: New: %v", s.name, err)
+		return nil
+	}
+	if err := call(func() error { return alg.SetKey(key) }); err != nil {
+		s.r.Errorf("%s: SetKey with %d-byte key: %v", s.name, len(key), err)
+		return nil
+	}
+	return alg
+}
+
+// encrypt и decrypt вызывают шифр с копией блока и превращают панику в ошибку
+func encrypt(b Block, block []byte) ([]byte, error) {
+	var out []byte
+	err := call(func() error {
+		var err error
+		out, err = b.Encrypt(append([]byte(nil), block...))
+		return err
+	})
+	return out, err
+}
+
+func decrypt(b Block, block []byte) ([]byte, error) {
+	var out []byte
+	err := call(func() error {
+		var err error
+		out, err = b.Decrypt(append([]byte(nil), block...))
+		return err
+	})
+	return out, err
+}
+
+// checkSizeMetadata сверяет размеры экземпляра с размерами нового экземпляра; они не должны зависеть от ключа
+func (s *suite) checkSizeMetadata(alg Algorithm, state string) {
+	if got := alg.BlockSize(); got != s.blockSize {
+		s.r.Errorf("%s: BlockSize %s = %d, want %d", s.name, state, got, s.blockSize)
+	}
+	if got := alg.KeySizes(); !equalInts(got, s.keySizes) {
+		s.r.Errorf("%s: KeySizes %s = %v, want %v", s.name, state, got, s.keySizes)
+	}
+}
+
+func (s *suite) checkRoundTrip(alg Algorithm, keySize int) {
+	for i := 0; i < 16; i++ {
+		block := randomBytes(s.blockSize)
+		ciphertext, err := encrypt(alg, block)
+		if err != nil {
+			s.r.Errorf("%s/key%d: Encrypt: %v", s.name, keySize, err)
+			return
+		}
+		if len(ciphertext) != s.blockSize {
+			s.r.Errorf("%s/key%d: Encrypt returned %d bytes, want %d", s.name, keySize, len(ciphertext), s.blockSize)
+			return
+		}
+		plaintext, err := decrypt(alg, ciphertext)
+		if err != nil {
+			s.r.Errorf("%s/key%d: Decrypt: %v", s.name, keySize, err)
+			return
+		}
+		if !bytes.Equal(plaintext, block) {
+			s.r.Errorf("%s/key%d: Decrypt(Encrypt(%x)) = %x", s.name, keySize, block, plaintext)
+			return
+		}
+	}
+}
+
+// badSizes возвращает размеры, которых нет среди допустимых
+func badSizes(valid []int) []int {
+	allowed := make(map[int]bool)
+	max := 0
+	for _, v := range valid {
+		allowed[v] = true
+		if v > max {
+			max = v
+		}
+	}
+	var bad []int
+	for _, candidate := range []int{0, 1, valid[0] - 1, valid[0] + 1, max + 1, 2 * max} {
+		if candidate >= 0 && !allowed[candidate] {
+			bad = append(bad, candidate)
+			allowed[candidate] = true
+		}
+	}
+	return bad
+}
+
+func (s *suite) checkKeySizeRejection() {
+	for _, size := range badSizes(s.keySizes) {
+		alg, err := newInstance(s.newAlg)
+		if err != nil {
+			s.r.Errorf("%s: New: %v", s.name, err)
+			return
+		}
+		err = call(func() error { return alg.SetKey(randomBytes(size)) })
+		checkRejected(s.r, err, "%s: SetKey with a %d-byte key", s.name, size)
+	}
+}
+
+func (s *suite) checkBlockSizeRejection(alg Algorithm, keySize int) {
+	for _, size := range badSizes([]int{s.blockSize}) {
+		block := randomBytes(size)
+		_, err := encrypt(alg, block)
+		checkRejected(s.r, err, "%s/key%d: Encrypt of a %d-byte block", s.name, keySize, size)
+		_, err = decrypt(alg, block)
+		checkRejected(s.r, err, "%s/key%d: Decrypt of a %d-byte block", s.name, keySize, size)
+	}
+}
+
+func (s *suite) checkDeterminism(alg Algorithm, key []byte) {
+	other := s.newKeyed(key)
+	if other == nil {
+		return
+	}
+	block := randomBytes(s.blockSize)
+	first, err1 := encrypt(alg, block)
+	second, err2 := encrypt(alg, block)
+	third, err3 := encrypt(other, block)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		s.r.Errorf("%s/key%d: Encrypt failed during determinism check: %v", s.name, len(key), err)
+		return
+	}
+	if !bytes.Equal(first, second) {
+		s.r.Errorf("%s/key%d: repeated Encrypt of the same block differs", s.name, len(key))
+	}
+	if !bytes.Equal(first, third) {
+		s.r.Errorf("%s/key%d: two instances with the same key disagree", s.name, len(key))
+	}
+}
+
+// CheckKeyedInstance проверяет, что экземпляр из newKeyed шифрует так же, как SetKey,
+// не зависит от последующей смены ключа у источника и от изменения переданного ключа.
+// Для алгоритмов, чей NewKeyed возвращает собственный тип интерфейса, newKeyed - обертка над ним.
+func CheckKeyedInstance(r Reporter, name string, newAlg func() (Algorithm, error), newKeyed func(key []byte) (Block, error), key []byte) {
+	reference, err := newInstance(newAlg)
+	if err == nil {
+		err = call(func() error { return reference.SetKey(key) })
+	}
+	if err != nil {
+		r.Errorf("%s/key%d: reference instance: %v", name, len(key), err)
+		return
+	}
+
+	keyCopy := append([]byte(nil), key...)
+	var keyed Block
+	err = call(func() error {
+		var err error
+		keyed, err = newKeyed(keyCopy)
+		return err
+	})
+	if err != nil {
+		r.Errorf("%s/key%d: NewKeyed: %v", name, len(key), err)
+		return
+	}
+	// Экземпляр не должен ссылаться на срез ключа вызывающей стороны
+	for i := range keyCopy {
+		keyCopy[i] ^= 0xFF
+	}
+
+	block := randomBytes(reference.BlockSize())
+	want, err := encrypt(reference, block)
+	if err != nil {
+		r.Errorf("%s/key%d: Encrypt failed during keyed instance check: %v", name, len(key), err)
+		return
+	}
+	got, err := encrypt(keyed, block)
+	if err != nil {
+		r.Errorf("%s/key%d: keyed Encrypt: %v", name, len(key), err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		r.Errorf("%s/key%d: keyed instance disagrees with SetKey or depends on the caller's key slice", name, len(key))
+	}
+	if decrypted, err := decrypt(keyed, got); err != nil || !bytes.Equal(decrypted, block) {
+		r.Errorf("%s/key%d: keyed instance does not round-trip", name, len(key))
+	}
+}
+
+func (s *suite) checkInputsUnchanged(key []byte) {
+	keyCopy := append([]byte(nil), key...)
+	alg := s.newKeyed(keyCopy)
+	if alg == nil {
+		return
+	}
+	if !bytes.Equal(keyCopy, key) {
+		s.r.Errorf("%s/key%d: SetKey modified the key", s.name, len(key))
+	}
+
+	block := randomBytes(s.blockSize)
+	input := append([]byte(nil), block...)
+	var ciphertext []byte
+	err := call(func() error {
+		var err error
+		ciphertext, err = alg.Encrypt(input)
+		return err
+	})
+	if err != nil {
+		s.r.Errorf("%s/key%d: Encrypt: %v", s.name, len(key), err)
+		return
+	}
+	if !bytes.Equal(input, block) {
+		s.r.Errorf("%s/key%d: Encrypt modified its input", s.name, len(key))
+	}
+	ciphertextCopy := append([]byte(nil), ciphertext...)
+	err = call(func() error { _, err := alg.Decrypt(ciphertextCopy); return err })
+	if err != nil {
+		s.r.Errorf("%s/key%d: Decrypt: %v", s.name, len(key), err)
+		return
+	}
+	if !bytes.Equal(ciphertextCopy, ciphertext) {
+		s.r.Errorf("%s/key%d: Decrypt modified its input", s.name, len(key))
+	}
+}
+
+// checkConcurrency сравнивает параллельное шифрование на общем экземпляре с последовательным
+func (s *suite) checkConcurrency(alg Algorithm, keySize int) {
+	const workers = 16
+	const repeats = 32
+	blocks := make([][]byte, workers)
+	expected := make([][]byte, workers)
+	for i := range blocks {
+		blocks[i] = randomBytes(s.blockSize)
+		out, err := encrypt(alg, blocks[i])
+		if err != nil {
+			s.r.Errorf("%s/key%d: Encrypt: %v", s.name, keySize, err)
+			return
+		}
+		expected[i] = out
+	}
+
+	failures := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < repeats; j++ {
+				out, err := encrypt(alg, blocks[i])
+				if err == nil && !bytes.Equal(out, expected[i]) {
+					err = errors.New("different result")
+				}
+				if err != nil {
+					failures[i] = err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(failures...); err != nil {
+		s.r.Errorf("%s/key%d: concurrent Encrypt on a shared instance failed: %v", s.name, keySize, err)
+	}
+}
+
+func (s *suite) checkAsyncConsistency(alg Algorithm, keySize int) {
+	block := randomBytes(s.blockSize)
+	expected, err := encrypt(alg, block)
+	if err != nil {
+		s.r.Errorf("%s/key%d: Encrypt: %v", s.name, keySize, err)
+		return
+	}
+
+	got, err := awaitAsync(func() (<-chan []byte, <-chan error) {
+		return alg.EncryptAsync(append([]byte(nil), block...))
+	})
+	if err != nil {
+		s.r.Errorf("%s/key%d: EncryptAsync: %v", s.name, keySize, err)
+	} else if !bytes.Equal(got, expected) {
+		s.r.Errorf("%s/key%d: EncryptAsync differs from Encrypt", s.name, keySize)
+	}
+
+	got, err = awaitAsync(func() (<-chan []byte, <-chan error) {
+		return alg.DecryptAsync(append([]byte(nil), expected...))
+	})
+	if err != nil {
+		s.r.Errorf("%s/key%d: DecryptAsync: %v", s.name, keySize, err)
+	} else if !bytes.Equal(got, block) {
+		s.r.Errorf("%s/key%d: DecryptAsync differs from Decrypt", s.name, keySize)
+	}
+
+	_, err = awaitAsync(func() (<-chan []byte, <-chan error) {
+		return alg.EncryptAsync(randomBytes(s.blockSize + 1))
+	})
+	checkRejected(s.r, err, "%s/key%d: EncryptAsync of a bad block", s.name, keySize)
+}
+
+// awaitAsync запускает асинхронный вызов, дожидается результата и проверяет, что оба канала закрываются.
+// Паника при запуске возвращается как *PanicError; паника внутри горутины вызова завершит процесс.
+func awaitAsync(start func() (<-chan []byte, <-chan error)) ([]byte, error) {
+	var resultChan <-chan []byte
+	var errChan <-chan error
+	if err := call(func() error { resultChan, errChan = start(); return nil }); err != nil {
+		return nil, err
+	}
+	timeout := time.After(10 * time.Second)
+	var result []byte
+	var err error
+	for resultChan != nil || errChan != nil {
+		select {
+		case v, ok := <-resultChan:
+			if !ok {
+				resultChan = nil
+				continue
+			}
+			result = v
+		case e, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			err = e
+		case <-timeout:
+			return nil, errors.New("async result channels were not closed")
+		}
+	}
+	if err == nil && result == nil {
+		return nil, errors.New("async call returned neither a result nor an error")
+	}
+	return result, err
+}
+
+// CheckKeyRound проверяет расширение ключа: детерминированность, неизменность ключа,
+// одинаковую длину раундовых ключей и отказ от неверных размеров ключа
+func CheckKeyRound(r Reporter, name string, kr KeyRound, keySizes []int) {
+	generate := func(key []byte) ([][]byte, error) {
+		var roundKeys [][]byte
+		err := call(func() error {
+			var err error
+			roundKeys, err = kr.GenerateKeys(key)
+			return err
+		})
+		return roundKeys, err
+	}
+	for _, size := range keySizes {
+		key := randomBytes(size)
+		keyCopy := append([]byte(nil), key...)
+		first, err := generate(keyCopy)
+		if err != nil {
+			r.Errorf("%s/key%d: GenerateKeys: %v", name, size, err)
+			continue
+		}
+		if !bytes.Equal(keyCopy, key) {
+			r.Errorf("%s/key%d: GenerateKeys modified the key", name, size)
+		}
+		if len(first) == 0 {
+			r.Errorf("%s/key%d: GenerateKeys returned no round keys", name, size)
+			continue
+		}
+		for i, rk := range first {
+			if len(rk) != len(first[0]) {
+				r.Errorf("%s/key%d: round key %d has %d bytes, round key 0 has %d", name, size, i, len(rk), len(first[0]))
+			}
+		}
+		// Раундовые ключи не должны ссылаться на срез ключа вызывающей стороны
+		snapshot := make([][]byte, len(first))
+		for i, rk := range first {
+			snapshot[i] = append([]byte(nil), rk...)
+		}
+		for i := range keyCopy {
+			keyCopy[i] ^= 0xFF
+		}
+		second, err := generate(key)
+		if err != nil || len(second) != len(first) {
+			r.Errorf("%s/key%d: repeated GenerateKeys is not deterministic", name, size)
+			continue
+		}
+		for i := range first {
+			if !bytes.Equal(first[i], snapshot[i]) {
+				r.Errorf("%s/key%d: round keys alias the input key", name, size)
+				break
+			}
+			if !bytes.Equal(first[i], second[i]) {
+				r.Errorf("%s/key%d: repeated GenerateKeys is not deterministic", name, size)
+				break
+			}
+		}
+	}
+	for _, size := range badSizes(keySizes) {
+		_, err := generate(randomBytes(size))
+		checkRejected(r, err, "%s: GenerateKeys with a %d-byte key", name, size)
+	}
+}
+
+// CheckCipherTransform проверяет раундовую функцию: детерминированность,
+// неизменность входных данных и постоянную длину результата.
+// Функции с RoundKeyPreparer перед вызовом получают все раундовые ключи проверки.
+func CheckCipherTransform(r Reporter, name string, ct CipherTransform, inputSize, roundKeySize int) {
+	apply := func(f func([]byte, []byte) ([]byte, error), input, roundKey []byte) ([]byte, error) {
+		var out []byte
+		err := call(func() error {
+			var err error
+			out, err = f(input, roundKey)
+			return err
+		})
+		return out, err
+	}
+	roundKeys := make([][]byte, 8)
+	for i := range roundKeys {
+		roundKeys[i] = randomBytes(roundKeySize)
+	}
+	if preparer, ok := ct.(RoundKeyPreparer); ok {
+		if err := call(func() error { return preparer.PrepareRoundKeys(roundKeys) }); err != nil {
+			r.Errorf("%s: PrepareRoundKeys: %v", name, err)
+			return
+		}
+	}
+	var outputSize int
+	for i, roundKey := range roundKeys {
+		input := randomBytes(inputSize)
+		inputCopy := append([]byte(nil), input...)
+		keyCopy := append([]byte(nil), roundKey...)
+
+		first, err := apply(ct.Encryption, inputCopy, keyCopy)
+		if err != nil {
+			r.Errorf("%s: Encryption: %v", name, err)
+			return
+		}
+		if !bytes.Equal(inputCopy, input) || !bytes.Equal(keyCopy, roundKey) {
+			r.Errorf("%s: Encryption modified its input", name)
+		}
+		second, err := apply(ct.Encryption, input, roundKey)
+		if err != nil || !bytes.Equal(first, second) {
+			r.Errorf("%s: Encryption is not deterministic", name)
+		}
+		if i == 0 {
+			outputSize = len(first)
+		} else if len(first) != outputSize {
+			r.Errorf("%s: Encryption output length changed from %d to %d", name, outputSize, len(first))
+		}
+		if _, err := apply(ct.Decryption, input, roundKey); err != nil {
+			r.Errorf("%s: Decryption: %v", name, err)
+		}
+	}
+}
+
+// CheckPadding проверяет, что Unpad(Pad(data)) == data для данных любой длины,
+// а Unpad не паникует на произвольных и заведомо испорченных данных.
+// Данные оканчиваются ненулевым байтом: иначе нулевую набивку нельзя снять однозначно.
+func CheckPadding(r Reporter, p Padding, blockSize int) {
+	name := fmt.Sprintf("%s/block%d", p.Name(), blockSize)
+	for length := 0; length <= 3*blockSize; length++ {
+		data := randomBytes(length)
+		if length > 0 && data[length-1] == 0 {
+			data[length-1] = 1
+		}
+		original := append([]byte(nil), data...)
+
+		var padded []byte
+		err := call(func() error {
+			var err error
+			padded, err = p.Pad(data, blockSize)
+			return err
+		})
+		if isPanic(err) {
+			r.Errorf("%s: Pad of %d bytes: %v", name, length, err)
+			continue
+		}
+		if err != nil {
+			// Схема может не поддерживать этот размер блока или невыровненные данные
+			continue
+		}
+		if !bytes.Equal(data, original) {
+			r.Errorf("%s: Pad modified its input", name)
+		}
+		if len(padded)%blockSize != 0 && !bytes.Equal(padded, data) {
+			r.Errorf("%s: Pad of %d bytes returned %d bytes, not a multiple of the block", name, length, len(padded))
+		}
+
+		var unpadded []byte
+		err = call(func() error {
+			var err error
+			unpadded, err = p.Unpad(padded, blockSize)
+			return err
+		})
+		if err != nil {
+			r.Errorf("%s: Unpad of padded %d bytes: %v", name, length, err)
+		} else if !bytes.Equal(unpadded, original) {
+			r.Errorf("%s: round trip of %d bytes returned %x, want %x", name, length, unpadded, original)
+		}
+	}
+
+	malformed := [][]byte{
+		nil,
+		{},
+		make([]byte, blockSize),
+		bytes.Repeat([]byte{0xFF}, blockSize),
+		bytes.Repeat([]byte{byte(blockSize + 1)}, blockSize),
+		bytes.Repeat([]byte{0x80}, blockSize+1),
+	}
+	for i := 0; i < 64; i++ {
+		malformed = append(malformed, randomBytes(i%(3*blockSize+2)))
+	}
+	for _, data := range malformed {
+		if err := call(func() error { _, err := p.Unpad(data, blockSize); return err }); isPanic(err) {
+			r.Errorf("%s: Unpad(%x) %v", name, data, err)
+		}
+	}
+}
+
+func randomBytes(size int) []byte {
+	if size < 0 {
+		size = 0
+	}
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
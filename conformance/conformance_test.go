@@ -0,0 +1,339 @@
+package conformance
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stdAlgorithm - Algorithm поверх блочного шифра стандартной библиотеки
+type stdAlgorithm struct {
+	blockSize int
+	keySizes  []int
+	newCipher func(key []byte) (cipher.Block, error)
+	block     cipher.Block
+}
+
+func (a *stdAlgorithm) BlockSize() int  { return a.blockSize }
+func (a *stdAlgorithm) KeySizes() []int { return a.keySizes }
+
+func (a *stdAlgorithm) SetKey(key []byte) error {
+	valid := false
+	for _, size := range a.keySizes {
+		valid = valid || size == len(key)
+	}
+	if !valid {
+		return fmt.Errorf("invalid key size %d", len(key))
+	}
+	block, err := a.newCipher(key)
+	if err != nil {
+		return err
+	}
+	a.block = block
+	return nil
+}
+
+func (a *stdAlgorithm) crypt(data []byte, f func(dst, src []byte)) ([]byte, error) {
+	if len(data) != a.blockSize {
+		return nil, fmt.Errorf("block must be %d bytes, got %d", a.blockSize, len(data))
+	}
+	out := make([]byte, len(data))
+	f(out, data)
+	return out, nil
+}
+
+func (a *stdAlgorithm) Encrypt(data []byte) ([]byte, error) {
+	if a.block == nil {
+		return nil, errors.New("key is not set")
+	}
+	return a.crypt(data, a.block.Encrypt)
+}
+
+func (a *stdAlgorithm) Decrypt(data []byte) ([]byte, error) {
+	if a.block == nil {
+		return nil, errors.New("key is not set")
+	}
+	return a.crypt(data, a.block.Decrypt)
+}
+
+func async(f func([]byte) ([]byte, error), data []byte) (<-chan []byte, <-chan error) {
+	resultChan := make(chan []byte, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(resultChan)
+		defer close(errChan)
+		out, err := f(data)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		resultChan <- out
+	}()
+	return resultChan, errChan
+}
+
+func (a *stdAlgorithm) EncryptAsync(data []byte) (<-chan []byte, <-chan error) {
+	return async(a.Encrypt, data)
+}
+
+func (a *stdAlgorithm) DecryptAsync(data []byte) (<-chan []byte, <-chan error) {
+	return async(a.Decrypt, data)
+}
+
+func newDES() (Algorithm, error) {
+	return &stdAlgorithm{blockSize: des.BlockSize, keySizes: []int{8}, newCipher: des.NewCipher}, nil
+}
+
+func newAES() (Algorithm, error) {
+	return &stdAlgorithm{blockSize: aes.BlockSize, keySizes: []int{16, 24, 32}, newCipher: aes.NewCipher}, nil
+}
+
+// recorder собирает сообщения о нарушениях
+type recorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) contains(substr string) bool {
+	for _, m := range r.messages {
+		if strings.Contains(m, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStdlibCiphersConform(t *testing.T) {
+	CheckSymmetricAlgorithm(t, "DES", newDES)
+	CheckSymmetricAlgorithm(t, "AES", newAES)
+}
+
+func TestKeyedInstance(t *testing.T) {
+	newKeyed := func(key []byte) (Block, error) {
+		alg, _ := newAES()
+		return alg, alg.SetKey(key)
+	}
+	CheckKeyedInstance(t, "AES", newAES, newKeyed, bytes.Repeat([]byte{1}, 16))
+
+	// Экземпляр, хранящий ссылку на ключ вызывающей стороны
+	aliasing := func(key []byte) (Block, error) {
+		return aliasingBlock{key: key}, nil
+	}
+	r := &recorder{}
+	CheckKeyedInstance(r, "AES", newAES, aliasing, bytes.Repeat([]byte{1}, 16))
+	if !r.contains("depends on the caller's key slice") {
+		t.Errorf("key aliasing was not reported: %q", r.messages)
+	}
+}
+
+type aliasingBlock struct{ key []byte }
+
+func (b aliasingBlock) block() cipher.Block {
+	block, _ := aes.NewCipher(b.key)
+	return block
+}
+
+func (b aliasingBlock) Encrypt(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	b.block().Encrypt(out, data)
+	return out, nil
+}
+
+func (b aliasingBlock) Decrypt(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	b.block().Decrypt(out, data)
+	return out, nil
+}
+
+// panickingAlgorithm паникует вместо отказа от неверного ключа и блока
+type panickingAlgorithm struct {
+	stdAlgorithm
+}
+
+func (a *panickingAlgorithm) SetKey(key []byte) error {
+	if len(key) != 8 {
+		panic("bad key")
+	}
+	return a.stdAlgorithm.SetKey(key)
+}
+
+func (a *panickingAlgorithm) Encrypt(data []byte) ([]byte, error) {
+	if len(data) != 8 {
+		panic("bad block")
+	}
+	return a.stdAlgorithm.Encrypt(data)
+}
+
+// mutatingAlgorithm портит входной блок
+type mutatingAlgorithm struct {
+	stdAlgorithm
+}
+
+func (a *mutatingAlgorithm) Encrypt(data []byte) ([]byte, error) {
+	out, err := a.stdAlgorithm.Encrypt(data)
+	if err == nil {
+		data[0] ^= 0xFF
+	}
+	return out, err
+}
+
+func TestBrokenAlgorithmsAreReported(t *testing.T) {
+	tests := []struct {
+		name   string
+		newAlg func() (Algorithm, error)
+		want   []string
+	}{
+		{
+			name: "panicking",
+			newAlg: func() (Algorithm, error) {
+				alg, _ := newDES()
+				return &panickingAlgorithm{*alg.(*stdAlgorithm)}, nil
+			},
+			// Паника не засчитывается как отказ
+			want: []string{"SetKey with a 0-byte key panicked", "Encrypt of a 0-byte block panicked"},
+		},
+		{
+			name: "mutating",
+			newAlg: func() (Algorithm, error) {
+				alg, _ := newDES()
+				return &mutatingAlgorithm{*alg.(*stdAlgorithm)}, nil
+			},
+			want: []string{"Encrypt modified its input"},
+		},
+	}
+	for _, tt := range tests {
+		r := &recorder{}
+		CheckSymmetricAlgorithm(r, tt.name, tt.newAlg)
+		for _, want := range tt.want {
+			if !r.contains(want) {
+				t.Errorf("%s: %q was not reported, got %q", tt.name, want, r.messages)
+			}
+		}
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	err := call(func() error { panic("boom") })
+	var p *PanicError
+	if !errors.As(err, &p) || p.Value != "boom" || len(p.Stack) == 0 {
+		t.Fatalf("call returned %#v", err)
+	}
+	if err := call(func() error { return errors.New("plain") }); isPanic(err) {
+		t.Errorf("plain error treated as a panic: %v", err)
+	}
+	// Строка с префиксом "panic:" - обычная ошибка
+	if err := call(func() error { return errors.New("panic: not really") }); isPanic(err) {
+		t.Errorf("error text treated as a panic: %v", err)
+	}
+}
+
+// pkcs7 - эталонная набивка для проверки CheckPadding
+type pkcs7 struct{ panicOnUnpad bool }
+
+func (pkcs7) Name() string { return "PKCS7" }
+
+func (pkcs7) Pad(data []byte, blockSize int) ([]byte, error) {
+	n := blockSize - len(data)%blockSize
+	return append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(n)}, n)...), nil
+}
+
+func (p pkcs7) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	n := int(data[len(data)-1])
+	if p.panicOnUnpad {
+		// Нет проверки n: срез выходит за границы на испорченных данных
+		return data[:len(data)-n], nil
+	}
+	if n == 0 || n > blockSize || !bytes.Equal(data[len(data)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("invalid padding")
+	}
+	return data[:len(data)-n], nil
+}
+
+func TestCheckPadding(t *testing.T) {
+	CheckPadding(t, pkcs7{}, 8)
+	CheckPadding(t, pkcs7{}, 16)
+
+	r := &recorder{}
+	CheckPadding(r, pkcs7{panicOnUnpad: true}, 8)
+	if !r.contains("panic:") {
+		t.Errorf("panicking Unpad was not reported: %q", r.messages)
+	}
+}
+
+// aliasingKeyRound - расширение ключа, возвращающее подсрезы входного ключа
+type aliasingKeyRound struct{}
+
+func (aliasingKeyRound) GenerateKeys(key []byte) ([][]byte, error) {
+	if len(key) != 16 {
+		return nil, errors.New("invalid key size")
+	}
+	return [][]byte{key[:8], key[8:]}, nil
+}
+
+type copyingKeyRound struct{}
+
+func (copyingKeyRound) GenerateKeys(key []byte) ([][]byte, error) {
+	if len(key) != 16 {
+		return nil, errors.New("invalid key size")
+	}
+	return [][]byte{append([]byte(nil), key[:8]...), append([]byte(nil), key[8:]...)}, nil
+}
+
+func TestCheckKeyRound(t *testing.T) {
+	CheckKeyRound(t, "copying", copyingKeyRound{}, []int{16})
+
+	r := &recorder{}
+	CheckKeyRound(r, "aliasing", aliasingKeyRound{}, []int{16})
+	if !r.contains("round keys alias the input key") {
+		t.Errorf("aliasing round keys were not reported: %q", r.messages)
+	}
+}
+
+// ownBlock - собственный интерфейс экземпляра, как BlockCipher в реализации
+type ownBlock interface {
+	Encrypt(data []byte) ([]byte, error)
+	Decrypt(data []byte) ([]byte, error)
+}
+
+// ownKeyedAES - AES, чей NewKeyed возвращает собственный тип интерфейса, а не Block
+type ownKeyedAES struct {
+	stdAlgorithm
+}
+
+func (a *ownKeyedAES) NewKeyed(key []byte) (ownBlock, error) {
+	return aliasingBlock{key: key}, nil
+}
+
+func TestKeyedConstructorBySignature(t *testing.T) {
+	newAlg := func() (Algorithm, error) {
+		alg, _ := newAES()
+		return &ownKeyedAES{*alg.(*stdAlgorithm)}, nil
+	}
+	probe, _ := newAlg()
+	if _, ok := keyedConstructor(probe); !ok {
+		t.Fatal("NewKeyed returning its own interface type was not recognized")
+	}
+	if _, ok := keyedConstructor(&stdAlgorithm{}); ok {
+		t.Error("algorithm without NewKeyed was treated as keyed")
+	}
+	r := &recorder{}
+	CheckSymmetricAlgorithm(r, "AES", newAlg)
+	if !r.contains("depends on the caller's key slice") {
+		t.Errorf("keyed instance check did not run: %q", r.messages)
+	}
+}
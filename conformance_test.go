@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"cryptolab/conformance"
+)
+
+// TestConformance прогоняет набор conformance на всех алгоритмах, расписаниях ключей,
+// раундовых функциях и набивках репозитория
+func TestConformance(t *testing.T) {
+	checks := ConformanceChecks()
+	for _, prefix := range []string{"Algorithm/", "KeyRound/", "CipherTransform/", "Padding/", "Context/"} {
+		found := false
+		for _, c := range checks {
+			found = found || strings.HasPrefix(c.Name, prefix)
+		}
+		if !found {
+			t.Errorf("no %s checks", strings.TrimSuffix(prefix, "/"))
+		}
+	}
+	for _, c := range checks {
+		t.Run(c.Name, func(t *testing.T) {
+			c.Run(t)
+		})
+	}
+}
+
+// conformanceRecorder собирает нарушения, чтобы проверить, что проверка действительно выполнялась
+type conformanceRecorder struct {
+	messages []string
+}
+
+func (r *conformanceRecorder) Errorf(format string, args ...any) {
+	r.messages = append(r.messages, format)
+}
+
+// aliasingDES - DES, чей NewKeyed хранит ссылку на ключ вызывающей стороны
+type aliasingDES struct {
+	*DES
+}
+
+func (a aliasingDES) NewKeyed(key []byte) (BlockCipher, error) {
+	return aliasingBlock{key: key}, nil
+}
+
+type aliasingBlock struct{ key []byte }
+
+func (b aliasingBlock) Encrypt(block []byte) ([]byte, error) {
+	des, err := newKeyedDES(b.key)
+	if err != nil {
+		return nil, err
+	}
+	return des.Encrypt(block)
+}
+
+func (b aliasingBlock) Decrypt(block []byte) ([]byte, error) {
+	des, err := newKeyedDES(b.key)
+	if err != nil {
+		return nil, err
+	}
+	return des.Decrypt(block)
+}
+
+// TestConformanceRunsKeyedChecks: NewKeyed, возвращающий BlockCipher, распознается набором,
+// и проверка экземпляров для ключа действительно выполняется
+func TestConformanceRunsKeyedChecks(t *testing.T) {
+	r := &conformanceRecorder{}
+	conformance.CheckSymmetricAlgorithm(r, "aliasing DES", func() (conformance.Algorithm, error) {
+		des, err := NewDES()
+		return aliasingDES{des}, err
+	})
+	found := false
+	for _, m := range r.messages {
+		found = found || strings.Contains(m, "depends on the caller's key slice")
+	}
+	if !found {
+		t.Errorf("NewKeyed returning BlockCipher was not checked: %q", r.messages)
+	}
+}
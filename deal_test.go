@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestContextIgnoresKeyMutation изменяет ключ вызывающей стороны после NewContext:
+// ни контекст, ни экземпляр в кэше расписаний не должны ссылаться на этот срез
+func TestContextIgnoresKeyMutation(t *testing.T) {
+	for _, name := range AlgorithmNames() {
+		t.Run(name, func(t *testing.T) {
+			spec, _ := LookupAlgorithm(name)
+			random := NewTestRand("keymutation/" + name)
+			for _, keySize := range spec.KeySizes {
+				key, err := GenerateKey(name, keySize, random)
+				if err != nil {
+					t.Fatal(err)
+				}
+				original := append([]byte(nil), key...)
+				block, err := randomBytes(random, spec.BlockSize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				alg, err := spec.New()
+				if err != nil {
+					t.Fatal(err)
+				}
+				cache, err := NewKeyScheduleCache(4)
+				if err != nil {
+					t.Fatal(err)
+				}
+				newContext := func(key []byte) *CryptoSymmetricContext {
+					ctx, err := NewContext(key, alg, WithMode(ECB), WithPadding(NoPadding), WithKeyScheduleCache(cache))
+					if err != nil {
+						t.Fatal(err)
+					}
+					return ctx
+				}
+
+				ctx := newContext(key)
+				want, err := ctx.Encrypt(block)
+				if err != nil {
+					t.Fatal(err)
+				}
+				key[0] ^= 0x80
+
+				got, err := ctx.Encrypt(block)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%d-byte key: ciphertext changed after the caller modified the key", keySize)
+				}
+				// Новый контекст с исходным ключом берет экземпляр из кэша расписаний
+				got, err = newContext(original).Encrypt(block)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%d-byte key: cached key schedule changed after the caller modified the key", keySize)
+				}
+			}
+		})
+	}
+}
+
+func TestDEALRoundKeysDoNotAliasKey(t *testing.T) {
+	for _, keySize := range []int{16, 24, 32} {
+		key := bytes.Repeat([]byte{0x5A}, keySize)
+		roundKeys, err := (&DEALKeySchedule{}).GenerateKeys(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := (&DEALKeySchedule{}).GenerateKeys(bytes.Repeat([]byte{0x5A}, keySize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range key {
+			key[i] = 0
+		}
+		for i := range roundKeys {
+			if !bytes.Equal(roundKeys[i], want[i]) {
+				t.Errorf("%d-byte key: round key %d = %x after the key was cleared, want %x", keySize, i+1, roundKeys[i], want[i])
+			}
+		}
+	}
+}
+
+func TestDEALRoundFunctionRejectsUnpreparedKey(t *testing.T) {
+	rf := NewDEALRoundFunction()
+	if err := rf.PrepareRoundKeys([][]byte{mustDecodeHex("133457799bbcdff1")}); err != nil {
+		t.Fatal(err)
+	}
+	block := mustDecodeHex("0123456789abcdef")
+	if _, err := rf.Encryption(block, mustDecodeHex("133457799bbcdff1")); err != nil {
+		t.Errorf("prepared round key: %v", err)
+	}
+	if _, err := rf.Encryption(block, mustDecodeHex("0e329232ea6d0d73")); err == nil {
+		t.Error("Encryption with a round key that was not prepared succeeded")
+	}
+	if _, err := rf.Decryption(block, mustDecodeHex("0e329232ea6d0d73")); err == nil {
+		t.Error("Decryption with a round key that was not prepared succeeded")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDESKeySchedule(t *testing.T) {
+	for _, v := range desKeyScheduleVectors {
+		roundKeys, err := (&DESKeySchedule{}).GenerateKeys(mustDecodeHex(v.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(roundKeys) != 16 {
+			t.Fatalf("key %s: %d round keys, want 16", v.key, len(roundKeys))
+		}
+		if got := hex.EncodeToString(roundKeys[0]); got != v.k1 {
+			t.Errorf("key %s: K1 = %s, want %s", v.key, got, v.k1)
+		}
+		if got := hex.EncodeToString(roundKeys[15]); got != v.k16 {
+			t.Errorf("key %s: K16 = %s, want %s", v.key, got, v.k16)
+		}
+	}
+}
+
+// TestDESMatchesStdlib сравнивает DES с crypto/des на случайных ключах и блоках;
+// зерно фиксировано, чтобы найденное расхождение воспроизводилось
+func TestDESMatchesStdlib(t *testing.T) {
+	iterations := 5000
+	if testing.Short() {
+		iterations = 500
+	}
+	for _, seed := range []int64{1, 2, 3} {
+		mismatch, err := DifferentialTestDES(rand.New(rand.NewSource(seed)), iterations)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if mismatch != nil {
+			var report strings.Builder
+			mismatch.Write(&report)
+			t.Fatalf("seed %d:\n%s", seed, report.String())
+		}
+	}
+}
+
+func TestCompareWithStdDESDetectsDifference(t *testing.T) {
+	key := mustDecodeHex("133457799bbcdff1")
+	block := mustDecodeHex("0123456789abcdef")
+	for _, decrypt := range []bool{false, true} {
+		if m := compareWithStdDES(key, block, decrypt); m != nil {
+			t.Fatalf("decrypt=%v: unexpected mismatch: got %x, want %x, err %v", decrypt, m.Got, m.Want, m.Err)
+		}
+	}
+	// Ключ неверной длины отвергают обе реализации, и это сообщается как расхождение с ошибкой
+	if m := compareWithStdDES(key[:7], block, false); m == nil || m.Err == nil {
+		t.Fatal("7-byte key was not reported")
+	}
+}
+
+func TestTraceDESCoversAllRounds(t *testing.T) {
+	key := mustDecodeHex("133457799bbcdff1")
+	block := mustDecodeHex("0123456789abcdef")
+	rounds := traceDES(key, block)
+	if len(rounds) != 16 {
+		t.Fatalf("traced %d rounds, want 16", len(rounds))
+	}
+	roundKeys, err := (&DESKeySchedule{}).GenerateKeys(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range rounds {
+		if r.Round != i+1 {
+			t.Errorf("trace %d has round number %d", i, r.Round)
+		}
+		if !bytes.Equal(r.RoundKey, roundKeys[i]) {
+			t.Errorf("round %d key = %x, want %x", r.Round, r.RoundKey, roundKeys[i])
+		}
+		if len(r.Left) != 4 || len(r.Right) != 4 {
+			t.Errorf("round %d halves are %d and %d bytes, want 4", r.Round, len(r.Left), len(r.Right))
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// Дифференциальная проверка DES: результаты нашей реализации сравниваются
+// с crypto/des на случайных ключах и блоках, а найденное расхождение
+// сокращается до минимальной пары ключ/блок с трассировкой по раундам.
+
+// DESRoundTrace - состояние после одного раунда сети Фейстеля
+type DESRoundTrace struct {
+	Round    int
+	RoundKey []byte
+	Left     []byte
+	Right    []byte
+}
+
+// DESMismatch - расхождение с crypto/des
+type DESMismatch struct {
+	Key     []byte
+	Block   []byte
+	Decrypt bool
+	Got     []byte
+	Want    []byte
+	// Rounds - промежуточные значения нашей реализации при шифровании Block
+	Rounds []DESRoundTrace
+	Err    error
+}
+
+// Write печатает расхождение в виде, удобном для отладки
+func (m *DESMismatch) Write(w io.Writer) {
+	op := "encrypt"
+	if m.Decrypt {
+		op = "decrypt"
+	}
+	fmt.Fprintf(w, "DES %s mismatch\n  key:   %x\n  block: %x\n", op, m.Key, m.Block)
+	if m.Err != nil {
+		fmt.Fprintf(w, "  error: %v\n", m.Err)
+	} else {
+		fmt.Fprintf(w, "  got:   %x\n  want:  %x\n", m.Got, m.Want)
+	}
+	for _, r := range m.Rounds {
+		fmt.Fprintf(w, "  round %2d: K=%x L=%x R=%x\n", r.Round, r.RoundKey, r.Left, r.Right)
+	}
+}
+
+// desKeyScheduleVectors - раундовые ключи K1 и K16 для ключа 133457799BBCDFF1
+// из разбора DES Дж. Грабба (J. Orlin Grabbe, "The DES Algorithm Illustrated")
+var desKeyScheduleVectors = []struct {
+	key, k1, k16 string
+}{
+	{"133457799bbcdff1", "1b02effc7072", "cb3d8b0e17f5"},
+}
+
+// DifferentialTestDES выполняет iterations случайных сравнений с crypto/des.
+// Возвращает nil, если расхождений нет, иначе минимизированное расхождение.
+func DifferentialTestDES(rng *rand.Rand, iterations int) (*DESMismatch, error) {
+	for _, v := range desKeyScheduleVectors {
+		key, _ := hex.DecodeString(v.key)
+		roundKeys, err := (&DESKeySchedule{}).GenerateKeys(key)
+		if err != nil {
+			return nil, err
+		}
+		if got := hex.EncodeToString(roundKeys[0]); got != v.k1 {
+			return nil, fmt.Errorf("key schedule for %s: K1 = %s, want %s", v.key, got, v.k1)
+		}
+		if got := hex.EncodeToString(roundKeys[15]); got != v.k16 {
+			return nil, fmt.Errorf("key schedule for %s: K16 = %s, want %s", v.key, got, v.k16)
+		}
+	}
+
+	key := make([]byte, 8)
+	block := make([]byte, 8)
+	for i := 0; i < iterations; i++ {
+		rng.Read(key)
+		rng.Read(block)
+		for _, decrypt := range []bool{false, true} {
+			if m := compareWithStdDES(key, block, decrypt); m != nil {
+				return shrinkDESMismatch(m), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// compareWithStdDES сравнивает одну операцию; nil - результаты совпали
+func compareWithStdDES(key, block []byte, decrypt bool) *DESMismatch {
+	m := &DESMismatch{
+		Key:     append([]byte(nil), key...),
+		Block:   append([]byte(nil), block...),
+		Decrypt: decrypt,
+		Want:    make([]byte, 8),
+	}
+
+	reference, err := des.NewCipher(key)
+	if err != nil {
+		m.Err = err
+		return m
+	}
+	ours, err := NewDES()
+	if err == nil {
+		err = ours.SetKey(m.Key)
+	}
+	if err != nil {
+		m.Err = err
+		return m
+	}
+
+	input := append([]byte(nil), block...)
+	if decrypt {
+		reference.Decrypt(m.Want, block)
+		m.Got, err = ours.Decrypt(input)
+	} else {
+		reference.Encrypt(m.Want, block)
+		m.Got, err = ours.Encrypt(input)
+	}
+	if err != nil {
+		m.Err = err
+		return m
+	}
+	if !bytes.Equal(input, block) {
+		m.Err = fmt.Errorf("input block was modified: %x -> %x", block, input)
+		return m
+	}
+	if bytes.Equal(m.Got, m.Want) {
+		return nil
+	}
+	return m
+}
+
+// shrinkDESMismatch жадно сбрасывает биты ключа и блока, пока расхождение сохраняется,
+// и добавляет трассировку раундов для полученной пары
+func shrinkDESMismatch(m *DESMismatch) *DESMismatch {
+	for changed := true; changed; {
+		changed = false
+		for _, buf := range [][]byte{m.Key, m.Block} {
+			for bit := 0; bit < len(buf)*8; bit++ {
+				mask := byte(0x80 >> (bit % 8))
+				if buf[bit/8]&mask == 0 {
+					continue
+				}
+				buf[bit/8] &^= mask
+				if smaller := compareWithStdDES(m.Key, m.Block, m.Decrypt); smaller != nil {
+					m.Got, m.Want, m.Err = smaller.Got, smaller.Want, smaller.Err
+					changed = true
+				} else {
+					buf[bit/8] |= mask
+				}
+			}
+		}
+	}
+	m.Rounds = traceDES(m.Key, m.Block)
+	return m
+}
+
+// traceDES шифрует блок отдельным экземпляром DES и собирает состояние после каждого раунда
+func traceDES(key, block []byte) []DESRoundTrace {
+	d, err := NewDES()
+	if err != nil || d.SetKey(key) != nil {
+		return nil
+	}
+	var rounds []DESRoundTrace
+	d.feistel.trace = func(round int, roundKey, left, right []byte) {
+		rounds = append(rounds, DESRoundTrace{
+			Round:    round,
+			RoundKey: append([]byte(nil), roundKey...),
+			Left:     append([]byte(nil), left...),
+			Right:    append([]byte(nil), right...),
+		})
+	}
+	d.Encrypt(append([]byte(nil), block...))
+	return rounds
+}
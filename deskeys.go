@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	mathbits "math/bits"
+)
+
+// Проверка ключей DES: биты четности и слабые ключи.
+//
+// Слабость ключа определяется половинами C и D после PC-1: если каждая из них
+// повторяет короткий шаблон, циклические сдвиги расписания дают лишь несколько
+// разных раундовых ключей. Для слабых ключей обе половины постоянны (все раундовые
+// ключи равны, шифрование совпадает с дешифрованием), для полуслабых повторяют
+// шаблон с периодом 2, для возможно слабых - один из шаблонов с периодом 4
+// (0011 и его сдвиги). Биты четности в PC-1 не входят и на класс ключа не влияют.
+
+// DESKeyClass - класс ключа DES
+type DESKeyClass int
+
+const (
+	DESKeyNormal DESKeyClass = iota
+	DESKeyPossiblyWeak
+	DESKeySemiWeak
+	DESKeyWeak
+)
+
+func (c DESKeyClass) String() string {
+	switch c {
+	case DESKeyNormal:
+		return "normal"
+	case DESKeyPossiblyWeak:
+		return "possibly weak"
+	case DESKeySemiWeak:
+		return "semi-weak"
+	case DESKeyWeak:
+		return "weak"
+	default:
+		return fmt.Sprintf("DESKeyClass(%d)", int(c))
+	}
+}
+
+var (
+	// ErrDESKeyParity возвращается при RequireOddParity для ключа с неверной четностью
+	ErrDESKeyParity = errors.New("DES key parity is not odd")
+	// ErrWeakDESKey возвращается для ключа, запрещенного политикой
+	ErrWeakDESKey = errors.New("weak DES key")
+)
+
+// DESKeyPolicy - какие ключи DESKeySchedule отвергает.
+// Нулевое значение принимает любые ключи, как и раньше.
+type DESKeyPolicy struct {
+	// RequireOddParity требует нечетной четности в каждом байте
+	RequireOddParity bool
+	// RejectWeak отвергает слабые и полуслабые ключи
+	RejectWeak bool
+	// RejectPossiblyWeak дополнительно отвергает возможно слабые ключи
+	RejectPossiblyWeak bool
+}
+
+// Check проверяет ключ по политике
+func (p DESKeyPolicy) Check(key []byte) error {
+	if p.RequireOddParity && !DESKeyParityOK(key) {
+		return ErrDESKeyParity
+	}
+	if !p.RejectWeak && !p.RejectPossiblyWeak {
+		return nil
+	}
+	class, err := ClassifyDESKey(key)
+	if err != nil {
+		return err
+	}
+	if class == DESKeyWeak || class == DESKeySemiWeak || (p.RejectPossiblyWeak && class == DESKeyPossiblyWeak) {
+		return fmt.Errorf("%w: key is %s", ErrWeakDESKey, class)
+	}
+	return nil
+}
+
+// CheckAlgorithmKey применяет политику к ключу DES или к раундовым ключам DEAL,
+// которые DEAL использует как ключи DES; ключи прочих алгоритмов не проверяются
+func (p DESKeyPolicy) CheckAlgorithmKey(algorithm string, key []byte) error {
+	switch algorithm {
+	case "DES":
+		return p.Check(key)
+	case "DEAL":
+		roundKeys, err := (&DEALKeySchedule{}).GenerateKeys(key)
+		if err != nil {
+			return err
+		}
+		for i, roundKey := range roundKeys {
+			if err := p.Check(roundKey); err != nil {
+				return fmt.Errorf("DEAL round key %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DESKeyParityOK сообщает, что в каждом байте ключа нечетное число единиц
+func DESKeyParityOK(key []byte) bool {
+	for _, b := range key {
+		if mathbits.OnesCount8(b)%2 == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FixDESKeyParity возвращает копию ключа, в которой младший бит каждого байта
+// выставлен для нечетной четности
+func FixDESKeyParity(key []byte) []byte {
+	fixed := make([]byte, len(key))
+	for i, b := range key {
+		fixed[i] = b &^ 1
+		if mathbits.OnesCount8(fixed[i])%2 == 0 {
+			fixed[i] |= 1
+		}
+	}
+	return fixed
+}
+
+// Шаблоны половин C и D: период 1, затем период 2, затем сдвиги 0011
+var (
+	desWeakHalfPatterns         = []int{0x0, 0xF}
+	desSemiWeakHalfPatterns     = []int{0x0, 0xF, 0x5, 0xA}
+	desPossiblyWeakHalfPatterns = []int{0x0, 0xF, 0x5, 0xA, 0x3, 0x6, 0xC, 0x9}
+)
+
+// ClassifyDESKey определяет класс 8-байтового ключа DES
+func ClassifyDESKey(key []byte) (DESKeyClass, error) {
+	if len(key) != 8 {
+		return DESKeyNormal, &KeySizeError{Algorithm: "DES", Size: len(key), Valid: []int{8}}
+	}
+	c, d, err := desKeyHalves(key)
+	if err != nil {
+		return DESKeyNormal, err
+	}
+	switch {
+	case desHalfMatches(c, desWeakHalfPatterns) && desHalfMatches(d, desWeakHalfPatterns):
+		return DESKeyWeak, nil
+	case desHalfMatches(c, desSemiWeakHalfPatterns) && desHalfMatches(d, desSemiWeakHalfPatterns):
+		return DESKeySemiWeak, nil
+	case desHalfMatches(c, desPossiblyWeakHalfPatterns) && desHalfMatches(d, desPossiblyWeakHalfPatterns):
+		return DESKeyPossiblyWeak, nil
+	}
+	return DESKeyNormal, nil
+}
+
+// desHalfMatches сообщает, что 28-битная половина повторяет один из 4-битных шаблонов
+func desHalfMatches(half []int, patterns []int) bool {
+	for _, pattern := range patterns {
+		if desHalfIsPattern(half, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func desHalfIsPattern(half []int, pattern int) bool {
+	for i, bit := range half {
+		if bit != (pattern>>(3-i%4))&1 {
+			return false
+		}
+	}
+	return true
+}
+
+// desKeyFromHalves строит ключ с нечетной четностью по половинам C и D (обратная PC-1)
+func desKeyFromHalves(c, d []int) []byte {
+	key := make([]byte, 8)
+	for i, bit := range append(append([]int{}, c...), d...) {
+		if bit != 0 {
+			pos := desPC1[i] - 1
+			key[pos/8] |= 0x80 >> (pos % 8)
+		}
+	}
+	return FixDESKeyParity(key)
+}
+
+// DESKeysOfClass перечисляет все ключи класса (с нечетной четностью):
+// 4 слабых, 12 полуслабых или 48 возможно слабых
+func DESKeysOfClass(class DESKeyClass) [][]byte {
+	if class == DESKeyNormal {
+		return nil
+	}
+	var keys [][]byte
+	for _, cp := range desPossiblyWeakHalfPatterns {
+		for _, dp := range desPossiblyWeakHalfPatterns {
+			key := desKeyFromHalves(desHalfFromPattern(cp), desHalfFromPattern(dp))
+			if got, _ := ClassifyDESKey(key); got == class {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+func desHalfFromPattern(pattern int) []int {
+	half := make([]int, 28)
+	for i := range half {
+		half[i] = (pattern >> (3 - i%4)) & 1
+	}
+	return half
+}
+
+// DESKeyReport - результат проверки одного ключа DES
+type DESKeyReport struct {
+	Label    string
+	Key      []byte
+	ParityOK bool
+	Class    DESKeyClass
+}
+
+// CheckDESKey проверяет один ключ DES
+func CheckDESKey(label string, key []byte) (DESKeyReport, error) {
+	class, err := ClassifyDESKey(key)
+	if err != nil {
+		return DESKeyReport{}, fmt.Errorf("%s: %w", label, err)
+	}
+	return DESKeyReport{Label: label, Key: key, ParityOK: DESKeyParityOK(key), Class: class}, nil
+}
+
+// CheckKeyForAlgorithms проверяет ключ как ключ DES (8 байт), как набор ключей 3DES
+// (16 или 24 байта) и через раундовые ключи DEAL (16, 24 или 32 байта).
+// Раундовые ключи DEAL используются внутри как ключи DES, поэтому проверяются так же.
+func CheckKeyForAlgorithms(key []byte) (map[string][]DESKeyReport, []string, error) {
+	reports := make(map[string][]DESKeyReport)
+	var warnings []string
+
+	add := func(alg, label string, k []byte) error {
+		report, err := CheckDESKey(label, k)
+		if err != nil {
+			return err
+		}
+		reports[alg] = append(reports[alg], report)
+		return nil
+	}
+
+	switch len(key) {
+	case 8:
+		if err := add("DES", "K", key); err != nil {
+			return nil, nil, err
+		}
+	case 16, 24:
+		for i := 0; i < len(key)/8; i++ {
+			if err := add("3DES", fmt.Sprintf("K%d", i+1), key[i*8:(i+1)*8]); err != nil {
+				return nil, nil, err
+			}
+		}
+		// Совпадающие соседние ключи сводят EDE к одинарному DES
+		k1, k2 := FixDESKeyParity(key[:8]), FixDESKeyParity(key[8:16])
+		k3 := k1
+		if len(key) == 24 {
+			k3 = FixDESKeyParity(key[16:])
+		}
+		if string(k1) == string(k2) || string(k2) == string(k3) {
+			warnings = append(warnings, "3DES: adjacent keys are equal, EDE degenerates to single DES")
+		}
+	}
+
+	if len(key) == 16 || len(key) == 24 || len(key) == 32 {
+		roundKeys, err := (&DEALKeySchedule{}).GenerateKeys(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, rk := range roundKeys {
+			if err := add("DEAL", fmt.Sprintf("RK%d", i+1), rk); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if len(reports) == 0 {
+		return nil, nil, fmt.Errorf("unsupported key size %d: expected 8 (DES), 16/24 (3DES, DEAL) or 32 (DEAL)", len(key))
+	}
+	return reports, warnings, nil
+}
+
+// WriteDESKeyReports печатает отчет; возвращает true, если найден хотя бы один слабый ключ
+func WriteDESKeyReports(w io.Writer, reports map[string][]DESKeyReport, warnings []string) bool {
+	weak := false
+	for _, alg := range []string{"DES", "3DES", "DEAL"} {
+		if len(reports[alg]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", alg)
+		for _, r := range reports[alg] {
+			parity := "ok"
+			if !r.ParityOK {
+				parity = "bad"
+			}
+			fmt.Fprintf(w, "  %-4s %x  parity %-3s  %s\n", r.Label, r.Key, parity, r.Class)
+			if r.Class != DESKeyNormal {
+				weak = true
+			}
+		}
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+		weak = true
+	}
+	return weak
+}
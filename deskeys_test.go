@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestClassifyDESKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want DESKeyClass
+	}{
+		// Слабые ключи
+		{"0101010101010101", DESKeyWeak},
+		{"fefefefefefefefe", DESKeyWeak},
+		{"e0e0e0e0f1f1f1f1", DESKeyWeak},
+		{"1f1f1f1f0e0e0e0e", DESKeyWeak},
+		// Биты четности на класс не влияют
+		{"0000000000000000", DESKeyWeak},
+		{"ffffffffffffffff", DESKeyWeak},
+		// Полуслабые пары
+		{"01fe01fe01fe01fe", DESKeySemiWeak},
+		{"fe01fe01fe01fe01", DESKeySemiWeak},
+		{"1fe01fe00ef10ef1", DESKeySemiWeak},
+		{"e01fe01ff10ef10e", DESKeySemiWeak},
+		{"01e001e001f101f1", DESKeySemiWeak},
+		{"e001e001f101f101", DESKeySemiWeak},
+		{"1ffe1ffe0efe0efe", DESKeySemiWeak},
+		{"fe1ffe1ffe0efe0e", DESKeySemiWeak},
+		{"011f011f010e010e", DESKeySemiWeak},
+		{"1f011f010e010e01", DESKeySemiWeak},
+		{"e0fee0fef1fef1fe", DESKeySemiWeak},
+		{"fee0fee0fef1fef1", DESKeySemiWeak},
+		// Возможно слабые
+		{"1f1f01010e0e0101", DESKeyPossiblyWeak},
+		{"01011f1f01010e0e", DESKeyPossiblyWeak},
+		{"e0e01f1ff1f10e0e", DESKeyPossiblyWeak},
+		// Обычные ключи
+		{"133457799bbcdff1", DESKeyNormal},
+		{"0e329232ea6d0d73", DESKeyNormal},
+	}
+	for _, tt := range tests {
+		got, err := ClassifyDESKey(mustDecodeHex(tt.key))
+		if err != nil {
+			t.Errorf("ClassifyDESKey(%s): %v", tt.key, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ClassifyDESKey(%s) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+
+	for _, size := range []int{0, 7, 9, 16} {
+		var sizeErr *KeySizeError
+		if _, err := ClassifyDESKey(make([]byte, size)); !errors.As(err, &sizeErr) {
+			t.Errorf("ClassifyDESKey(%d bytes) error = %v, want *KeySizeError", size, err)
+		}
+	}
+}
+
+func TestDESKeysOfClass(t *testing.T) {
+	tests := []struct {
+		class DESKeyClass
+		count int
+	}{
+		{DESKeyWeak, 4},
+		{DESKeySemiWeak, 12},
+		{DESKeyPossiblyWeak, 48},
+		{DESKeyNormal, 0},
+	}
+	for _, tt := range tests {
+		keys := DESKeysOfClass(tt.class)
+		if len(keys) != tt.count {
+			t.Errorf("DESKeysOfClass(%s) returned %d keys, want %d", tt.class, len(keys), tt.count)
+		}
+		seen := make(map[string]bool)
+		for _, key := range keys {
+			if seen[string(key)] {
+				t.Errorf("%s: key %x listed twice", tt.class, key)
+			}
+			seen[string(key)] = true
+			if !DESKeyParityOK(key) {
+				t.Errorf("%s: key %x does not have odd parity", tt.class, key)
+			}
+			if got, err := ClassifyDESKey(key); err != nil || got != tt.class {
+				t.Errorf("%s: key %x classified as %s (%v)", tt.class, key, got, err)
+			}
+		}
+	}
+}
+
+func TestFixDESKeyParity(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"0000000000000000", "0101010101010101"},
+		{"ffffffffffffffff", "fefefefefefefefe"},
+		{"133457799bbcdff1", "133457799bbcdff1"}, // уже нечетная четность
+		{"123456789abcdef0", "133457799bbcdff1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		key := mustDecodeHex(tt.key)
+		original := append([]byte(nil), key...)
+		got := FixDESKeyParity(key)
+		if want := mustDecodeHex(tt.want); !bytes.Equal(got, want) {
+			t.Errorf("FixDESKeyParity(%s) = %x, want %s", tt.key, got, tt.want)
+		}
+		if !DESKeyParityOK(got) {
+			t.Errorf("FixDESKeyParity(%s) = %x has even parity bytes", tt.key, got)
+		}
+		if !bytes.Equal(key, original) {
+			t.Errorf("FixDESKeyParity(%s) modified its argument", tt.key)
+		}
+	}
+}
+
+func TestDESKeyPolicyCheck(t *testing.T) {
+	const (
+		normal       = "133457799bbcdff1"
+		badParity    = "123456789abcdef0"
+		weak         = "0101010101010101"
+		semiWeak     = "01fe01fe01fe01fe"
+		possiblyWeak = "1f1f01010e0e0101"
+	)
+	tests := []struct {
+		name   string
+		policy DESKeyPolicy
+		key    string
+		want   error
+	}{
+		{"zero policy accepts weak keys", DESKeyPolicy{}, weak, nil},
+		{"zero policy accepts bad parity", DESKeyPolicy{}, badParity, nil},
+		{"parity accepted", DESKeyPolicy{RequireOddParity: true}, normal, nil},
+		{"parity rejected", DESKeyPolicy{RequireOddParity: true}, badParity, ErrDESKeyParity},
+		{"reject weak: normal key", DESKeyPolicy{RejectWeak: true}, normal, nil},
+		{"reject weak: weak key", DESKeyPolicy{RejectWeak: true}, weak, ErrWeakDESKey},
+		{"reject weak: semi-weak key", DESKeyPolicy{RejectWeak: true}, semiWeak, ErrWeakDESKey},
+		{"reject weak: possibly weak key", DESKeyPolicy{RejectWeak: true}, possiblyWeak, nil},
+		{"reject possibly weak: possibly weak key", DESKeyPolicy{RejectPossiblyWeak: true}, possiblyWeak, ErrWeakDESKey},
+		{"reject possibly weak: weak key", DESKeyPolicy{RejectPossiblyWeak: true}, weak, ErrWeakDESKey},
+		{"reject weak ignores parity", DESKeyPolicy{RejectWeak: true}, badParity, nil},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Check(mustDecodeHex(tt.key))
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: Check(%s) = %v, want nil", tt.name, tt.key, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Check(%s) = %v, want %v", tt.name, tt.key, err, tt.want)
+		}
+	}
+
+	var sizeErr *KeySizeError
+	if err := (DESKeyPolicy{RejectWeak: true}).Check(make([]byte, 7)); !errors.As(err, &sizeErr) {
+		t.Errorf("Check(7 bytes) error = %v, want *KeySizeError", err)
+	}
+}
+
+// TestNewDESWithKeyPolicyRejectsWeakKey: политика применяется в SetKey, в NewKeyed и в NewContext
+func TestNewDESWithKeyPolicyRejectsWeakKey(t *testing.T) {
+	des, err := NewDESWithKeyPolicy(DESKeyPolicy{RejectWeak: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"0101010101010101", "fe01fe01fe01fe01"} {
+		if err := des.SetKey(mustDecodeHex(key)); !errors.Is(err, ErrWeakDESKey) {
+			t.Errorf("SetKey(%s) error = %v, want ErrWeakDESKey", key, err)
+		}
+		if _, err := des.NewKeyed(mustDecodeHex(key)); !errors.Is(err, ErrWeakDESKey) {
+			t.Errorf("NewKeyed(%s) error = %v, want ErrWeakDESKey", key, err)
+		}
+		if _, err := NewContext(mustDecodeHex(key), des, WithMode(ECB), WithPadding(PKCS7)); !errors.Is(err, ErrWeakDESKey) {
+			t.Errorf("NewContext(%s) error = %v, want ErrWeakDESKey", key, err)
+		}
+	}
+	if err := des.SetKey(mustDecodeHex("133457799bbcdff1")); err != nil {
+		t.Errorf("SetKey of a normal key: %v", err)
+	}
+
+	// Без политики слабый ключ принимается, как и раньше
+	plain, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.SetKey(mustDecodeHex("0101010101010101")); err != nil {
+		t.Errorf("NewDES().SetKey of a weak key: %v", err)
+	}
+}
+
+func TestCheckKeyForAlgorithms(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		counts   map[string]int // число отчетов по алгоритмам
+		weak     bool
+		warnings int
+	}{
+		{"DES normal", "133457799bbcdff1", map[string]int{"DES": 1}, false, 0},
+		{"DES weak", "fefefefefefefefe", map[string]int{"DES": 1}, true, 0},
+		{"two-key 3DES", "133457799bbcdff10e329232ea6d0d73", map[string]int{"3DES": 2, "DEAL": 6}, false, 0},
+		{"two-key 3DES with equal keys", "133457799bbcdff1133457799bbcdff1", map[string]int{"3DES": 2, "DEAL": 6}, true, 1},
+		{"equal keys up to parity", "133457799bbcdff1123456789abcdef0", map[string]int{"3DES": 2, "DEAL": 6}, true, 1},
+		{"three-key 3DES", "133457799bbcdff10e329232ea6d0d73a1b2c3d4e5f60718", map[string]int{"3DES": 3, "DEAL": 8}, false, 0},
+		{"DEAL-256", "133457799bbcdff10e329232ea6d0d73a1b2c3d4e5f607188070605040302010", map[string]int{"DEAL": 12}, false, 0},
+		{"DEAL-256 with a weak round key", "133457799bbcdff10e329232ea6d0d73a1b2c3d4e5f607180101010101010101", map[string]int{"DEAL": 12}, true, 0},
+	}
+	for _, tt := range tests {
+		reports, warnings, err := CheckKeyForAlgorithms(mustDecodeHex(tt.key))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(reports) != len(tt.counts) {
+			t.Errorf("%s: reports for %d algorithms, want %d", tt.name, len(reports), len(tt.counts))
+		}
+		for alg, count := range tt.counts {
+			if len(reports[alg]) != count {
+				t.Errorf("%s: %d %s reports, want %d", tt.name, len(reports[alg]), alg, count)
+			}
+		}
+		if len(warnings) != tt.warnings {
+			t.Errorf("%s: warnings %q, want %d", tt.name, warnings, tt.warnings)
+		}
+		if got := hasWeakDESKey(reports, warnings); got != tt.weak {
+			t.Errorf("%s: weak = %v, want %v", tt.name, got, tt.weak)
+		}
+	}
+
+	for _, size := range []int{0, 7, 12, 40} {
+		if _, _, err := CheckKeyForAlgorithms(make([]byte, size)); err == nil {
+			t.Errorf("CheckKeyForAlgorithms accepted a %d-byte key", size)
+		}
+	}
+}
+
+func TestCheckAlgorithmKey(t *testing.T) {
+	policy := DESKeyPolicy{RejectWeak: true}
+	tests := []struct {
+		algorithm string
+		key       string
+		weak      bool
+	}{
+		{"DES", "133457799bbcdff1", false},
+		{"DES", "01fe01fe01fe01fe", true},
+		{"DEAL", "133457799bbcdff10e329232ea6d0d73", false},
+		{"DEAL", "0101010101010101fefefefefefefefe", true},
+		{"AES", "01010101010101010101010101010101", false}, // не алгоритм на основе DES
+	}
+	for _, tt := range tests {
+		err := policy.CheckAlgorithmKey(tt.algorithm, mustDecodeHex(tt.key))
+		if tt.weak != errors.Is(err, ErrWeakDESKey) || (!tt.weak && err != nil) {
+			t.Errorf("CheckAlgorithmKey(%s, %s) = %v, weak = %v", tt.algorithm, tt.key, err, tt.weak)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Шифрование дерева каталогов. Каждый файл шифруется отдельно со своим
+// случайным IV, записанным перед шифротекстом: один IV на все файлы дерева
+// раскрыл бы совпадающие начала файлов (CBC) или сам открытый текст (CTR, OFB).
+// Рядом с файлами записывается зашифрованный манифест с путями, правами,
+// размерами и хешами SHA-256, по которому дерево проверяется и восстанавливается.
+
+// ManifestFileName - имя файла манифеста в зашифрованном каталоге.
+// Зашифрованные файлы всегда имеют суффикс .enc, поэтому с файлами имена не пересекаются;
+// каталог с таким именем отвергает checkStoredPaths.
+const ManifestFileName = ".cryptolab-manifest"
+
+// ErrManifestMismatch - расшифрованный файл не совпадает с манифестом по размеру или хешу
+var ErrManifestMismatch = errors.New("content does not match manifest")
+
+const (
+	encryptedFileSuffix = ".enc"
+	manifestVersion     = 1
+)
+
+// ManifestEntry - файл или каталог дерева
+type ManifestEntry struct {
+	// Path - путь относительно корня через "/"
+	Path string `json:"path"`
+	Dir  bool   `json:"dir,omitempty"`
+	// Stored - путь зашифрованного файла относительно корня зашифрованного каталога;
+	// пуст для каталогов и пустых файлов, которые не шифруются
+	Stored string      `json:"stored,omitempty"`
+	Mode   fs.FileMode `json:"mode"`
+	Size   int64       `json:"size,omitempty"`
+	SHA256 string      `json:"sha256,omitempty"`
+}
+
+// Manifest описывает зашифрованное дерево
+type Manifest struct {
+	Version        int             `json:"version"`
+	Mode           string          `json:"mode"`
+	Padding        string          `json:"padding"`
+	EncryptedNames bool            `json:"encrypted_names"`
+	Entries        []ManifestEntry `json:"entries"`
+}
+
+// DirOptions - параметры шифрования дерева
+type DirOptions struct {
+	// EncryptNames заменяет имена файлов случайными, а структуру каталогов - плоским списком;
+	// настоящие пути хранятся только в зашифрованном манифесте
+	EncryptNames bool
+	// Workers - число файлов, обрабатываемых параллельно; 0 - runtime.GOMAXPROCS(0)
+	Workers int
+	// Overwrite разрешает заменять существующие файлы в каталоге назначения
+	Overwrite bool
+}
+
+// EncryptDir шифрует дерево srcDir в каталог dstDir и возвращает манифест.
+// Поддерживаются только обычные файлы и каталоги.
+func (cstc *CryptoSymmetricContext) EncryptDir(srcDir, dstDir string, opts DirOptions) (*Manifest, error) {
+	if err := checkDirsDisjoint(srcDir, dstDir); err != nil {
+		return nil, err
+	}
+	manifest, err := cstc.scanDir(srcDir, opts.EncryptNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dstDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	for _, e := range manifest.Entries {
+		if !e.Dir && strings.Contains(e.Stored, "/") {
+			if err := os.MkdirAll(filepath.Join(dstDir, filepath.Dir(filepath.FromSlash(e.Stored))), 0o755); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var written writtenFiles
+	err = runDirJobs(manifest.Entries, opts.Workers, func(e *ManifestEntry) error {
+		data, err := os.ReadFile(filepath.Join(srcDir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		e.Size, e.SHA256 = int64(len(data)), hex.EncodeToString(sum[:])
+		if len(data) == 0 {
+			e.Stored = ""
+			return nil
+		}
+
+		encrypted, err := cstc.encryptWithFreshIV(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Path, err)
+		}
+		return written.write(filepath.Join(dstDir, filepath.FromSlash(e.Stored)), encrypted, 0o644, opts.Overwrite)
+	})
+	if err == nil {
+		err = cstc.writeManifest(dstDir, manifest, opts.Overwrite)
+	}
+	if err != nil {
+		written.removeAll()
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// DecryptDir восстанавливает дерево из encDir в dstDir, проверяя размеры и хеши
+func (cstc *CryptoSymmetricContext) DecryptDir(encDir, dstDir string, opts DirOptions) (*Manifest, error) {
+	manifest, err := cstc.ReadManifest(encDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Каталоги создаются с правами владельца на запись, а итоговые права выставляются
+	// после файлов, начиная с самых глубоких, иначе в каталог без прав на запись ничего не записать
+	if err := os.MkdirAll(dstDir, 0o755); err != nil {
+		return nil, err
+	}
+	var dirs []ManifestEntry
+	for _, e := range manifest.Entries {
+		if e.Dir {
+			dirs = append(dirs, e)
+			if err := os.MkdirAll(filepath.Join(dstDir, filepath.FromSlash(e.Path)), 0o700); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var written writtenFiles
+	err = runDirJobs(manifest.Entries, opts.Workers, func(e *ManifestEntry) error {
+		data, err := cstc.decryptManifestEntry(encDir, e)
+		if err != nil {
+			return err
+		}
+		return written.write(filepath.Join(dstDir, filepath.FromSlash(e.Path)), data, e.Mode.Perm(), opts.Overwrite)
+	})
+	if err != nil {
+		written.removeAll()
+		return nil, err
+	}
+
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i].Path) > len(dirs[j].Path) })
+	for _, d := range dirs {
+		if err := os.Chmod(filepath.Join(dstDir, filepath.FromSlash(d.Path)), d.Mode.Perm()); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// VerifyDir расшифровывает все файлы encDir в памяти и сверяет их с манифестом
+func (cstc *CryptoSymmetricContext) VerifyDir(encDir string, opts DirOptions) (*Manifest, error) {
+	manifest, err := cstc.ReadManifest(encDir)
+	if err != nil {
+		return nil, err
+	}
+	err = runDirJobs(manifest.Entries, opts.Workers, func(e *ManifestEntry) error {
+		_, err := cstc.decryptManifestEntry(encDir, e)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeManifest шифрует манифест со случайным IV и записывает его последним:
+// каталог без манифеста не считается зашифрованным деревом
+func (cstc *CryptoSymmetricContext) writeManifest(dstDir string, manifest *Manifest, overwrite bool) error {
+	plainManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	encryptedManifest, err := cstc.encryptWithFreshIV(plainManifest)
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	return WriteFileAtomic(filepath.Join(dstDir, ManifestFileName), encryptedManifest, 0o644, overwrite)
+}
+
+// ReadManifest расшифровывает и проверяет манифест зашифрованного каталога
+func (cstc *CryptoSymmetricContext) ReadManifest(encDir string) (*Manifest, error) {
+	encrypted, err := os.ReadFile(filepath.Join(encDir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := cstc.decryptWithFreshIV(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(plain, &manifest); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+	if manifest.Mode != cstc.mode.String() || manifest.Padding != cstc.padding.String() {
+		return nil, fmt.Errorf("manifest was written with %s/%s, context uses %s/%s",
+			manifest.Mode, manifest.Padding, cstc.mode, cstc.padding)
+	}
+	for _, e := range manifest.Entries {
+		if !isSafeRelativePath(e.Path) || (e.Stored != "" && !isSafeRelativePath(e.Stored)) {
+			return nil, fmt.Errorf("manifest contains unsafe path %q", e.Path)
+		}
+	}
+	return &manifest, nil
+}
+
+// decryptManifestEntry расшифровывает файл и сверяет размер и хеш
+func (cstc *CryptoSymmetricContext) decryptManifestEntry(encDir string, e *ManifestEntry) ([]byte, error) {
+	var data []byte
+	if e.Stored != "" {
+		encrypted, err := os.ReadFile(filepath.Join(encDir, filepath.FromSlash(e.Stored)))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = cstc.decryptWithFreshIV(encrypted); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Path, err)
+		}
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != e.Size || hex.EncodeToString(sum[:]) != e.SHA256 {
+		return nil, fmt.Errorf("%s: %w", e.Path, ErrManifestMismatch)
+	}
+	return data, nil
+}
+
+// encryptWithFreshIV шифрует данные со случайным IV и записывает перед шифротекстом заголовок с KCV и IV
+func (cstc *CryptoSymmetricContext) encryptWithFreshIV(data []byte) ([]byte, error) {
+	iv, err := GenerateIV(cstc.mode, cstc.blockSize, cstc.randomReader())
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := cstc.withIV(iv).EncryptWithIVPrefix(data)
+	if err != nil {
+		return nil, err
+	}
+	return cstc.withKCVHeader(encrypted)
+}
+
+// decryptWithFreshIV проверяет KCV и дешифрует данные, записанные encryptWithFreshIV
+func (cstc *CryptoSymmetricContext) decryptWithFreshIV(data []byte) ([]byte, error) {
+	data, err := cstc.stripKCVHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	return cstc.DecryptWithIVPrefix(data)
+}
+
+// scanDir обходит дерево и строит манифест без размеров и хешей
+func (cstc *CryptoSymmetricContext) scanDir(srcDir string, encryptNames bool) (*Manifest, error) {
+	manifest := &Manifest{
+		Version:        manifestVersion,
+		Mode:           cstc.mode.String(),
+		Padding:        cstc.padding.String(),
+		EncryptedNames: encryptNames,
+	}
+	err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := ManifestEntry{Path: filepath.ToSlash(rel), Mode: info.Mode().Perm()}
+		switch {
+		case d.IsDir():
+			entry.Dir = true
+		case info.Mode().IsRegular():
+			entry.Stored = entry.Path + encryptedFileSuffix
+			if encryptNames {
+				id, err := randomBytes(cstc.random, 16)
+				if err != nil {
+					return err
+				}
+				entry.Stored = hex.EncodeToString(id) + encryptedFileSuffix
+			}
+		default:
+			return fmt.Errorf("%s: unsupported file type %s", rel, info.Mode().Type())
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStoredPaths(manifest.Entries); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// checkStoredPaths ищет зашифрованные файлы, которые нельзя записать: файл a хранится
+// как a.enc, и если в дереве есть каталог a.enc с файлами, пути совпадают. Так же
+// проверяется каталог с именем манифеста. Со случайными именами каталогов нет.
+func checkStoredPaths(entries []ManifestEntry) error {
+	dirs := make(map[string]bool)
+	for _, e := range entries {
+		if e.Stored == "" {
+			continue
+		}
+		for dir := path.Dir(e.Stored); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	if dirs[ManifestFileName] {
+		return fmt.Errorf("%w: directory %s collides with the manifest; encrypt the names instead", ErrInvalidArgument, ManifestFileName)
+	}
+	for _, e := range entries {
+		if e.Stored != "" && dirs[e.Stored] {
+			return fmt.Errorf("%w: %s would be stored as %s, which collides with a directory; encrypt the names instead",
+				ErrInvalidArgument, e.Path, e.Stored)
+		}
+	}
+	return nil
+}
+
+// writtenFiles запоминает файлы, записанные при обработке дерева,
+// чтобы при ошибке удалить уже готовую часть результата
+type writtenFiles struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// write атомарно записывает файл; права выставляются явно и не зависят от umask
+func (w *writtenFiles) write(path string, data []byte, perm fs.FileMode, overwrite bool) error {
+	if err := WriteFileAtomic(path, data, perm, overwrite); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.paths = append(w.paths, path)
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *writtenFiles) removeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, path := range w.paths {
+		os.Remove(path)
+	}
+	w.paths = nil
+}
+
+// runDirJobs обрабатывает файлы манифеста (каталоги пропускаются) в workers горутинах.
+// После первой ошибки новые файлы не начинаются; возвращается первая ошибка.
+func runDirJobs(entries []ManifestEntry, workers int, job func(e *ManifestEntry) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	jobs := make(chan *ManifestEntry)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				if failed() {
+					continue
+				}
+				if err := job(e); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range entries {
+		if !entries[i].Dir {
+			jobs <- &entries[i]
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// checkDirsDisjoint запрещает шифровать каталог в самого себя или в подкаталог
+func checkDirsDisjoint(srcDir, dstDir string) error {
+	src, err := filepath.Abs(srcDir)
+	if err != nil {
+		return err
+	}
+	dst, err := filepath.Abs(dstDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(src, dst); err == nil && (rel == "." || !strings.HasPrefix(rel, "..")) {
+		return errors.New("destination directory must not be inside the source directory")
+	}
+	return nil
+}
+
+// isSafeRelativePath не пропускает абсолютные пути и выход за пределы корня
+func isSafeRelativePath(p string) bool {
+	if p == "" || path.IsAbs(p) || strings.Contains(p, "\\") {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean == p && clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testTree описывает файлы (содержимое и права) и каталоги (права) дерева для тестов
+type testTree struct {
+	files map[string]string
+	modes map[string]fs.FileMode
+	dirs  map[string]fs.FileMode
+}
+
+func defaultTestTree() testTree {
+	return testTree{
+		files: map[string]string{
+			"x.txt":        "hello",
+			"a/b/bin":      string(bytes.Repeat([]byte{0x5a, 0xa5, 0x00}, 3000)),
+			"a/zero":       "",
+			"a/script.sh":  "#!/bin/sh\necho hi\n",
+			"a.enc.d/note": "not a collision",
+		},
+		modes: map[string]fs.FileMode{
+			"x.txt":       0o600,
+			"a/script.sh": 0o750,
+		},
+		dirs: map[string]fs.FileMode{
+			"a":       0o750,
+			"a/b":     0o555,
+			"empty":   0o700,
+			"a.enc.d": 0o755,
+		},
+	}
+}
+
+// write создает дерево в root; права каталогов выставляются после файлов
+func (tree testTree) write(t *testing.T, root string) {
+	t.Helper()
+	for name := range tree.dirs {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range tree.files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		mode, ok := tree.modes[name]
+		if !ok {
+			mode = 0o644
+		}
+		if err := os.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, mode := range tree.dirs {
+		if err := os.Chmod(filepath.Join(root, filepath.FromSlash(name)), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	restoreWritable(t, root)
+}
+
+// check сверяет содержимое и права восстановленного дерева
+func (tree testTree) check(t *testing.T, root string) {
+	t.Helper()
+	for name, content := range tree.files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("%s: restored content differs", name)
+		}
+		want, ok := tree.modes[name]
+		if !ok {
+			want = 0o644
+		}
+		if info, err := os.Stat(p); err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s: mode %v, want %v (%v)", name, info.Mode().Perm(), want, err)
+		}
+	}
+	for name, want := range tree.dirs {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !info.IsDir() || info.Mode().Perm() != want {
+			t.Errorf("%s: directory mode %v, want %v", name, info.Mode().Perm(), want)
+		}
+	}
+}
+
+// restoreWritable возвращает права на запись перед удалением временного каталога
+func restoreWritable(t *testing.T, root string) {
+	t.Cleanup(func() {
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				os.Chmod(p, 0o755)
+			}
+			return nil
+		})
+	})
+}
+
+func newDirTestContext(t *testing.T, mode CipherMode, seed string) *CryptoSymmetricContext {
+	t.Helper()
+	spec, _ := LookupAlgorithm("AES")
+	random := NewTestRand("dirtree/" + seed)
+	key, err := GenerateKey("AES", 16, random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv, err := GenerateIV(mode, spec.BlockSize, random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alg, err := spec.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext(key, alg, WithMode(mode), WithPadding(PKCS7), WithIV(iv), WithRand(random))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	tree := defaultTestTree()
+	for _, mode := range []CipherMode{CBC, CTR, ECB} {
+		for _, encryptNames := range []bool{false, true} {
+			dir := t.TempDir()
+			src, enc, out := filepath.Join(dir, "src"), filepath.Join(dir, "enc"), filepath.Join(dir, "out")
+			tree.write(t, src)
+			restoreWritable(t, out)
+			ctx := newDirTestContext(t, mode, "roundtrip")
+			opts := DirOptions{EncryptNames: encryptNames, Workers: 2}
+
+			manifest, err := ctx.EncryptDir(src, enc, opts)
+			if err != nil {
+				t.Fatalf("%s/names=%v: EncryptDir: %v", mode, encryptNames, err)
+			}
+			if manifest.EncryptedNames != encryptNames {
+				t.Errorf("%s: manifest EncryptedNames = %v", mode, manifest.EncryptedNames)
+			}
+			for _, e := range manifest.Entries {
+				if e.Path == "a/zero" && e.Stored != "" {
+					t.Errorf("%s: empty file stored as %s", mode, e.Stored)
+				}
+				if e.Stored == "" {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(enc, filepath.FromSlash(e.Stored))); err != nil {
+					t.Errorf("%s: stored file for %s: %v", mode, e.Path, err)
+				}
+				if encryptNames && filepath.Base(e.Stored) != e.Stored {
+					t.Errorf("%s: encrypted name %s keeps the directory structure", mode, e.Stored)
+				}
+			}
+
+			if _, err := ctx.VerifyDir(enc, opts); err != nil {
+				t.Errorf("%s/names=%v: VerifyDir: %v", mode, encryptNames, err)
+			}
+			if _, err := ctx.DecryptDir(enc, out, opts); err != nil {
+				t.Fatalf("%s/names=%v: DecryptDir: %v", mode, encryptNames, err)
+			}
+			tree.check(t, out)
+
+			// Повторное восстановление без Overwrite не заменяет файлы
+			if _, err := ctx.DecryptDir(enc, out, opts); !errors.Is(err, ErrOutputExists) {
+				t.Errorf("%s: second DecryptDir error = %v, want ErrOutputExists", mode, err)
+			}
+			opts.Overwrite = true
+			if _, err := ctx.DecryptDir(enc, out, opts); err != nil {
+				t.Errorf("%s: DecryptDir with Overwrite: %v", mode, err)
+			}
+		}
+	}
+}
+
+// TestDirDetectsTampering портит зашифрованное дерево: VerifyDir и DecryptDir
+// должны отвергнуть его и не оставить восстановленных файлов
+func TestDirDetectsTampering(t *testing.T) {
+	tree := testTree{files: map[string]string{
+		"one": string(bytes.Repeat([]byte("first file "), 50)),
+		"two": string(bytes.Repeat([]byte("second file"), 50)),
+	}}
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, enc string, m *Manifest)
+		want   error
+	}{
+		{"flipped ciphertext byte", func(t *testing.T, enc string, m *Manifest) {
+			p := filepath.Join(enc, storedPath(t, m, "one"))
+			data, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[len(data)/2] ^= 0x01
+			if err := os.WriteFile(p, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, ErrManifestMismatch},
+		{"swapped files", func(t *testing.T, enc string, m *Manifest) {
+			one, two := filepath.Join(enc, storedPath(t, m, "one")), filepath.Join(enc, storedPath(t, m, "two"))
+			tmp := one + ".tmp"
+			for _, rename := range [][2]string{{one, tmp}, {two, one}, {tmp, two}} {
+				if err := os.Rename(rename[0], rename[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}, ErrManifestMismatch},
+		{"missing file", func(t *testing.T, enc string, m *Manifest) {
+			if err := os.Remove(filepath.Join(enc, storedPath(t, m, "two"))); err != nil {
+				t.Fatal(err)
+			}
+		}, fs.ErrNotExist},
+		{"tampered manifest", func(t *testing.T, enc string, m *Manifest) {
+			p := filepath.Join(enc, ManifestFileName)
+			data, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[len(data)-1] ^= 0x80
+			if err := os.WriteFile(p, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, ErrAuthenticationFailed},
+		{"missing manifest", func(t *testing.T, enc string, m *Manifest) {
+			if err := os.Remove(filepath.Join(enc, ManifestFileName)); err != nil {
+				t.Fatal(err)
+			}
+		}, fs.ErrNotExist},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		src, enc, out := filepath.Join(dir, "src"), filepath.Join(dir, "enc"), filepath.Join(dir, "out")
+		tree.write(t, src)
+		ctx := newDirTestContext(t, CBC, "tamper")
+		manifest, err := ctx.EncryptDir(src, enc, DirOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.tamper(t, enc, manifest)
+
+		if _, err := ctx.VerifyDir(enc, DirOptions{}); !errors.Is(err, tt.want) {
+			t.Errorf("%s: VerifyDir error = %v, want %v", tt.name, err, tt.want)
+		}
+		if _, err := ctx.DecryptDir(enc, out, DirOptions{Workers: 1}); !errors.Is(err, tt.want) {
+			t.Errorf("%s: DecryptDir error = %v, want %v", tt.name, err, tt.want)
+		}
+		for name := range tree.files {
+			if _, err := os.Stat(filepath.Join(out, name)); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s: DecryptDir left %s behind", tt.name, name)
+			}
+		}
+	}
+}
+
+func storedPath(t *testing.T, m *Manifest, name string) string {
+	t.Helper()
+	for _, e := range m.Entries {
+		if e.Path == name {
+			return filepath.FromSlash(e.Stored)
+		}
+	}
+	t.Fatalf("%s is not in the manifest", name)
+	return ""
+}
+
+func TestReadManifestRejectsMismatch(t *testing.T) {
+	dir := t.TempDir()
+	src, enc := filepath.Join(dir, "src"), filepath.Join(dir, "enc")
+	testTree{files: map[string]string{"f": "data"}}.write(t, src)
+	if _, err := newDirTestContext(t, CBC, "manifest").EncryptDir(src, enc, DirOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Другой ключ: KCV манифеста не совпадает
+	if _, err := newDirTestContext(t, CBC, "other key").ReadManifest(enc); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("wrong key: error = %v, want ErrKeyMismatch", err)
+	}
+
+	// Тот же ключ, другой режим: манифест отвергается до обработки файлов
+	cbc := newDirTestContext(t, CBC, "manifest")
+	ofb := cbc.withIV(cbc.iv)
+	ofb.mode = OFB
+	if _, err := ofb.ReadManifest(enc); err == nil {
+		t.Error("manifest written with CBC was accepted by an OFB context")
+	}
+
+	// Манифест с путями за пределами корня
+	for _, unsafe := range []ManifestEntry{
+		{Path: "../evil", Stored: "x.enc"},
+		{Path: "ok", Stored: "../../evil.enc"},
+		{Path: "/etc/passwd"},
+	} {
+		manifest := &Manifest{Version: manifestVersion, Mode: cbc.mode.String(), Padding: cbc.padding.String(), Entries: []ManifestEntry{unsafe}}
+		if err := cbc.writeManifest(enc, manifest, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cbc.ReadManifest(enc); err == nil {
+			t.Errorf("manifest with entry %+v was accepted", unsafe)
+		}
+	}
+}
+
+func TestEncryptDirRejectsStoredNameCollision(t *testing.T) {
+	trees := map[string]map[string]string{
+		"file and directory": {"a": "file", "a.enc/b": "nested"},
+		"manifest directory": {".cryptolab-manifest/x": "nested"},
+	}
+	for name, files := range trees {
+		dir := t.TempDir()
+		src, enc := filepath.Join(dir, "src"), filepath.Join(dir, "enc")
+		tree := testTree{files: files}
+		tree.write(t, src)
+		ctx := newDirTestContext(t, CBC, "collision")
+
+		if _, err := ctx.EncryptDir(src, enc, DirOptions{}); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: error = %v, want ErrInvalidArgument", name, err)
+		}
+		if entries, _ := os.ReadDir(enc); len(entries) != 0 {
+			t.Errorf("%s: rejected tree left %d entries in the destination", name, len(entries))
+		}
+
+		// Со случайными именами совпадений нет
+		if _, err := ctx.EncryptDir(src, enc, DirOptions{EncryptNames: true}); err != nil {
+			t.Fatalf("%s with encrypted names: %v", name, err)
+		}
+		out := filepath.Join(dir, "out")
+		if _, err := ctx.DecryptDir(enc, out, DirOptions{}); err != nil {
+			t.Fatalf("%s with encrypted names: DecryptDir: %v", name, err)
+		}
+		tree.check(t, out)
+	}
+}
+
+func TestIsSafeRelativePath(t *testing.T) {
+	tests := []struct {
+		path string
+		safe bool
+	}{
+		{"a", true},
+		{"a/b.enc", true},
+		{"..a", true},
+		{"a..b/c", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../a", false},
+		{"a/../../b", false},
+		{"a/../b", false}, // не в канонической форме
+		{"./a", false},
+		{"a//b", false},
+		{"a/", false},
+		{"/etc/passwd", false},
+		{`a\..\b`, false},
+		{`C:\Windows`, false},
+	}
+	for _, tt := range tests {
+		if got := isSafeRelativePath(tt.path); got != tt.safe {
+			t.Errorf("isSafeRelativePath(%q) = %v, want %v", tt.path, got, tt.safe)
+		}
+	}
+}
+
+func TestCheckDirsDisjoint(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	tests := []struct {
+		dst string
+		ok  bool
+	}{
+		{filepath.Join(root, "dst"), true},
+		{filepath.Join(root, "src2"), true},
+		{root, true},
+		{src, false},
+		{src + string(filepath.Separator), false},
+		{filepath.Join(src, "sub"), false},
+		{filepath.Join(src, "a", "..", "b"), false},
+		{filepath.Join(root, "dst", "..", "src", "x"), false},
+	}
+	for _, tt := range tests {
+		err := checkDirsDisjoint(src, tt.dst)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkDirsDisjoint(%s, %s) = %v, want ok = %v", src, tt.dst, err, tt.ok)
+		}
+	}
+
+	// Пути относительно рабочего каталога
+	if err := checkDirsDisjoint(".", "out"); err == nil {
+		t.Error("checkDirsDisjoint(., out) accepted a destination inside the source")
+	}
+	if err := checkDirsDisjoint("src", "../out"); err != nil {
+		t.Errorf("checkDirsDisjoint(src, ../out): %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Источники случайности. По умолчанию используется crypto/rand; для воспроизводимых
+// проверок контексту можно передать детерминированный HMAC_DRBG (NIST SP 800-90A)
+// с фиксированным зерном - тогда IV, случайная набивка ISO 10126 и delta режима
+// RandomDelta получаются одинаковыми при каждом запуске.
+
+// HMACDRBG - детерминированный генератор HMAC_DRBG на SHA-256 без повторного засева.
+// Для шифрования реальных данных не предназначен: при известном зерне весь вывод предсказуем.
+// Не безопасен для одновременного использования из нескольких горутин.
+type HMACDRBG struct {
+	k, v []byte
+	// reseedCounter - число выполненных запросов; по SP 800-90A не больше 2^48
+	reseedCounter uint64
+}
+
+// drbgMaxRequest - наибольший размер одного запроса к HMAC_DRBG в байтах (2^19 бит)
+const drbgMaxRequest = 1 << 16
+
+// NewHMACDRBG создает генератор; seed соответствует entropy_input || nonce || personalization_string
+func NewHMACDRBG(seed []byte) *HMACDRBG {
+	d := &HMACDRBG{
+		k:             make([]byte, sha256.Size),
+		v:             make([]byte, sha256.Size),
+		reseedCounter: 1,
+	}
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+	d.update(seed)
+	return d
+}
+
+// NewTestRand - генератор для воспроизводимых проверок с зерном из строки
+func NewTestRand(seed string) *HMACDRBG {
+	return NewHMACDRBG([]byte(seed))
+}
+
+func (d *HMACDRBG) hmac(parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, d.k)
+	for _, p := range parts {
+		mac.Write(p)
+	}
+	return mac.Sum(nil)
+}
+
+// update - функция HMAC_DRBG_Update из SP 800-90A
+func (d *HMACDRBG) update(data []byte) {
+	d.k = d.hmac(d.v, []byte{0x00}, data)
+	d.v = d.hmac(d.v)
+	if len(data) == 0 {
+		return
+	}
+	d.k = d.hmac(d.v, []byte{0x01}, data)
+	d.v = d.hmac(d.v)
+}
+
+// Generate заполняет out одним запросом HMAC_DRBG_Generate без дополнительного ввода
+func (d *HMACDRBG) Generate(out []byte) error {
+	if len(out) > drbgMaxRequest {
+		return fmt.Errorf("DRBG request of %d bytes exceeds %d", len(out), drbgMaxRequest)
+	}
+	if d.reseedCounter > 1<<48 {
+		return errors.New("DRBG reseed required")
+	}
+	for n := 0; n < len(out); {
+		d.v = d.hmac(d.v)
+		n += copy(out[n:], d.v)
+	}
+	d.update(nil)
+	d.reseedCounter++
+	return nil
+}
+
+// Read реализует io.Reader; длинные чтения разбиваются на запросы по drbgMaxRequest байт.
+// Вывод зависит не только от зерна, но и от размеров чтений.
+func (d *HMACDRBG) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		chunk := len(p) - n
+		if chunk > drbgMaxRequest {
+			chunk = drbgMaxRequest
+		}
+		if err := d.Generate(p[n : n+chunk]); err != nil {
+			return n, err
+		}
+		n += chunk
+	}
+	return len(p), nil
+}
+
+// randomSource возвращает r или crypto/rand, если r не задан
+func randomSource(r io.Reader) io.Reader {
+	if r == nil {
+		return rand.Reader
+	}
+	return r
+}
+
+// randomBytes читает size байт из r (nil - crypto/rand)
+func randomBytes(r io.Reader, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(randomSource(r), b); err != nil {
+		return nil, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return b, nil
+}
+
+// lockedReader позволяет читать один источник из нескольких горутин
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
+
+// lockRandom оборачивает источник случайности контекста в lockedReader: контекст читает его
+// из нескольких горутин (параллельные режимы, EncryptDir, конкурентные вызовы Encrypt),
+// а HMACDRBG и другие читатели не потокобезопасны. nil остается nil (crypto/rand).
+func lockRandom(r io.Reader) io.Reader {
+	if r == nil {
+		return nil
+	}
+	if _, ok := r.(*lockedReader); ok {
+		return r
+	}
+	return &lockedReader{r: r}
+}
+
+// checkRandom - источник случайных ключей и данных для самопроверок и бенчмарков;
+// nil - crypto/rand. Команда selftest с -seed подставляет сюда HMAC_DRBG.
+var checkRandom io.Reader
+
+// setCheckSeed делает случайные данные проверок воспроизводимыми; пустое зерно возвращает crypto/rand
+func setCheckSeed(seed string) {
+	if seed == "" {
+		checkRandom = nil
+		return
+	}
+	checkRandom = lockRandom(NewTestRand(seed))
+}
+
+// drbgVector - вектор NIST CAVP для HMAC_DRBG SHA-256 без засева и дополнительного ввода:
+// после создания выполняются два запроса, сверяется вывод второго
+type drbgVector struct {
+	entropy, nonce, returned string
+}
+
+var drbgVectors = []drbgVector{
+	{
+		entropy:  "ca851911349384bffe89de1cbdc46e6831e44d34a4fb935ee285dd14b71a7488",
+		nonce:    "659ba96c601dc69fc902940805ec0ca8",
+		returned: "e528e9abf2dece54d47c7e75e5fe302149f817ea9fb4bee6f4199697d04d5b89d54fbb978a15b5c443c9ec21036d2460b6f73ebad0dc2aba6e624abf07745bc107694bb7547bb0995f70de25d6b29e2d3011bb19d27676c07162c8b5ccde0668961df86803482cb37ed6d5c0bb8d50cf1f50d476aa0458bdaba806f48be9dcb8",
+	},
+}
+
+func runDRBGVector(v drbgVector) error {
+	d := NewHMACDRBG(append(mustDecodeHex(v.entropy), mustDecodeHex(v.nonce)...))
+	out := make([]byte, len(v.returned)/2)
+	for i := 0; i < 2; i++ {
+		if err := d.Generate(out); err != nil {
+			return err
+		}
+	}
+	if got := hex.EncodeToString(out); got != v.returned {
+		return fmt.Errorf("got %s, want %s", got, v.returned)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	mathbits "math/bits"
+	"sort"
+	"strings"
+)
+
+// Анализ распространения ошибок: шифротекст портится заданным образом,
+// после чего расшифрованный текст сравнивается с ожидаемым поблочно и побитно.
+
+// Fault - вид искажения шифротекста
+type Fault int
+
+const (
+	FaultBitFlip Fault = iota
+	FaultDropBlock
+	FaultDuplicateBlock
+)
+
+func (f Fault) String() string {
+	switch f {
+	case FaultBitFlip:
+		return "bit flip"
+	case FaultDropBlock:
+		return "drop block"
+	case FaultDuplicateBlock:
+		return "duplicate block"
+	default:
+		return fmt.Sprintf("Fault(%d)", int(f))
+	}
+}
+
+// PropagationResult - результат одного эксперимента
+type PropagationResult struct {
+	Mode        string
+	Fault       Fault
+	TargetBlock int
+	// Bit - номер инвертированного бита в искажаемом блоке (для FaultBitFlip)
+	Bit             int
+	CorruptedBlocks []int
+	// CorruptedBitOffsets[i] - номера искаженных битов внутри блока CorruptedBlocks[i];
+	// биты нумеруются с нуля от старшего бита первого байта блока
+	CorruptedBitOffsets [][]int
+	CorruptedBits       int
+	// Expected - теоретически искаженные блоки; nil, если теория для режима неизвестна
+	Expected []int
+	// ExpectedBitOffsets - блоки, в которых теория предсказывает точные номера искаженных
+	// битов (например, один бит в CTR); в остальных искаженных блоках биты случайны
+	ExpectedBitOffsets map[int][]int
+	Err                error
+}
+
+// MatchesTheory сообщает, совпал ли результат с теоретическим: по блокам и, где теория
+// это позволяет, по номерам битов
+func (r PropagationResult) MatchesTheory() bool {
+	if r.Expected == nil || r.Err != nil {
+		return false
+	}
+	if !equalInts(r.Expected, r.CorruptedBlocks) {
+		return false
+	}
+	for block, bits := range r.ExpectedBitOffsets {
+		i := sort.SearchInts(r.CorruptedBlocks, block)
+		if i == len(r.CorruptedBlocks) || r.CorruptedBlocks[i] != block || !equalInts(bits, r.CorruptedBitOffsets[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// PropagationConfig - конфигурация контекста, для которой проводится анализ
+type PropagationConfig struct {
+	Key       []byte
+	Cipher    SymmetricAlgorithm
+	IV        []byte
+	BlockSize int
+	// Blocks - длина сообщения в блоках, TargetBlock - номер искажаемого блока
+	Blocks      int
+	TargetBlock int
+	// Bit - номер инвертируемого бита в искажаемом блоке, от 0 (старший бит первого байта)
+	// до BlockSize*8-1
+	Bit int
+}
+
+// AnalyzeErrorPropagation проводит все виды искажений для каждого из режимов.
+// Набивка не используется, чтобы удаление или дублирование блока не приводило к ошибке набивки.
+func AnalyzeErrorPropagation(cfg PropagationConfig, modes []CipherMode) ([]PropagationResult, error) {
+	if cfg.Blocks < 2 || cfg.TargetBlock < 0 || cfg.TargetBlock >= cfg.Blocks-1 {
+		return nil, fmt.Errorf("%w: target block %d must leave at least one block after it in a %d-block message", ErrInvalidArgument, cfg.TargetBlock, cfg.Blocks)
+	}
+	if cfg.Bit < 0 || cfg.Bit >= cfg.BlockSize*8 {
+		return nil, fmt.Errorf("%w: bit %d is outside a %d-byte block", ErrInvalidArgument, cfg.Bit, cfg.BlockSize)
+	}
+
+	plaintext := make([]byte, cfg.Blocks*cfg.BlockSize)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 7)
+	}
+
+	var results []PropagationResult
+	for _, mode := range modes {
+		ctx, err := NewCryptoSymmetricContext(cfg.Key, cfg.Cipher, mode, NoPadding, cfg.IV, cfg.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := ctx.Encrypt(plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", mode, err)
+		}
+
+		for _, fault := range []Fault{FaultBitFlip, FaultDropBlock, FaultDuplicateBlock} {
+			result := PropagationResult{
+				Mode:               mode.String(),
+				Fault:              fault,
+				TargetBlock:        cfg.TargetBlock,
+				Bit:                cfg.Bit,
+				Expected:           expectedPropagation(mode.String(), fault, cfg.TargetBlock, cfg.Blocks),
+				ExpectedBitOffsets: expectedBitOffsets(mode.String(), fault, cfg.TargetBlock, cfg.Bit),
+			}
+
+			damaged, reference := applyFault(fault, ciphertext, plaintext, cfg.BlockSize, cfg.TargetBlock, cfg.Bit)
+			decrypted, err := ctx.Decrypt(damaged)
+			if err != nil {
+				result.Err = err
+			} else {
+				result.CorruptedBlocks, result.CorruptedBitOffsets, result.CorruptedBits = compareBlocks(decrypted, reference, cfg.BlockSize)
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// applyFault искажает шифротекст и возвращает открытый текст, который получатель
+// увидел бы при идеальном режиме: для удаления и дублирования блок удаляется или дублируется и в нем.
+// Служебный префикс шифротекста (например, delta в RandomDelta) не затрагивается.
+func applyFault(fault Fault, ciphertext, plaintext []byte, blockSize, target, bit int) ([]byte, []byte) {
+	header := len(ciphertext) - len(plaintext)
+	prefix := ciphertext[:header]
+	body := ciphertext[header:]
+	start, end := target*blockSize, (target+1)*blockSize
+
+	var damaged, reference []byte
+	switch fault {
+	case FaultBitFlip:
+		damaged = append([]byte{}, body...)
+		damaged[start+bit/8] ^= 0x80 >> (bit % 8)
+		reference = plaintext
+	case FaultDropBlock:
+		damaged = concatBytes(body[:start], body[end:])
+		reference = concatBytes(plaintext[:start], plaintext[end:])
+	case FaultDuplicateBlock:
+		damaged = concatBytes(body[:end], body[start:end], body[end:])
+		reference = concatBytes(plaintext[:end], plaintext[start:end], plaintext[end:])
+	}
+	return concatBytes(prefix, damaged), reference
+}
+
+// compareBlocks возвращает номера отличающихся блоков, номера отличающихся битов в каждом
+// из них и общее число отличающихся битов. Недостающие байты считаются искаженными целиком.
+func compareBlocks(got, want []byte, blockSize int) ([]int, [][]int, int) {
+	corrupted := []int{}
+	offsets := [][]int{}
+	bits := 0
+	for bs := 0; bs < len(want); bs += blockSize {
+		be := bs + blockSize
+		if be > len(want) {
+			be = len(want)
+		}
+		var gotBlock []byte
+		if bs < len(got) {
+			gotBlock = got[bs:min(be, len(got))]
+		}
+		if bytes.Equal(gotBlock, want[bs:be]) {
+			continue
+		}
+		corrupted = append(corrupted, bs/blockSize)
+		var blockBits []int
+		for i := bs; i < be; i++ {
+			diff := byte(0xFF)
+			if i < len(got) {
+				diff = got[i] ^ want[i]
+			}
+			bits += mathbits.OnesCount8(diff)
+			for b := 0; b < 8; b++ {
+				if diff&(0x80>>b) != 0 {
+					blockBits = append(blockBits, (i-bs)*8+b)
+				}
+			}
+		}
+		offsets = append(offsets, blockBits)
+	}
+	return corrupted, offsets, bits
+}
+
+// expectedPropagation - теоретически искаженные блоки для встроенных режимов.
+// Номера блоков относятся к эталонному тексту после удаления или дублирования.
+func expectedPropagation(mode string, fault Fault, target, blocks int) []int {
+	span := func(from, to int) []int {
+		s := []int{}
+		for i := from; i < to; i++ {
+			s = append(s, i)
+		}
+		return s
+	}
+	total := blocks
+	switch fault {
+	case FaultDropBlock:
+		total = blocks - 1
+	case FaultDuplicateBlock:
+		total = blocks + 1
+	}
+
+	switch fault {
+	case FaultBitFlip:
+		switch mode {
+		case "ECB", "OFB", "CTR", "RandomDelta":
+			return []int{target}
+		case "CBC", "CFB":
+			return []int{target, target + 1}
+		case "PCBC":
+			return span(target, total)
+		}
+	case FaultDropBlock:
+		switch mode {
+		case "ECB", "RandomDelta":
+			return []int{}
+		case "CBC", "CFB":
+			return []int{target}
+		case "PCBC", "OFB", "CTR":
+			return span(target, total)
+		}
+	case FaultDuplicateBlock:
+		switch mode {
+		case "ECB", "RandomDelta":
+			return []int{}
+		case "CBC", "CFB":
+			return []int{target + 1}
+		case "PCBC", "OFB", "CTR":
+			return span(target+1, total)
+		}
+	}
+	return nil
+}
+
+// expectedBitOffsets - блоки, в которых инвертированный бит шифротекста инвертирует ровно
+// тот же бит открытого текста: сам блок в OFB, CTR и CFB, следующий блок в CBC.
+// В RandomDelta бит переходит в вычитание по модулю 256 и может затронуть старшие биты байта.
+func expectedBitOffsets(mode string, fault Fault, target, bit int) map[int][]int {
+	if fault != FaultBitFlip {
+		return nil
+	}
+	switch mode {
+	case "OFB", "CTR", "CFB":
+		return map[int][]int{target: {bit}}
+	case "CBC":
+		return map[int][]int{target + 1: {bit}}
+	}
+	return nil
+}
+
+// WritePropagationTable печатает сравнительную таблицу по режимам
+func WritePropagationTable(w io.Writer, results []PropagationResult) {
+	faults := []Fault{FaultBitFlip, FaultDropBlock, FaultDuplicateBlock}
+	cells := make(map[string]map[Fault]string)
+	var modes []string
+	for _, r := range results {
+		if _, ok := cells[r.Mode]; !ok {
+			cells[r.Mode] = make(map[Fault]string)
+			modes = append(modes, r.Mode)
+		}
+		cells[r.Mode][r.Fault] = formatPropagationCell(r)
+	}
+
+	fmt.Fprintf(w, "%-12s", "Mode")
+	for _, f := range faults {
+		fmt.Fprintf(w, " | %-30s", f)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, strings.Repeat("-", 12+len(faults)*33))
+	for _, mode := range modes {
+		fmt.Fprintf(w, "%-12s", mode)
+		for _, f := range faults {
+			fmt.Fprintf(w, " | %-30s", cells[mode][f])
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// WritePropagationBits печатает номера искаженных битов по блокам для инверсии бита
+func WritePropagationBits(w io.Writer, results []PropagationResult) {
+	for _, r := range results {
+		if r.Fault != FaultBitFlip || r.Err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "%-12s bit %d of block %d:", r.Mode, r.Bit, r.TargetBlock)
+		if len(r.CorruptedBlocks) == 0 {
+			fmt.Fprint(w, " none")
+		}
+		for i, block := range r.CorruptedBlocks {
+			fmt.Fprintf(w, " block %d bits %s;", block, formatRanges(r.CorruptedBitOffsets[i]))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func formatPropagationCell(r PropagationResult) string {
+	if r.Err != nil {
+		return "error: " + r.Err.Error()
+	}
+	mark := "?"
+	if r.Expected != nil {
+		mark = "ok"
+		if !r.MatchesTheory() {
+			mark = "MISMATCH"
+		}
+	}
+	return fmt.Sprintf("%s (%d bits) %s", formatBlockList(r.CorruptedBlocks), r.CorruptedBits, mark)
+}
+
+// formatBlockList сворачивает номера блоков в диапазоны: [2 3 4 7] -> "blocks 2-4,7"
+func formatBlockList(blocks []int) string {
+	if len(blocks) == 0 {
+		return "none"
+	}
+	return "blocks " + formatRanges(blocks)
+}
+
+// formatRanges сворачивает числа в диапазоны: [2 3 4 7] -> "2-4,7"
+func formatRanges(numbers []int) string {
+	sorted := append([]int{}, numbers...)
+	sort.Ints(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprint(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	var n int
+	for _, p := range parts {
+		n += len(p)
+	}
+	result := make([]byte, 0, n)
+	for _, p := range parts {
+		result = append(result, p...)
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestErrorPropagationMatchesTheory проверяет для каждого зарегистрированного режима,
+// что искаженные блоки и, где это следует из теории, номера битов совпадают с ожидаемыми
+func TestErrorPropagationMatchesTheory(t *testing.T) {
+	var modes []CipherMode
+	for _, name := range BlockModeNames() {
+		mode, _ := LookupBlockMode(name)
+		modes = append(modes, mode)
+	}
+	for _, algName := range []string{"DES", "AES"} {
+		spec, _ := LookupAlgorithm(algName)
+		random := NewTestRand("errorprop/" + algName)
+		key, err := GenerateKey(algName, spec.KeySizes[0], random)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iv, err := randomBytes(random, spec.BlockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, target := range []int{0, 2} {
+			for _, bit := range []int{0, 3, 13, spec.BlockSize*8 - 1} {
+				cipher, err := spec.New()
+				if err != nil {
+					t.Fatal(err)
+				}
+				results, err := AnalyzeErrorPropagation(PropagationConfig{
+					Key: key, Cipher: cipher, IV: iv, BlockSize: spec.BlockSize,
+					Blocks: 6, TargetBlock: target, Bit: bit,
+				}, modes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) != 3*len(modes) {
+					t.Fatalf("got %d results for %d modes", len(results), len(modes))
+				}
+				for _, r := range results {
+					if !r.MatchesTheory() {
+						t.Errorf("%s %s, block %d bit %d: got %s bits %v (err %v), want blocks %v bits %v",
+							algName, r.Mode, target, bit, formatBlockList(r.CorruptedBlocks), r.CorruptedBitOffsets, r.Err, r.Expected, r.ExpectedBitOffsets)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestErrorPropagationReportsBitOffsets(t *testing.T) {
+	got, offsets, bits := compareBlocks([]byte{0x00, 0x00, 0x81, 0x00}, []byte{0x00, 0x00, 0x00, 0x01}, 2)
+	if fmt.Sprint(got) != "[1]" || fmt.Sprint(offsets) != "[[0 7 15]]" || bits != 3 {
+		t.Errorf("compareBlocks = %v %v %d, want [1] [[0 7 15]] 3", got, offsets, bits)
+	}
+	// Недостающие байты искажены целиком
+	got, offsets, bits = compareBlocks([]byte{0x00}, []byte{0x00, 0x00}, 2)
+	if fmt.Sprint(got) != "[0]" || len(offsets[0]) != 8 || offsets[0][0] != 8 || bits != 8 {
+		t.Errorf("compareBlocks of a short text = %v %v %d", got, offsets, bits)
+	}
+}
+
+func TestErrorPropagationRejectsBadBit(t *testing.T) {
+	cipher, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bit := range []int{-1, 64} {
+		_, err := AnalyzeErrorPropagation(PropagationConfig{
+			Key: mustDecodeHex("133457799bbcdff1"), Cipher: cipher, IV: make([]byte, 8), BlockSize: 8,
+			Blocks: 4, TargetBlock: 1, Bit: bit,
+		}, []CipherMode{CTR})
+		if err == nil {
+			t.Errorf("bit %d was accepted", bit)
+		}
+	}
+}
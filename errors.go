@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Общие ошибки библиотеки. Конкретные ошибки оборачивают их через %w, поэтому
+// класс ошибки проверяется errors.Is, а подробности - errors.As на типах ниже.
+// Decrypt контекста намеренно возвращает только ErrDecryption: различать ошибки
+// набивки и режима снаружи нельзя, иначе появляется оракул набивки.
+
+var (
+	// ErrInvalidKeySize - длина ключа не поддерживается алгоритмом
+	ErrInvalidKeySize = errors.New("invalid key size")
+	// ErrInvalidBlockSize - длина блока или данных не соответствует размеру блока
+	ErrInvalidBlockSize = errors.New("invalid block size")
+	// ErrInvalidIV - IV отсутствует или имеет неверную длину
+	ErrInvalidIV = errors.New("invalid IV")
+	// ErrInvalidPadding - набивка повреждена или не соответствует схеме
+	ErrInvalidPadding = errors.New("invalid padding")
+	// ErrAuthenticationFailed - шифротекст не прошел проверку: неверный ключ или поврежденные данные
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// ErrUnsupportedMode - режим шифрования не зарегистрирован или не подходит для операции
+	ErrUnsupportedMode = errors.New("unsupported cipher mode")
+	// ErrUnsupportedPadding - схема набивки не зарегистрирована
+	ErrUnsupportedPadding = errors.New("unsupported padding mode")
+	// ErrEmptyInput - данные для шифрования или дешифрования пусты
+	ErrEmptyInput = errors.New("data cannot be nil or empty")
+	// ErrInvalidArgument - недопустимый параметр контекста: nil вместо шифра или источника
+	// случайности, отрицательное число горутин и т.п.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// KeySizeError - ключ недопустимой длины; errors.Is(err, ErrInvalidKeySize) для нее истинно
+type KeySizeError struct {
+	// Algorithm - имя алгоритма, может быть пустым
+	Algorithm string
+	Size      int
+	// Valid - допустимые длины ключа в байтах
+	Valid []int
+}
+
+func (e *KeySizeError) Error() string {
+	if e.Algorithm == "" {
+		return fmt.Sprintf("key must be one of %v bytes, got %d", e.Valid, e.Size)
+	}
+	return fmt.Sprintf("%s key must be one of %v bytes, got %d", e.Algorithm, e.Valid, e.Size)
+}
+
+func (e *KeySizeError) Unwrap() error { return ErrInvalidKeySize }
+
+// BlockError - ошибка блочного шифра при обработке блока с номером Index (с нуля)
+type BlockError struct {
+	// Op - "encryption" или "decryption"
+	Op    string
+	Index int
+	Err   error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("%s failed at block %d: %v", e.Op, e.Index, e.Err)
+}
+
+func (e *BlockError) Unwrap() error { return e.Err }
+
+// encryptBlockError и decryptBlockError добавляют к ошибке шифра номер блока
+func encryptBlockError(index int, err error) error {
+	return &BlockError{Op: "encryption", Index: index, Err: err}
+}
+
+func decryptBlockError(index int, err error) error {
+	return &BlockError{Op: "decryption", Index: index, Err: err}
+}
+
+// blockSizeError - входной блок шифра имеет неверную длину
+func blockSizeError(want, got int) error {
+	return fmt.Errorf("%w: block must be %d bytes, got %d", ErrInvalidBlockSize, want, got)
+}
+
+// dataLengthError - длина данных не кратна размеру блока
+func dataLengthError(length, blockSize int) error {
+	return fmt.Errorf("%w: data length (%d) is not a multiple of block size (%d)", ErrInvalidBlockSize, length, blockSize)
+}
+
+// checkIVSize проверяет, что IV режима имеет длину size
+func checkIVSize(iv []byte, size int) error {
+	if len(iv) != size {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidIV, size, len(iv))
+	}
+	return nil
+}
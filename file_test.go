@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileMatchesEncrypt шифрует файлы длиннее одной части (fileChunkBlocks блоков):
+// набивка добавляется только к последней части, поэтому результат после заголовка KCV
+// должен совпасть с Encrypt всего содержимого, а дешифрование - вернуть исходный файл
+func TestFileMatchesEncrypt(t *testing.T) {
+	modes := []struct {
+		name string
+		opts []ContextOption
+	}{
+		{name: "ECB"}, {name: "CBC"}, {name: "PCBC"}, {name: "CFB"}, {name: "OFB"}, {name: "CTR"}, {name: "RandomDelta"},
+		{name: "CFB", opts: []ContextOption{WithSegmentSize(1)}},
+		{name: "CTR", opts: []ContextOption{WithCounterLayout(CounterLayout{Size: 4, LittleEndian: true})}},
+	}
+	for _, algName := range []string{"DES", "AES"} {
+		spec, _ := LookupAlgorithm(algName)
+		chunk := spec.BlockSize * fileChunkBlocks
+		for _, m := range modes {
+			mode, _ := LookupBlockMode(m.name)
+			blockMode, err := mode.BlockMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, size := range []int{0, 5, chunk, chunk + 1, 2*chunk + 5} {
+				random := NewTestRand("file/" + algName + "/" + m.name)
+				key, err := GenerateKey(algName, spec.KeySizes[0], random)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := randomBytes(random, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts := append([]ContextOption{WithMode(mode), WithPadding(PKCS7)}, m.opts...)
+				if ivSize := blockMode.IVSize(spec.BlockSize); ivSize > 0 {
+					iv, err := randomBytes(random, ivSize)
+					if err != nil {
+						t.Fatal(err)
+					}
+					opts = append(opts, WithIV(iv))
+				}
+				newContext := func() *CryptoSymmetricContext {
+					alg, err := spec.New()
+					if err != nil {
+						t.Fatal(err)
+					}
+					// Одинаковое зерно у каждого контекста, чтобы RandomDelta выбрал ту же delta
+					ctx, err := NewContext(key, alg, append(opts, WithRand(NewTestRand("file/rand")))...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return ctx
+				}
+
+				dir := t.TempDir()
+				plainPath := filepath.Join(dir, "plain")
+				encryptedPath := filepath.Join(dir, "encrypted")
+				decryptedPath := filepath.Join(dir, "decrypted")
+				if err := os.WriteFile(plainPath, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := <-newContext().EncryptFileAsync(plainPath, encryptedPath); err != nil {
+					t.Fatalf("%s/%s/%d: encrypt file: %v", algName, m.name, size, err)
+				}
+				encrypted, err := os.ReadFile(encryptedPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ctx := newContext()
+				header, err := ctx.kcvHeader()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.HasPrefix(encrypted, header) {
+					t.Fatalf("%s/%s/%d: file does not start with the key check value header", algName, m.name, size)
+				}
+				var want []byte
+				if size > 0 {
+					if want, err = ctx.Encrypt(data); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if !bytes.Equal(encrypted[len(header):], want) {
+					t.Errorf("%s/%s/%d: file ciphertext differs from Encrypt of the whole input", algName, m.name, size)
+				}
+
+				if err := <-newContext().DecryptFileAsync(encryptedPath, decryptedPath); err != nil {
+					t.Fatalf("%s/%s/%d: decrypt file: %v", algName, m.name, size, err)
+				}
+				decrypted, err := os.ReadFile(decryptedPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(decrypted, data) {
+					t.Errorf("%s/%s/%d: decrypted file differs from the original", algName, m.name, size)
+				}
+			}
+		}
+	}
+}
+
+// TestDecryptFileRequiresKCV: файл без заголовка KCV дешифруется только с WithAllowMissingKCV
+func TestDecryptFileRequiresKCV(t *testing.T) {
+	spec, _ := LookupAlgorithm("DES")
+	random := NewTestRand("file/kcv")
+	key, err := GenerateKey("DES", 8, random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := randomBytes(random, 3*spec.BlockSize*fileChunkBlocks/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newContext := func(opts ...ContextOption) *CryptoSymmetricContext {
+		alg, err := spec.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, err := NewContext(key, alg, append([]ContextOption{WithMode(ECB), WithPadding(PKCS7)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ctx
+	}
+	encrypted, err := newContext().Encrypt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	headerless := filepath.Join(dir, "headerless")
+	if err := os.WriteFile(headerless, encrypted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = <-newContext().DecryptFileAsync(headerless, filepath.Join(dir, "rejected"))
+	if !errors.Is(err, ErrMissingKCV) {
+		t.Fatalf("headerless file: got %v, want ErrMissingKCV", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "rejected")); !os.IsNotExist(err) {
+		t.Errorf("rejected file left an output behind: %v", err)
+	}
+
+	accepted := filepath.Join(dir, "accepted")
+	if err := <-newContext(WithAllowMissingKCV(true)).DecryptFileAsync(headerless, accepted); err != nil {
+		t.Fatalf("headerless file with WithAllowMissingKCV: %v", err)
+	}
+	decrypted, err := os.ReadFile(accepted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Error("headerless file decrypted to different data")
+	}
+}
+
+func TestKCVLengthIsExact(t *testing.T) {
+	for _, s := range []string{"", "AB", "ABCD", "ABCDEF01"} {
+		if _, err := parseKCVHex(s); err == nil {
+			t.Errorf("parseKCVHex(%q) accepted a %d-byte value", s, len(s)/2)
+		}
+	}
+	if _, err := parseKCVHex("ABCDEF"); err != nil {
+		t.Errorf("parseKCVHex(ABCDEF): %v", err)
+	}
+
+	alg, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext(bytes.Repeat([]byte{0x13}, 8), alg, WithMode(ECB), WithPadding(PKCS7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kcv, err := ctx.KCV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.verifyKCV(kcv); err != nil {
+		t.Errorf("verifyKCV of the own KCV: %v", err)
+	}
+	for _, n := range []int{0, 1, KCVSize + 1} {
+		header := append(append([]byte(nil), kcvMagic...), kcvHeaderVersion, byte(n))
+		header = append(header, make([]byte, n)...)
+		if _, _, err := ParseKCVHeader(append(header, 0xAA)); err == nil {
+			t.Errorf("ParseKCVHeader accepted a %d-byte key check value", n)
+		}
+	}
+	for _, bad := range [][]byte{nil, kcv[:1], kcv[:2], append(append([]byte(nil), kcv...), 0)} {
+		if err := ctx.verifyKCV(bad); err == nil {
+			t.Errorf("verifyKCV accepted a %d-byte key check value", len(bad))
+		}
+	}
+}
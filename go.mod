@@ -0,0 +1,3 @@
+module cryptolab
+
+go 1.24
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+// TestKnownAnswers прогоняет векторы самопроверки (SP 800-17, FIPS 81, CFB-8, KCV, HMAC_DRBG),
+// чтобы регрессия в DES, ключевом расписании или режимах ловилась и без команды selftest
+func TestKnownAnswers(t *testing.T) {
+	tests := KnownAnswerTests()
+	if len(tests) == 0 {
+		t.Fatal("no known-answer tests")
+	}
+	suites := make(map[string]int)
+	for _, kat := range tests {
+		suites[kat.Suite]++
+		if err := kat.Run(); err != nil {
+			t.Errorf("%s %s: %v", kat.Suite, kat.Name, err)
+		}
+	}
+	for _, suite := range []string{"SP 800-17 variable plaintext", "SP 800-17 variable key", "FIPS 81", "CFB-8", "KCV", "SP 800-90A HMAC_DRBG"} {
+		if suites[suite] == 0 {
+			t.Errorf("suite %q has no vectors", suite)
+		}
+	}
+}
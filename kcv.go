@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Контрольное значение ключа (KCV): первые байты шифрования нулевого блока,
+// как принято в банковской практике для ключей DES и 3DES. KCV записывается
+// в заголовок шифротекста и сверяется до дешифрования, поэтому неверный ключ
+// обнаруживается сразу, а не по мусору на выходе или ошибке набивки.
+// Три байта почти не сужают перебор ключа, но случайно совпадают лишь с вероятностью 2^-24.
+
+// KCVSize - длина KCV в байтах
+const KCVSize = 3
+
+// Заголовок шифротекста: магическое значение, версия, длина KCV, KCV.
+// Шифротекст без заголовка (записанный до появления KCV или с -kcv=false) отвергается
+// с ErrMissingKCV, если контекст явно не разрешает его (WithAllowMissingKCV).
+var kcvMagic = []byte("CLKV")
+
+const kcvHeaderVersion = 1
+
+// armorHeaderKCV - заголовок PEM с KCV в шестнадцатеричном виде
+const armorHeaderKCV = "KCV"
+
+// ErrKeyMismatch - KCV ключа не совпадает с записанным в заголовке;
+// errors.Is(ErrKeyMismatch, ErrAuthenticationFailed) истинно
+var ErrKeyMismatch = fmt.Errorf("%w: key check value does not match: wrong key", ErrAuthenticationFailed)
+
+// ErrMissingKCV - у шифротекста нет заголовка с KCV, и контекст не разрешает дешифровать его без проверки;
+// errors.Is(ErrMissingKCV, ErrAuthenticationFailed) истинно
+var ErrMissingKCV = fmt.Errorf("%w: ciphertext has no key check value header", ErrAuthenticationFailed)
+
+// ErrInvalidKCVHeader - заголовок с KCV обрезан, имеет неизвестную версию или длину KCV;
+// errors.Is(ErrInvalidKCVHeader, ErrAuthenticationFailed) истинно
+var ErrInvalidKCVHeader = fmt.Errorf("%w: invalid key check value header", ErrAuthenticationFailed)
+
+// ComputeKCV шифрует нулевой блок и возвращает первые KCVSize байт
+func ComputeKCV(block BlockCipher, blockSize int) ([]byte, error) {
+	if blockSize < KCVSize {
+		return nil, fmt.Errorf("%w: block size %d is too small for a key check value", ErrInvalidBlockSize, blockSize)
+	}
+	encrypted, err := block.Encrypt(make([]byte, blockSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < KCVSize {
+		return nil, fmt.Errorf("%w: cipher returned %d bytes for a %d-byte block", ErrInvalidBlockSize, len(encrypted), blockSize)
+	}
+	return encrypted[:KCVSize], nil
+}
+
+// KeyCheckValue вычисляет KCV ключа без создания контекста; кэш расписаний не используется
+func KeyCheckValue(alg SymmetricAlgorithm, key []byte, blockSize int) ([]byte, error) {
+	block, err := keyedCipherFor(nil, alg, key)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeKCV(block, blockSize)
+}
+
+// KCV возвращает контрольное значение текущего ключа контекста
+func (cstc *CryptoSymmetricContext) KCV() ([]byte, error) {
+	cstc.keyMu.RLock()
+	block := cstc.block
+	cstc.keyMu.RUnlock()
+	return ComputeKCV(block, cstc.blockSize)
+}
+
+// kcvHeader возвращает заголовок с KCV текущего ключа
+func (cstc *CryptoSymmetricContext) kcvHeader() ([]byte, error) {
+	kcv, err := cstc.KCV()
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(nil), kcvMagic...)
+	header = append(header, kcvHeaderVersion, byte(len(kcv)))
+	return append(header, kcv...), nil
+}
+
+// ParseKCVHeader отделяет заголовок с KCV от шифротекста.
+// Если заголовка нет, возвращает nil и data без изменений.
+func ParseKCVHeader(data []byte) (kcv, rest []byte, err error) {
+	if !bytes.HasPrefix(data, kcvMagic) {
+		return nil, data, nil
+	}
+	fixed := len(kcvMagic) + 2
+	if len(data) < fixed {
+		return nil, nil, fmt.Errorf("%w: truncated", ErrInvalidKCVHeader)
+	}
+	if version := data[len(kcvMagic)]; version != kcvHeaderVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidKCVHeader, version)
+	}
+	n := int(data[len(kcvMagic)+1])
+	if n != KCVSize {
+		return nil, nil, fmt.Errorf("%w: key check value length %d, want %d", ErrInvalidKCVHeader, n, KCVSize)
+	}
+	if len(data) < fixed+n {
+		return nil, nil, fmt.Errorf("%w: truncated", ErrInvalidKCVHeader)
+	}
+	return data[fixed : fixed+n], data[fixed+n:], nil
+}
+
+// verifyKCV сравнивает KCV из заголовка с KCV ключа контекста; KCV короче KCVSize не принимается
+func (cstc *CryptoSymmetricContext) verifyKCV(kcv []byte) error {
+	if len(kcv) != KCVSize {
+		return fmt.Errorf("%w: key check value length %d, want %d", ErrInvalidKCVHeader, len(kcv), KCVSize)
+	}
+	own, err := cstc.KCV()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(own, kcv) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+// withKCVHeader добавляет заголовок с KCV перед шифротекстом
+func (cstc *CryptoSymmetricContext) withKCVHeader(ciphertext []byte) ([]byte, error) {
+	header, err := cstc.kcvHeader()
+	if err != nil {
+		return nil, err
+	}
+	return append(header, ciphertext...), nil
+}
+
+// stripKCVHeader проверяет и снимает заголовок с KCV, если он есть
+func (cstc *CryptoSymmetricContext) stripKCVHeader(data []byte) ([]byte, error) {
+	kcv, rest, err := ParseKCVHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if kcv == nil {
+		if err := cstc.checkMissingKCV(); err != nil {
+			return nil, err
+		}
+		return rest, nil
+	}
+	if err := cstc.verifyKCV(kcv); err != nil {
+		return nil, err
+	}
+	return rest, nil
+}
+
+// checkMissingKCV отвергает шифротекст без заголовка, если контекст его не разрешает,
+// а иначе предупреждает, что неверный ключ не будет обнаружен до дешифрования
+func (cstc *CryptoSymmetricContext) checkMissingKCV() error {
+	if !cstc.allowMissingKCV {
+		return ErrMissingKCV
+	}
+	cstc.log().Warn("ciphertext has no key check value; a wrong key will not be detected before decryption")
+	return nil
+}
+
+// skipKCVHeader проверяет заголовок в начале файла и оставляет позицию сразу за ним.
+// Файл без заголовка перематывается в начало.
+func (cstc *CryptoSymmetricContext) skipKCVHeader(f *os.File) error {
+	fixed := make([]byte, len(kcvMagic)+2)
+	n, err := io.ReadFull(f, fixed)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if !bytes.HasPrefix(fixed[:n], kcvMagic) || n < len(fixed) {
+		if err := cstc.checkMissingKCV(); err != nil {
+			return err
+		}
+		_, err := f.Seek(0, io.SeekStart)
+		return err
+	}
+	header := append(fixed, make([]byte, fixed[len(fixed)-1])...)
+	if _, err := io.ReadFull(f, header[len(fixed):]); err != nil {
+		return fmt.Errorf("%w: truncated", ErrInvalidKCVHeader)
+	}
+	_, err = cstc.stripKCVHeader(header)
+	return err
+}
+
+// FormatKCV печатает KCV заглавными hex-цифрами, как в банковских ключевых бланках
+func FormatKCV(kcv []byte) string {
+	return fmt.Sprintf("%X", kcv)
+}
+
+// parseKCVHex разбирает KCV из заголовка PEM или командной строки
+func parseKCVHex(s string) ([]byte, error) {
+	kcv, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKCVHeader, err)
+	}
+	if len(kcv) != KCVSize {
+		return nil, fmt.Errorf("%w: key check value length %d, want %d", ErrInvalidKCVHeader, len(kcv), KCVSize)
+	}
+	return kcv, nil
+}
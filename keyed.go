@@ -0,0 +1,153 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Неизменяемые экземпляры шифров с установленным ключом и LRU-кэш расписаний ключей.
+// SetKey перестраивает раундовые ключи на месте, поэтому общий экземпляр алгоритма
+// нельзя использовать с разными ключами из разных горутин. Экземпляр, полученный
+// через NewKeyed, ключ не меняет и может свободно разделяться между горутинами.
+
+// BlockCipher - блочный шифр с зафиксированным ключом
+type BlockCipher interface {
+	Encrypt(block []byte) ([]byte, error)
+	Decrypt(block []byte) ([]byte, error)
+}
+
+// KeyedAlgorithm - алгоритм, умеющий создавать неизменяемые экземпляры для ключа.
+// Сам алгоритм при этом не изменяется.
+type KeyedAlgorithm interface {
+	NewKeyed(key []byte) (BlockCipher, error)
+}
+
+// keyedBlock скрывает SetKey у экземпляра, которым больше никто не владеет
+type keyedBlock struct {
+	alg SymmetricAlgorithm
+}
+
+func (k keyedBlock) Encrypt(block []byte) ([]byte, error) {
+	return k.alg.Encrypt(block)
+}
+
+func (k keyedBlock) Decrypt(block []byte) ([]byte, error) {
+	return k.alg.Decrypt(block)
+}
+
+// newKeyedBlock создает новый экземпляр алгоритма, устанавливает ключ и закрывает доступ к SetKey
+func newKeyedBlock(alg SymmetricAlgorithm, key []byte) (BlockCipher, error) {
+	if err := alg.SetKey(key); err != nil {
+		return nil, err
+	}
+	return keyedBlock{alg: alg}, nil
+}
+
+// KeyScheduleCache хранит последние использованные экземпляры с расписаниями ключей.
+// Полезен, когда один алгоритм часто переключается между небольшим набором ключей.
+// Расписания - это ключевой материал, поэтому кэш включается явно (WithKeyScheduleCache)
+// и живет столько, сколько его владелец; Purge удаляет все расписания.
+type KeyScheduleCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // от недавно использованных к давно использованным
+	entries  map[keyScheduleID]*list.Element
+}
+
+// keyScheduleID - алгоритм и хеш ключа; сам ключ в качестве ключа карты не хранится
+type keyScheduleID struct {
+	alg    KeyedAlgorithm
+	digest [sha256.Size]byte
+}
+
+type keyScheduleEntry struct {
+	id    keyScheduleID
+	block BlockCipher
+}
+
+// NewKeyScheduleCache создает кэш на capacity расписаний
+func NewKeyScheduleCache(capacity int) (*KeyScheduleCache, error) {
+	if capacity <= 0 {
+		return nil, errors.New("cache capacity must be positive")
+	}
+	return &KeyScheduleCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[keyScheduleID]*list.Element),
+	}, nil
+}
+
+// Get возвращает экземпляр alg для ключа, создавая его при промахе.
+// Экземпляры различаются по самому алгоритму, поэтому разные объекты одного типа
+// (например, адаптеры разных шифров crypto/cipher) не смешиваются.
+// Алгоритмы несравнимых типов в кэш не попадают.
+func (c *KeyScheduleCache) Get(alg KeyedAlgorithm, key []byte) (BlockCipher, error) {
+	if alg == nil {
+		return nil, errors.New("algorithm is nil")
+	}
+	if !reflect.TypeOf(alg).Comparable() {
+		return alg.NewKeyed(key)
+	}
+	id := keyScheduleID{alg: alg, digest: sha256.Sum256(key)}
+
+	c.mu.Lock()
+	if elem, ok := c.entries[id]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*keyScheduleEntry).block, nil
+	}
+	c.mu.Unlock()
+
+	// Расписание строится без блокировки: при гонке двух промахов победит любой из равных экземпляров
+	block, err := alg.NewKeyed(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*keyScheduleEntry).block, nil
+	}
+	c.entries[id] = c.order.PushFront(&keyScheduleEntry{id: id, block: block})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*keyScheduleEntry).id)
+	}
+	return block, nil
+}
+
+// Len возвращает число расписаний в кэше
+func (c *KeyScheduleCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Purge очищает кэш
+func (c *KeyScheduleCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[keyScheduleID]*list.Element)
+}
+
+// keyedCipherFor возвращает неизменяемый экземпляр для ключа. Алгоритмы без NewKeyed
+// получают ключ через SetKey и используются как есть, как и раньше.
+func keyedCipherFor(cache *KeyScheduleCache, alg SymmetricAlgorithm, key []byte) (BlockCipher, error) {
+	if keyed, ok := alg.(KeyedAlgorithm); ok {
+		if cache == nil {
+			return keyed.NewKeyed(key)
+		}
+		return cache.Get(keyed, key)
+	}
+	if err := alg.SetKey(key); err != nil {
+		return nil, err
+	}
+	return alg, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// countingDES считает, сколько раз для него строилось расписание ключей
+type countingDES struct {
+	*DES
+	schedules int
+}
+
+func (c *countingDES) NewKeyed(key []byte) (BlockCipher, error) {
+	c.schedules++
+	return c.DES.NewKeyed(key)
+}
+
+func newCountingDES(t *testing.T) *countingDES {
+	t.Helper()
+	des, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &countingDES{DES: des}
+}
+
+// TestKeyScheduleCacheIsolation: попадания в кэш бывают только для того же
+// экземпляра алгоритма и того же ключа; Purge удаляет все расписания
+func TestKeyScheduleCacheIsolation(t *testing.T) {
+	cache, err := NewKeyScheduleCache(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := newCountingDES(t), newCountingDES(t)
+	keyA := mustDecodeHex("133457799bbcdff1")
+	keyB := mustDecodeHex("0e329232ea6d0d73")
+
+	steps := []struct {
+		name string
+		alg  *countingDES
+		key  []byte
+		hit  bool
+	}{
+		{"first algorithm, key A", first, keyA, false},
+		{"first algorithm, key A again", first, keyA, true},
+		{"first algorithm, key B", first, keyB, false},
+		{"second algorithm, key A", second, keyA, false},
+		{"second algorithm, key A again", second, keyA, true},
+		{"first algorithm, key B again", first, keyB, true},
+	}
+	blocks := make(map[*countingDES]map[string]BlockCipher)
+	for _, step := range steps {
+		before := step.alg.schedules
+		block, err := cache.Get(step.alg, step.key)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if hit := step.alg.schedules == before; hit != step.hit {
+			t.Errorf("%s: hit = %v, want %v", step.name, hit, step.hit)
+		}
+		if blocks[step.alg] == nil {
+			blocks[step.alg] = make(map[string]BlockCipher)
+		}
+		if cached, ok := blocks[step.alg][string(step.key)]; ok && cached != block {
+			t.Errorf("%s: cache returned a different instance", step.name)
+		}
+		blocks[step.alg][string(step.key)] = block
+	}
+	if got := cache.Len(); got != 3 {
+		t.Errorf("Len() = %d, want 3", got)
+	}
+
+	// Экземпляры для разных ключей шифруют по-разному
+	plaintext := mustDecodeHex("0123456789abcdef")
+	a, err := blocks[first][string(keyA)].Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := blocks[first][string(keyB)].Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("cached instances for different keys produce the same ciphertext")
+	}
+
+	cache.Purge()
+	if got := cache.Len(); got != 0 {
+		t.Errorf("Len() after Purge = %d, want 0", got)
+	}
+	before := first.schedules
+	if _, err := cache.Get(first, keyA); err != nil {
+		t.Fatal(err)
+	}
+	if first.schedules == before {
+		t.Error("Get after Purge returned a cached key schedule")
+	}
+}
+
+// TestContextKeyScheduleCacheIsOptIn: без WithKeyScheduleCache контекст строит
+// расписание заново при каждом SetKey, с ним - берет его из общего кэша
+func TestContextKeyScheduleCacheIsOptIn(t *testing.T) {
+	key := mustDecodeHex("133457799bbcdff1")
+	alg := newCountingDES(t)
+	for i := 0; i < 2; i++ {
+		if _, err := NewContext(key, alg, WithMode(ECB), WithPadding(PKCS7)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if alg.schedules != 2 {
+		t.Errorf("without a cache: %d key schedules for 2 contexts, want 2", alg.schedules)
+	}
+
+	cache, err := NewKeyScheduleCache(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alg = newCountingDES(t)
+	for i := 0; i < 2; i++ {
+		if _, err := NewContext(key, alg, WithMode(ECB), WithPadding(PKCS7), WithKeyScheduleCache(cache)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if alg.schedules != 1 {
+		t.Errorf("with a shared cache: %d key schedules for 2 contexts, want 1", alg.schedules)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("cache holds %d key schedules, want 1", cache.Len())
+	}
+
+	if _, err := NewContext(key, alg, WithKeyScheduleCache(nil)); err == nil {
+		t.Error("WithKeyScheduleCache(nil) was accepted")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Генерация ключей и векторов инициализации
+
+// ErrWeakRandomKeys возвращается GenerateKey, если источник случайности раз за разом дает слабые ключи
+var ErrWeakRandomKeys = errors.New("random source keeps producing weak keys")
+
+// keygenAttempts ограничивает число повторов при выпадении слабого ключа;
+// для исправного генератора случайных чисел хватает одной попытки
+const keygenAttempts = 16
+
+// GenerateKey создает случайный ключ длины size (0 - первая из допустимых длин) для алгоритма из реестра.
+// Для алгоритмов на основе DES выставляется нечетная четность и исключаются
+// слабые, полуслабые и возможно слабые ключи, в том числе среди раундовых ключей DEAL.
+func GenerateKey(algorithm string, size int, random io.Reader) ([]byte, error) {
+	spec, ok := LookupAlgorithm(algorithm)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown algorithm %s", ErrInvalidArgument, algorithm)
+	}
+	if size == 0 {
+		size = spec.KeySizes[0]
+	}
+	if !containsInt(spec.KeySizes, size) {
+		return nil, &KeySizeError{Algorithm: spec.Name, Size: size, Valid: spec.KeySizes}
+	}
+
+	for attempt := 0; attempt < keygenAttempts; attempt++ {
+		key := make([]byte, size)
+		if _, err := io.ReadFull(random, key); err != nil {
+			return nil, fmt.Errorf("failed to read random key: %w", err)
+		}
+		if !spec.DESKeys {
+			return key, nil
+		}
+
+		key = FixDESKeyParity(key)
+		reports, warnings, err := CheckKeyForAlgorithms(key)
+		if err != nil {
+			return nil, err
+		}
+		if !hasWeakDESKey(reports, warnings) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d attempts", ErrWeakRandomKeys, keygenAttempts)
+}
+
+func hasWeakDESKey(reports map[string][]DESKeyReport, warnings []string) bool {
+	if len(warnings) > 0 {
+		return true
+	}
+	for _, list := range reports {
+		for _, r := range list {
+			if r.Class != DESKeyNormal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GenerateIV создает случайный IV нужной режиму длины; для режимов без IV возвращает nil.
+// Для CTR это начальное значение счетчика, которое не должно повторяться для одного ключа.
+func GenerateIV(mode CipherMode, blockSize int, random io.Reader) ([]byte, error) {
+	blockMode, err := mode.BlockMode()
+	if err != nil {
+		return nil, err
+	}
+	size := blockMode.IVSize(blockSize)
+	if size == 0 {
+		return nil, nil
+	}
+	iv := make([]byte, size)
+	if _, err := io.ReadFull(random, iv); err != nil {
+		return nil, fmt.Errorf("failed to read random IV: %w", err)
+	}
+	return iv, nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// countingReader отдает байты src и считает, сколько их прочитано
+type countingReader struct {
+	src  io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	r.read += n
+	return n, err
+}
+
+// zeroReader бесконечно отдает нули: после исправления четности это слабый ключ 0101010101010101
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestGenerateKeyHasOddParity(t *testing.T) {
+	random := NewTestRand("keygen/parity")
+	for _, name := range AlgorithmNames() {
+		spec, _ := LookupAlgorithm(name)
+		if !spec.DESKeys {
+			continue
+		}
+		for _, size := range spec.KeySizes {
+			for i := 0; i < 32; i++ {
+				key, err := GenerateKey(name, size, random)
+				if err != nil {
+					t.Fatalf("%s/%d: %v", name, size, err)
+				}
+				if len(key) != size {
+					t.Fatalf("%s/%d: got a %d-byte key", name, size, len(key))
+				}
+				if !DESKeyParityOK(key) {
+					t.Errorf("%s/%d: key %x does not have odd parity", name, size, key)
+				}
+				reports, warnings, err := CheckKeyForAlgorithms(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hasWeakDESKey(reports, warnings) {
+					t.Errorf("%s/%d: generated weak key %x", name, size, key)
+				}
+			}
+		}
+	}
+}
+
+// TestGenerateKeyRetriesWeakKeys: первый ключ из источника слабый, GenerateKey
+// должен отбросить его и вернуть второй
+func TestGenerateKeyRetriesWeakKeys(t *testing.T) {
+	normal := mustDecodeHex("133457799bbcdff10e329232ea6d0d73")
+	tests := []struct {
+		algorithm string
+		size      int
+		weak      string
+	}{
+		{"DES", 8, "0000000000000000"},
+		{"DES", 8, "fe01fe01fe01fe01"}, // полуслабый
+		{"DES", 8, "1f1f01010e0e0101"}, // возможно слабый
+		{"DEAL", 16, "133457799bbcdff10000000000000000"},
+		{"DEAL", 16, "133457799bbcdff1133457799bbcdff1"}, // совпадающие ключи 3DES
+	}
+	for _, tt := range tests {
+		want := normal[:tt.size]
+		random := &countingReader{src: bytes.NewReader(append(mustDecodeHex(tt.weak), want...))}
+		key, err := GenerateKey(tt.algorithm, tt.size, random)
+		if err != nil {
+			t.Errorf("%s after weak key %s: %v", tt.algorithm, tt.weak, err)
+			continue
+		}
+		if !bytes.Equal(key, want) {
+			t.Errorf("%s after weak key %s: got %x, want %x", tt.algorithm, tt.weak, key, want)
+		}
+		if random.read != 2*tt.size {
+			t.Errorf("%s after weak key %s: read %d random bytes, want %d", tt.algorithm, tt.weak, random.read, 2*tt.size)
+		}
+	}
+}
+
+func TestGenerateKeyFailsOnWeakSource(t *testing.T) {
+	for _, name := range []string{"DES", "DEAL"} {
+		random := &countingReader{src: zeroReader{}}
+		_, err := GenerateKey(name, 0, random)
+		if !errors.Is(err, ErrWeakRandomKeys) {
+			t.Errorf("%s: error = %v, want ErrWeakRandomKeys", name, err)
+		}
+		spec, _ := LookupAlgorithm(name)
+		if want := keygenAttempts * spec.KeySizes[0]; random.read != want {
+			t.Errorf("%s: read %d random bytes, want %d", name, random.read, want)
+		}
+	}
+}
+
+func TestGenerateKeyErrors(t *testing.T) {
+	// Ключ без DES внутри возвращается как есть
+	want := bytes.Repeat([]byte{0x01}, 16)
+	key, err := GenerateKey("AES", 16, bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, want) {
+		t.Errorf("AES key = %x, want the random bytes %x", key, want)
+	}
+
+	if _, err := GenerateKey("DES", 8, bytes.NewReader(make([]byte, 5))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("short random source: error = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err := GenerateKey("Blowfish", 0, zeroReader{}); err == nil {
+		t.Error("unknown algorithm was accepted")
+	}
+	if _, err := GenerateKey("DES", 16, zeroReader{}); err == nil {
+		t.Error("unsupported key size was accepted")
+	}
+}
+
+func TestGenerateIV(t *testing.T) {
+	for _, name := range BlockModeNames() {
+		mode, _ := LookupBlockMode(name)
+		blockMode, err := mode.BlockMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, blockSize := range []int{8, 16} {
+			random := &countingReader{src: NewTestRand("keygen/iv/" + name)}
+			iv, err := GenerateIV(mode, blockSize, random)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", name, blockSize, err)
+			}
+			size := blockMode.IVSize(blockSize)
+			if size == 0 {
+				if iv != nil || random.read != 0 {
+					t.Errorf("%s/%d: mode without IV returned %x and read %d bytes", name, blockSize, iv, random.read)
+				}
+				continue
+			}
+			if len(iv) != size || random.read != size {
+				t.Errorf("%s/%d: IV of %d bytes after reading %d, want %d", name, blockSize, len(iv), random.read, size)
+			}
+			// Другой источник - другой IV
+			other, err := GenerateIV(mode, blockSize, NewTestRand("keygen/iv/other"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(iv, other) {
+				t.Errorf("%s/%d: different random sources gave the same IV", name, blockSize)
+			}
+		}
+	}
+
+	if _, err := GenerateIV(CBC, 8, bytes.NewReader(make([]byte, 3))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("short random source: error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
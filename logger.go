@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"sync/atomic"
+)
+
+// Диагностический вывод библиотеки идет только через log/slog. По умолчанию логгер
+// отбрасывает все записи, поэтому ни stdout, ни stderr вызывающей программы не засоряются.
+// Ключи, раундовые ключи и IV в записях всегда заменяются отметкой с длиной; показать их
+// можно только явно через SetLogSecrets(true), и только в записях уровня Debug,
+// которые пишутся, если логгер включен на этом уровне (например, на занятиях).
+
+var (
+	defaultLogger atomic.Pointer[slog.Logger]
+	logSecrets    atomic.Bool
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// SetLogger задает логгер библиотеки; nil отключает вывод
+func SetLogger(l *slog.Logger) {
+	defaultLogger.Store(l)
+}
+
+// Logger возвращает логгер библиотеки; без SetLogger записи отбрасываются
+func Logger() *slog.Logger {
+	if l := defaultLogger.Load(); l != nil {
+		return l
+	}
+	return discardLogger
+}
+
+// SetLogSecrets разрешает выводить ключевой материал и IV в отладочных записях.
+// Только для учебных целей: значения попадут в журнал открытым текстом.
+func SetLogSecrets(enabled bool) {
+	logSecrets.Store(enabled)
+}
+
+// secretValue - ключ или IV в записи журнала; выводится только длина.
+// Открытое значение подставляет logDebug при SetLogSecrets(true), поэтому в записи
+// других уровней ключевой материал не попадает, даже если его туда передать.
+type secretValue []byte
+
+func (s secretValue) LogValue() slog.Value {
+	return slog.GroupValue(slog.Bool("redacted", true), slog.Int("len", len(s)))
+}
+
+// revealSecret заменяет скрытое значение атрибута шестнадцатеричной строкой
+func revealSecret(a slog.Attr) slog.Attr {
+	if s, ok := a.Value.Any().(secretValue); ok {
+		return slog.String(a.Key, hex.EncodeToString(s))
+	}
+	return a
+}
+
+// secretAttr создает атрибут с ключевым материалом
+func secretAttr(key string, value []byte) slog.Attr {
+	return slog.Any(key, secretValue(append([]byte(nil), value...)))
+}
+
+// logDebug пишет отладочную запись, не вычисляя атрибуты, если уровень Debug выключен
+func logDebug(l *slog.Logger, msg string, attrs func() []slog.Attr) {
+	if l == nil {
+		l = Logger()
+	}
+	ctx := context.Background()
+	if !l.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	list := attrs()
+	if logSecrets.Load() {
+		for i, a := range list {
+			list[i] = revealSecret(a)
+		}
+	}
+	l.LogAttrs(ctx, slog.LevelDebug, msg, list...)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+const (
+	logTestKey      = "133457799bbcdff10e329232ea6d0d73"
+	logTestRoundKey = "0e329232ea6d0d73"
+)
+
+// debugLogger пишет записи уровня level и выше в buf
+func debugLogger(buf *bytes.Buffer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: level}))
+}
+
+// withLogState восстанавливает логгер библиотеки и SetLogSecrets после теста
+func withLogState(t *testing.T) {
+	t.Helper()
+	saved, secrets := defaultLogger.Load(), logSecrets.Load()
+	t.Cleanup(func() {
+		defaultLogger.Store(saved)
+		logSecrets.Store(secrets)
+	})
+}
+
+// logKeyMaterial создает контекст DEAL с журналом logger и расписание ключей DEAL
+// с логгером библиотеки: обе записи содержат ключевой материал
+func logKeyMaterial(t *testing.T, logger *slog.Logger) {
+	t.Helper()
+	deal, err := NewDEAL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []ContextOption{WithMode(CBC), WithIV(mustDecodeHex("00112233445566778899aabbccddeeff"))}
+	if logger != nil {
+		opts = append(opts, WithLogger(logger))
+	}
+	if _, err := NewContext(mustDecodeHex(logTestKey), deal, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecretsRedactedByDefault(t *testing.T) {
+	withLogState(t)
+	SetLogSecrets(false)
+	var buf bytes.Buffer
+	logger := debugLogger(&buf, slog.LevelDebug)
+	SetLogger(logger)
+
+	logKeyMaterial(t, logger)
+	out := buf.String()
+	for _, want := range []string{"context created", "DEAL round key", "key.redacted=true", "key.len=16", "key.len=8", "iv.len=16"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not contain %q:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{logTestKey, logTestRoundKey, "00112233445566778899aabbccddeeff"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log reveals %s by default:\n%s", secret, out)
+		}
+	}
+}
+
+func TestSetLogSecretsRevealsOnlyAtDebug(t *testing.T) {
+	withLogState(t)
+	SetLogSecrets(true)
+
+	var debug bytes.Buffer
+	logger := debugLogger(&debug, slog.LevelDebug)
+	SetLogger(logger)
+	logKeyMaterial(t, logger)
+	for _, secret := range []string{"key=" + logTestKey, "key=" + logTestRoundKey, "iv=00112233445566778899aabbccddeeff"} {
+		if !strings.Contains(debug.String(), secret) {
+			t.Errorf("debug log with SetLogSecrets(true) does not contain %q:\n%s", secret, debug.String())
+		}
+	}
+
+	// Записи других уровней ключевой материал не раскрывают
+	var info bytes.Buffer
+	infoLogger := debugLogger(&info, slog.LevelDebug)
+	infoLogger.LogAttrs(t.Context(), slog.LevelInfo, "info record", secretAttr("key", mustDecodeHex(logTestKey)))
+	infoLogger.Warn("warn record", secretAttr("key", mustDecodeHex(logTestKey)))
+	if out := info.String(); strings.Contains(out, logTestKey) || strings.Count(out, "key.redacted=true") != 2 {
+		t.Errorf("info and warn records with SetLogSecrets(true):\n%s", out)
+	}
+
+	// Логгер, выключенный на уровне Debug, не получает ни записей, ни значений
+	var quiet bytes.Buffer
+	quietLogger := debugLogger(&quiet, slog.LevelInfo)
+	SetLogger(quietLogger)
+	logKeyMaterial(t, quietLogger)
+	if quiet.Len() != 0 {
+		t.Errorf("logger at Info level received debug records:\n%s", quiet.String())
+	}
+}
+
+// TestLibraryLogsNothingByDefault: без SetLogger и WithLogger библиотека ничего не пишет
+// ни в stdout и stderr, ни в slog.Default, и не вычисляет атрибуты отладочных записей
+func TestLibraryLogsNothingByDefault(t *testing.T) {
+	withLogState(t)
+	SetLogger(nil)
+	SetLogSecrets(true)
+
+	var slogDefault bytes.Buffer
+	saved := slog.Default()
+	slog.SetDefault(debugLogger(&slogDefault, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(saved) })
+
+	stdout, stderr := captureOutput(t, func() {
+		logKeyMaterial(t, nil)
+		alg, err := NewDEAL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, err := NewContext(mustDecodeHex(logTestKey), alg, WithMode(OFB), WithIV(make([]byte, 16)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ctx.Encrypt([]byte("attack at dawn")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if stdout != "" || stderr != "" || slogDefault.Len() != 0 {
+		t.Errorf("library wrote output without a logger: stdout %q, stderr %q, slog.Default %q", stdout, stderr, slogDefault.String())
+	}
+
+	called := false
+	logDebug(nil, "unused", func() []slog.Attr {
+		called = true
+		return nil
+	})
+	if called {
+		t.Error("logDebug built attributes for a discarded record")
+	}
+}
@@ -0,0 +1,769 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// ModeParams - параметры, которые контекст передает режиму шифрования
+type ModeParams struct {
+	Cipher    BlockCipher
+	BlockSize int
+	IV        []byte
+	// Workers - число горутин для параллельных режимов; 0 - runtime.GOMAXPROCS(0)
+	Workers int
+	// Rand - источник случайности для режимов, которым она нужна; nil - crypto/rand
+	Rand io.Reader
+	// SegmentSize - размер сегмента CFB в байтах; 0 - размер блока
+	SegmentSize int
+	// Counter - раскладка блока счетчика CTR
+	Counter CounterLayout
+	// Logger - журнал отладочных записей режима; nil - логгер библиотеки
+	Logger *slog.Logger
+}
+
+// forEachBlock вызывает fn для блоков 0..numBlocks-1, разделив их на непрерывные
+// диапазоны между workers горутинами. Возвращает ошибку блока с наименьшим номером.
+func forEachBlock(numBlocks, workers int, fn func(blockIndex int) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > numBlocks {
+		workers = numBlocks
+	}
+	if workers <= 1 {
+		for i := 0; i < numBlocks; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w * numBlocks / workers; i < (w+1)*numBlocks/workers; i++ {
+				if err := fn(i); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Диапазоны идут по порядку, поэтому первая найденная ошибка относится к наименьшему блоку
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlockMode - интерфейс режима шифрования.
+// Реализации не должны хранить состояние между вызовами: всё необходимое передается через ModeParams.
+type BlockMode interface {
+	// Name возвращает имя режима, под которым он доступен в реестре
+	Name() string
+	Encrypt(p ModeParams, data []byte) ([]byte, error)
+	Decrypt(p ModeParams, data []byte) ([]byte, error)
+	// IVSize возвращает требуемую длину IV для заданного размера блока (0 - IV не нужен)
+	IVSize(blockSize int) int
+	// NeedsPadding сообщает, должны ли данные быть кратны размеру блока
+	NeedsPadding() bool
+	// Parallelizable сообщает, обрабатываются ли блоки независимо друг от друга
+	Parallelizable() bool
+}
+
+// SegmentedMode - необязательный интерфейс режима, который обрабатывает данные сегментами
+// короче блока (например, CFB-8). Без него данные режимов с набивкой выравниваются по блоку.
+type SegmentedMode interface {
+	// SegmentSize возвращает размер сегмента в байтах для параметров p
+	SegmentSize(p ModeParams) int
+}
+
+// CounterMode - необязательный интерфейс режима, который шифрует блоки счетчика
+// и учитывает ModeParams.Counter. WithCounterLayout принимается только такими режимами.
+type CounterMode interface {
+	// CounterBlockSize возвращает длину блока счетчика для размера блока шифра;
+	// CounterLayout.Size не может ее превышать
+	CounterBlockSize(blockSize int) int
+}
+
+// ChainedMode - необязательный интерфейс режима, который может продолжить шифрование
+// с того места, где остановился предыдущий вызов. Так файлы шифруются частями
+// с тем же результатом, что и целиком. Без него файл обрабатывается одним вызовом.
+type ChainedMode interface {
+	// NextIV возвращает IV для данных, следующих за plaintext, который при параметрах p
+	// дал ciphertext. Длина обоих кратна размеру блока.
+	NextIV(p ModeParams, plaintext, ciphertext []byte) ([]byte, error)
+}
+
+// Реестр режимов шифрования. Индекс в срезе совпадает со значением CipherMode.
+var (
+	modeRegistryMu sync.RWMutex
+	modeRegistry   []BlockMode
+	modeByName     = make(map[string]CipherMode)
+)
+
+// Встроенные режимы регистрируются в порядке констант ECB..RandomDelta
+func init() {
+	builtin := []BlockMode{
+		ecbMode{}, cbcMode{}, pcbcMode{}, cfbMode{}, ofbMode{}, ctrMode{}, randomDeltaMode{},
+	}
+	for _, m := range builtin {
+		if _, err := RegisterBlockMode(m); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterBlockMode добавляет режим в реестр и возвращает присвоенный ему идентификатор
+func RegisterBlockMode(m BlockMode) (CipherMode, error) {
+	if m == nil {
+		return 0, errors.New("block mode is nil")
+	}
+	name := m.Name()
+	if name == "" {
+		return 0, errors.New("block mode name is empty")
+	}
+
+	modeRegistryMu.Lock()
+	defer modeRegistryMu.Unlock()
+
+	if _, exists := modeByName[name]; exists {
+		return 0, fmt.Errorf("block mode %q is already registered", name)
+	}
+	id := CipherMode(len(modeRegistry))
+	modeRegistry = append(modeRegistry, m)
+	modeByName[name] = id
+	return id, nil
+}
+
+// LookupBlockMode ищет режим по имени
+func LookupBlockMode(name string) (CipherMode, bool) {
+	modeRegistryMu.RLock()
+	defer modeRegistryMu.RUnlock()
+	id, ok := modeByName[name]
+	return id, ok
+}
+
+// BlockModeNames возвращает имена всех зарегистрированных режимов в порядке регистрации
+func BlockModeNames() []string {
+	modeRegistryMu.RLock()
+	defer modeRegistryMu.RUnlock()
+	names := make([]string, len(modeRegistry))
+	for i, m := range modeRegistry {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+// BlockMode возвращает реализацию режима по его идентификатору
+func (m CipherMode) BlockMode() (BlockMode, error) {
+	modeRegistryMu.RLock()
+	defer modeRegistryMu.RUnlock()
+	if m < 0 || int(m) >= len(modeRegistry) {
+		return nil, fmt.Errorf("%w: CipherMode(%d)", ErrUnsupportedMode, int(m))
+	}
+	return modeRegistry[m], nil
+}
+
+func (m CipherMode) String() string {
+	bm, err := m.BlockMode()
+	if err != nil {
+		return fmt.Sprintf("CipherMode(%d)", int(m))
+	}
+	return bm.Name()
+}
+
+// Реализация режима ECB с распараллеливанием
+type ecbMode struct{}
+
+func (ecbMode) Name() string             { return "ECB" }
+func (ecbMode) IVSize(blockSize int) int { return 0 }
+func (ecbMode) NeedsPadding() bool       { return true }
+func (ecbMode) Parallelizable() bool     { return true }
+
+// NextIV: блоки ECB не связаны друг с другом, IV не нужен
+func (ecbMode) NextIV(p ModeParams, plaintext, ciphertext []byte) ([]byte, error) {
+	return nil, nil
+}
+
+func (ecbMode) Encrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+
+	// Проверка, что длина данных кратна размеру блока
+	if len(data)%blockSize != 0 {
+		return nil, dataLengthError(len(data), blockSize)
+	}
+
+	numBlocks := len(data) / blockSize
+	encrypted := make([]byte, len(data))
+
+	err := forEachBlock(numBlocks, p.Workers, func(blockIndex int) error {
+		bs := blockIndex * blockSize
+		block := data[bs : bs+blockSize]
+
+		// Используем метод Encrypt блочного шифра
+		encryptedBlock, err := p.Cipher.Encrypt(block)
+		if err != nil {
+			return encryptBlockError(blockIndex, err)
+		}
+		copy(encrypted[bs:], encryptedBlock)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return encrypted, nil
+}
+
+func (ecbMode) Decrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+
+	// Проверка, что длина данных кратна размеру блока
+	if len(data)%blockSize != 0 {
+		return nil, dataLengthError(len(data), blockSize)
+	}
+
+	numBlocks := len(data) / blockSize
+	decrypted := make([]byte, len(data))
+
+	err := forEachBlock(numBlocks, p.Workers, func(blockIndex int) error {
+		bs := blockIndex * blockSize
+		block := data[bs : bs+blockSize]
+
+		// Используем метод Decrypt блочного шифра
+		decryptedBlock, err := p.Cipher.Decrypt(block)
+		if err != nil {
+			return decryptBlockError(blockIndex, err)
+		}
+		copy(decrypted[bs:], decryptedBlock)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decrypted, nil
+}
+
+// Реализация режима CBC без распараллеливания
+type cbcMode struct{}
+
+func (cbcMode) Name() string             { return "CBC" }
+func (cbcMode) IVSize(blockSize int) int { return blockSize }
+func (cbcMode) NeedsPadding() bool       { return true }
+func (cbcMode) Parallelizable() bool     { return false }
+
+// NextIV: следующий блок сцепляется с последним блоком шифротекста
+func (cbcMode) NextIV(p ModeParams, plaintext, ciphertext []byte) ([]byte, error) {
+	return lastBlock(ciphertext, p.BlockSize)
+}
+
+func (cbcMode) Encrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+	if err := checkIVSize(p.IV, blockSize); err != nil {
+		return nil, err
+	}
+
+	if len(data)%blockSize != 0 {
+		return nil, dataLengthError(len(data), blockSize)
+	}
+
+	encrypted := make([]byte, len(data))
+	numBlocks := len(data) / blockSize
+	previous := make([]byte, blockSize)
+	copy(previous, p.IV)
+
+	for i := 0; i < numBlocks; i++ {
+		start := i * blockSize
+		block := data[start : start+blockSize]
+
+		// XOR текущего блока с предыдущим зашифрованным блоком
+		inputBlock := make([]byte, blockSize)
+		for j := 0; j < blockSize; j++ {
+			inputBlock[j] = block[j] ^ previous[j]
+		}
+
+		// Шифруем блок
+		encryptedBlock, err := p.Cipher.Encrypt(inputBlock)
+		if err != nil {
+			return nil, encryptBlockError(i, err)
+		}
+
+		copy(encrypted[start:], encryptedBlock)
+		copy(previous, encryptedBlock)
+	}
+
+	return encrypted, nil
+}
+
+func (cbcMode) Decrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+	if err := checkIVSize(p.IV, blockSize); err != nil {
+		return nil, err
+	}
+
+	if len(data)%blockSize != 0 {
+		return nil, dataLengthError(len(data), blockSize)
+	}
+
+	decrypted := make([]byte, len(data))
+	numBlocks := len(data) / blockSize
+	previous := make([]byte, blockSize)
+	copy(previous, p.IV)
+
+	for i := 0; i < numBlocks; i++ {
+		start := i * blockSize
+		block := data[start : start+blockSize]
+
+		// Расшифровка текущего блока
+		decryptedBlock, err := p.Cipher.Decrypt(block)
+		if err != nil {
+			return nil, decryptBlockError(i, err)
+		}
+
+		// XOR с предыдущим зашифрованным блоком
+		for j := 0; j < blockSize; j++ {
+			decrypted[start+j] = decryptedBlock[j] ^ previous[j]
+		}
+
+		// Обновляем previous для следующего блока
+		copy(previous, block)
+	}
+
+	return decrypted, nil
+}
+
+// Реализация режима PCBC без распараллеливания
+type pcbcMode struct{}
+
+func (pcbcMode) Name() string             { return "PCBC" }
+func (pcbcMode) IVSize(blockSize int) int { return blockSize }
+func (pcbcMode) NeedsPadding() bool       { return true }
+func (pcbcMode) Parallelizable() bool     { return false }
+
+// NextIV: следующий блок сцепляется с XOR последних блоков открытого текста и шифротекста
+func (pcbcMode) NextIV(p ModeParams, plaintext, ciphertext []byte) ([]byte, error) {
+	return xorLastBlocks(plaintext, ciphertext, p.BlockSize)
+}
+
+func (pcbcMode) Encrypt(p ModeParams, data []byte) ([]byte, error) {
+	// Из-за зависимости между блоками распараллеливание ограничено
+	blockSize := p.BlockSize
+	if err := checkIVSize(p.IV, blockSize); err != nil {
+		return nil, err
+	}
+
+	if len(data)%blockSize != 0 {
+		return nil, dataLengthError(len(data), blockSize)
+	}
+
+	encrypted := make([]byte, len(data))
+	numBlocks := len(data) / blockSize
+	previousPlaintext := make([]byte, blockSize)
+	previousCiphertext := make([]byte, blockSize)
+	copy(previousCiphertext, p.IV)
+
+	for i := 0; i < numBlocks; i++ {
+		bs := i * blockSize
+		plaintextBlock := data[bs : bs+blockSize]
+
+		// XOR текущего блока с предыдущими
+		inputBlock := make([]byte, blockSize)
+		for j := 0; j < blockSize; j++ {
+			inputBlock[j] = plaintextBlock[j] ^ previousPlaintext[j] ^ previousCiphertext[j]
+		}
+
+		encryptedBlock, err := p.Cipher.Encrypt(inputBlock)
+		if err != nil {
+			return nil, encryptBlockError(i, err)
+		}
+
+		copy(encrypted[bs:], encryptedBlock)
+		copy(previousPlaintext, plaintextBlock)
+		copy(previousCiphertext, encryptedBlock)
+	}
+
+	return encrypted, nil
+}
+
+func (pcbcMode) Decrypt(p ModeParams, data []byte) ([]byte, error) {
+	// Из-за зависимости между блоками распараллеливание ограничено
+	blockSize := p.BlockSize
+	if err := checkIVSize(p.IV, blockSize); err != nil {
+		return nil, err
+	}
+
+	// Неполный последний блок - усеченный шифротекст, а не данные для дополнения нулями
+	if len(data)%blockSize != 0 {
+		return nil, dataLengthError(len(data), blockSize)
+	}
+
+	decrypted := make([]byte, len(data))
+	numBlocks := len(data) / blockSize
+	previousPlaintext := make([]byte, blockSize)
+	previousCiphertext := make([]byte, blockSize)
+	copy(previousCiphertext, p.IV)
+
+	for i := 0; i < numBlocks; i++ {
+		bs := i * blockSize
+		block := data[bs : bs+blockSize]
+
+		decryptedBlock, err := p.Cipher.Decrypt(block)
+		if err != nil {
+			return nil, decryptBlockError(i, err)
+		}
+
+		for j := 0; j < blockSize; j++ {
+			decrypted[bs+j] = decryptedBlock[j] ^ previousPlaintext[j] ^ previousCiphertext[j]
+		}
+
+		copy(previousPlaintext, decrypted[bs:bs+blockSize])
+		copy(previousCiphertext, block)
+	}
+
+	return decrypted, nil
+}
+
+// Реализация режима CFB
+type cfbMode struct{}
+
+func (cfbMode) Name() string             { return "CFB" }
+func (cfbMode) IVSize(blockSize int) int { return blockSize }
+func (cfbMode) NeedsPadding() bool       { return true }
+func (cfbMode) Parallelizable() bool     { return false }
+
+// NextIV: сегмент делит блок, поэтому регистр после последнего сегмента -
+// это последний блок шифротекста
+func (cfbMode) NextIV(p ModeParams, plaintext, ciphertext []byte) ([]byte, error) {
+	return lastBlock(ciphertext, p.BlockSize)
+}
+
+// SegmentSize возвращает размер сегмента CFB: p.SegmentSize или, если он не задан, размер блока
+func (cfbMode) SegmentSize(p ModeParams) int {
+	if p.SegmentSize == 0 {
+		return p.BlockSize
+	}
+	return p.SegmentSize
+}
+
+func (cfbMode) Encrypt(p ModeParams, data []byte) ([]byte, error) {
+	return cfbCrypt(p, data, true)
+}
+
+func (cfbMode) Decrypt(p ModeParams, data []byte) ([]byte, error) {
+	// Из-за цепочки зависимостей распараллеливание ограничено
+	return cfbCrypt(p, data, false)
+}
+
+// cfbCrypt - CFB с сегментом p.SegmentSize байт (SP 800-38A): регистр сдвигается
+// на сегмент, и в него дописывается очередной сегмент шифротекста
+func cfbCrypt(p ModeParams, data []byte, encrypt bool) ([]byte, error) {
+	blockSize := p.BlockSize
+	if err := checkIVSize(p.IV, blockSize); err != nil {
+		return nil, err
+	}
+	segment := cfbMode{}.SegmentSize(p)
+	if segment > blockSize || blockSize%segment != 0 {
+		return nil, fmt.Errorf("%w: CFB segment size %d does not divide %d-byte blocks", ErrInvalidBlockSize, segment, blockSize)
+	}
+
+	// Данные выравниваются набивкой, поэтому неполный последний сегмент означает усеченный шифротекст
+	if len(data)%segment != 0 {
+		return nil, fmt.Errorf("%w: data length (%d) is not a multiple of CFB segment size (%d)", ErrInvalidBlockSize, len(data), segment)
+	}
+	numSegments := len(data) / segment
+	out := make([]byte, numSegments*segment)
+	register := make([]byte, blockSize)
+	copy(register, p.IV)
+
+	for i := 0; i < numSegments; i++ {
+		ss := i * segment
+		input := data[ss : ss+segment]
+
+		// Используем метод Encrypt из SymmetricAlgorithm для получения выходного блока
+		outputBlock, err := p.Cipher.Encrypt(register)
+		if err != nil {
+			return nil, encryptBlockError(ss/blockSize, err)
+		}
+
+		for j := 0; j < segment; j++ {
+			out[ss+j] = input[j] ^ outputBlock[j]
+		}
+
+		ciphertext := out[ss : ss+segment]
+		if !encrypt {
+			ciphertext = input
+		}
+		copy(register, register[segment:])
+		copy(register[blockSize-segment:], ciphertext)
+	}
+
+	return out, nil
+}
+
+// Реализация режима OFB
+type ofbMode struct{}
+
+func (ofbMode) Name() string             { return "OFB" }
+func (ofbMode) IVSize(blockSize int) int { return blockSize }
+func (ofbMode) NeedsPadding() bool       { return false }
+func (ofbMode) Parallelizable() bool     { return false }
+
+// NextIV: обратная связь OFB - последний блок ключевого потока, т.е. XOR последних
+// блоков открытого текста и шифротекста
+func (ofbMode) NextIV(p ModeParams, plaintext, ciphertext []byte) ([]byte, error) {
+	return xorLastBlocks(plaintext, ciphertext, p.BlockSize)
+}
+
+func (ofbMode) Encrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+	if err := checkIVSize(p.IV, blockSize); err != nil {
+		return nil, err
+	}
+
+	encrypted := make([]byte, len(data))
+	feedback := make([]byte, blockSize)
+	copy(feedback, p.IV)
+	logDebug(p.Logger, "OFB keystream started", func() []slog.Attr {
+		return []slog.Attr{secretAttr("iv", p.IV), slog.Int("bytes", len(data))}
+	})
+	for i := 0; i < len(data); i += blockSize {
+		// Шифруем текущий `feedback`
+		outputBlock, err := p.Cipher.Encrypt(feedback)
+		if err != nil {
+			return nil, encryptBlockError(i/blockSize, err)
+		}
+
+		// XOR текущего блока данных с зашифрованным `feedback`
+		for j := 0; j < blockSize && i+j < len(data); j++ {
+			encrypted[i+j] = data[i+j] ^ outputBlock[j]
+		}
+
+		// Обновляем `feedback`
+		copy(feedback, outputBlock)
+	}
+
+	return encrypted, nil
+}
+
+func (m ofbMode) Decrypt(p ModeParams, data []byte) ([]byte, error) {
+	// OFB режим симметричен для шифрования и дешифрования
+	return m.Encrypt(p, data)
+}
+
+// Реализация режима CTR с распараллеливанием
+type ctrMode struct{}
+
+func (ctrMode) Name() string             { return "CTR" }
+func (ctrMode) IVSize(blockSize int) int { return blockSize }
+func (ctrMode) NeedsPadding() bool       { return false }
+func (ctrMode) Parallelizable() bool     { return true }
+
+// CounterBlockSize: счетчиком служит весь блок или его часть
+func (ctrMode) CounterBlockSize(blockSize int) int { return blockSize }
+
+// NextIV: счетчик продвигается на число обработанных блоков
+func (ctrMode) NextIV(p ModeParams, plaintext, ciphertext []byte) ([]byte, error) {
+	if err := checkIVSize(p.IV, p.BlockSize); err != nil {
+		return nil, err
+	}
+	if len(ciphertext)%p.BlockSize != 0 {
+		return nil, dataLengthError(len(ciphertext), p.BlockSize)
+	}
+	counter := make([]byte, p.BlockSize)
+	copy(counter, p.IV)
+	if err := incrementCounterLayout(counter, len(ciphertext)/p.BlockSize, p.Counter); err != nil {
+		return nil, err
+	}
+	return counter, nil
+}
+
+func (ctrMode) Encrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+	if err := checkIVSize(p.IV, blockSize); err != nil {
+		return nil, err
+	}
+
+	numBlocks := (len(data) + blockSize - 1) / blockSize
+	encrypted := make([]byte, len(data))
+
+	err := forEachBlock(numBlocks, p.Workers, func(blockIndex int) error {
+		// Инкрементируем счетчик на номер блока
+		currentCounter := make([]byte, blockSize)
+		copy(currentCounter, p.IV)
+		if err := incrementCounterLayout(currentCounter, blockIndex, p.Counter); err != nil {
+			return encryptBlockError(blockIndex, err)
+		}
+
+		// Шифруем текущий счетчик для получения keystream блока
+		keystreamBlock, err := p.Cipher.Encrypt(currentCounter)
+		if err != nil {
+			return encryptBlockError(blockIndex, err)
+		}
+
+		bs := blockIndex * blockSize
+		be := bs + blockSize
+		if be > len(data) {
+			be = len(data)
+		}
+
+		chunkSize := be - bs
+		for j := 0; j < chunkSize; j++ {
+			encrypted[bs+j] = data[bs+j] ^ keystreamBlock[j]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return encrypted, nil
+}
+
+func (m ctrMode) Decrypt(p ModeParams, data []byte) ([]byte, error) {
+	// CTR режим симметричен для шифрования и дешифрования
+	return m.Encrypt(p, data)
+}
+
+// Дополнительная функция для инкрементации счетчика с учетом номера блока
+func incrementCounter(counter []byte, blockIndex int) {
+	// Инкрементируем счетчик на значение blockIndex
+	carry := blockIndex
+	for i := len(counter) - 1; i >= 0 && carry > 0; i-- {
+		sum := int(counter[i]) + (carry & 0xFF)
+		counter[i] = byte(sum & 0xFF)
+		carry = (carry >> 8) + (sum >> 8)
+	}
+}
+
+// ErrCounterOverflow - счетчик CTR переполнился бы и повторил ключевой поток
+var ErrCounterOverflow = errors.New("CTR counter overflow: message is too long for the counter layout")
+
+// incrementCounterLayout прибавляет blockIndex к счетчику из раскладки layout.
+// Счетчик во весь блок (Size 0) переходит через ноль как раньше, а счетчик
+// в части блока не должен переполняться, иначе ключевой поток повторится.
+func incrementCounterLayout(block []byte, blockIndex int, layout CounterLayout) error {
+	if layout.Size == 0 {
+		incrementCounter(block, blockIndex)
+		return nil
+	}
+	size := layout.Size
+	if size > len(block) {
+		size = len(block)
+	}
+	counter := block[len(block)-size:]
+	carry := blockIndex
+	for i := 0; i < len(counter) && carry > 0; i++ {
+		pos := len(counter) - 1 - i
+		if layout.LittleEndian {
+			pos = i
+		}
+		sum := int(counter[pos]) + (carry & 0xFF)
+		counter[pos] = byte(sum)
+		carry = (carry >> 8) + (sum >> 8)
+	}
+	if carry > 0 {
+		return ErrCounterOverflow
+	}
+	return nil
+}
+
+// lastBlock возвращает копию последнего блока данных, длина которых кратна blockSize
+func lastBlock(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, dataLengthError(len(data), blockSize)
+	}
+	return append([]byte(nil), data[len(data)-blockSize:]...), nil
+}
+
+// xorLastBlocks возвращает XOR последних блоков a и b одинаковой длины, кратной blockSize
+func xorLastBlocks(a, b []byte, blockSize int) ([]byte, error) {
+	if len(a) != len(b) {
+		return nil, fmt.Errorf("plaintext and ciphertext lengths differ: %d and %d", len(a), len(b))
+	}
+	last, err := lastBlock(a, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := range last {
+		last[i] ^= b[len(b)-blockSize+i]
+	}
+	return last, nil
+}
+
+// Реализация режима RandomDelta: delta генерируется при шифровании и хранится перед шифротекстом
+type randomDeltaMode struct{}
+
+func (randomDeltaMode) Name() string             { return "RandomDelta" }
+func (randomDeltaMode) IVSize(blockSize int) int { return 0 }
+func (randomDeltaMode) NeedsPadding() bool       { return false }
+func (randomDeltaMode) Parallelizable() bool     { return false }
+
+func (randomDeltaMode) Encrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+	encrypted := make([]byte, len(data))
+
+	// Генерация delta
+	delta := make([]byte, blockSize)
+	if _, err := io.ReadFull(randomSource(p.Rand), delta); err != nil {
+		return nil, fmt.Errorf("failed to generate delta: %w", err)
+	}
+
+	// Шифрование блоков
+	for i := 0; i < len(data); i += blockSize {
+		blockEnd := i + blockSize
+		if blockEnd > len(data) {
+			blockEnd = len(data) // Для последнего неполного блока
+		}
+
+		block := data[i:blockEnd]
+		for j := 0; j < len(block); j++ {
+			encrypted[i+j] = block[j] + delta[j%blockSize]
+		}
+	}
+
+	// Сохраняем `delta` в зашифрованные данные (например, в начало файла)
+	return append(delta, encrypted...), nil
+}
+
+func (randomDeltaMode) Decrypt(p ModeParams, data []byte) ([]byte, error) {
+	blockSize := p.BlockSize
+
+	// Извлечение `delta` из данных
+	if len(data) < blockSize {
+		return nil, fmt.Errorf("%w: data too short to contain delta", ErrInvalidBlockSize)
+	}
+	delta := data[:blockSize]
+	data = data[blockSize:]
+
+	decrypted := make([]byte, len(data))
+
+	// Дешифрование блоков
+	for i := 0; i < len(data); i += blockSize {
+		blockEnd := i + blockSize
+		if blockEnd > len(data) {
+			blockEnd = len(data) // Для последнего неполного блока
+		}
+
+		block := data[i:blockEnd]
+		for j := 0; j < len(block); j++ {
+			decrypted[i+j] = block[j] - delta[j%blockSize]
+		}
+	}
+
+	return decrypted, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestTruncatedCiphertextRejected: режимы, работающие с полными блоками или сегментами,
+// отвергают шифротекст с неполным последним блоком, а не дополняют его нулями
+func TestTruncatedCiphertextRejected(t *testing.T) {
+	alg, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alg.SetKey(mustDecodeHex("133457799bbcdff1")); err != nil {
+		t.Fatal(err)
+	}
+	iv := mustDecodeHex("0011223344556677")
+	tests := []struct {
+		mode    string
+		segment int
+	}{
+		{mode: "ECB"}, {mode: "CBC"}, {mode: "PCBC"}, {mode: "CFB"}, {mode: "CFB", segment: 4},
+	}
+	for _, tt := range tests {
+		id, _ := LookupBlockMode(tt.mode)
+		blockMode, err := id.BlockMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := ModeParams{Cipher: alg, BlockSize: 8, SegmentSize: tt.segment}
+		if blockMode.IVSize(8) > 0 {
+			p.IV = iv
+		}
+		for _, length := range []int{3, 8 + 3, 16 + 7} {
+			data := make([]byte, length)
+			if _, err := blockMode.Decrypt(p, data); !errors.Is(err, ErrInvalidBlockSize) {
+				t.Errorf("%s (segment %d) Decrypt of %d bytes: error = %v, want ErrInvalidBlockSize", tt.mode, tt.segment, length, err)
+			}
+			if _, err := blockMode.Encrypt(p, data); !errors.Is(err, ErrInvalidBlockSize) {
+				t.Errorf("%s (segment %d) Encrypt of %d bytes: error = %v, want ErrInvalidBlockSize", tt.mode, tt.segment, length, err)
+			}
+		}
+	}
+}
+
+// TestParallelizableModesUseWorkers: Parallelizable должен означать, что режим делит работу
+// между p.Workers горутинами; RandomDelta обрабатывает данные последовательно
+func TestParallelizableModesUseWorkers(t *testing.T) {
+	want := map[string]bool{"ECB": true, "CBC": false, "PCBC": false, "CFB": false, "OFB": false, "CTR": true, "RandomDelta": false}
+	for name, parallel := range want {
+		id, _ := LookupBlockMode(name)
+		blockMode, err := id.BlockMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blockMode.Parallelizable() != parallel {
+			t.Errorf("%s Parallelizable() = %v, want %v", name, blockMode.Parallelizable(), parallel)
+		}
+	}
+}
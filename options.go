@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Функциональные опции конструктора NewContext. Опции только записывают значения,
+// а вся проверка выполняется после применения всех опций, поэтому порядок опций
+// не важен, а несовместимые сочетания (IV для ECB, размер сегмента для CTR и т.п.)
+// обнаруживаются при создании контекста, а не при первом шифровании.
+
+// ContextOption настраивает контекст при создании
+type ContextOption func(*contextConfig) error
+
+// contextConfig - настройки, собранные из опций
+type contextConfig struct {
+	mode            CipherMode
+	padding         PaddingMode
+	iv              []byte
+	ivSet           bool
+	blockSize       int
+	segmentSize     int
+	counter         CounterLayout
+	counterSet      bool
+	workers         int
+	workersSet      bool
+	random          io.Reader
+	overwrite       bool
+	allowMissingKCV bool
+	logger          *slog.Logger
+	keyCache        *KeyScheduleCache
+}
+
+// CounterLayout описывает блок счетчика режима CTR: последние Size байт блока - счетчик,
+// остальные - неизменяемый nonce. Size 0 в WithCounterLayout означает, что счетчиком
+// служит весь блок; как и частичный, такой счетчик не переходит через ноль (ErrCounterOverflow).
+// Без WithCounterLayout весь блок - счетчик в порядке big-endian, переходящий через ноль.
+type CounterLayout struct {
+	Size int
+	// LittleEndian - младший байт счетчика идет первым
+	LittleEndian bool
+}
+
+// WithMode задает режим шифрования; по умолчанию CBC
+func WithMode(mode CipherMode) ContextOption {
+	return func(c *contextConfig) error {
+		c.mode = mode
+		return nil
+	}
+}
+
+// WithPadding задает схему набивки; по умолчанию PKCS7
+func WithPadding(padding PaddingMode) ContextOption {
+	return func(c *contextConfig) error {
+		c.padding = padding
+		return nil
+	}
+}
+
+// WithIV задает вектор инициализации; обязателен для режимов с IV и запрещен для остальных
+func WithIV(iv []byte) ContextOption {
+	return func(c *contextConfig) error {
+		c.iv = append([]byte(nil), iv...)
+		c.ivSet = true
+		return nil
+	}
+}
+
+// WithBlockSize проверяет размер блока: он берется из cipher.BlockSize(),
+// и опция лишь требует, чтобы алгоритм сообщал именно такой размер
+func WithBlockSize(blockSize int) ContextOption {
+	return func(c *contextConfig) error {
+		if blockSize <= 0 {
+			return fmt.Errorf("%w: must be positive, got %d", ErrInvalidBlockSize, blockSize)
+		}
+		c.blockSize = blockSize
+		return nil
+	}
+}
+
+// WithSegmentSize задает размер сегмента режима CFB в байтах (CFB-8 - 1 байт);
+// по умолчанию сегмент равен блоку
+func WithSegmentSize(bytes int) ContextOption {
+	return func(c *contextConfig) error {
+		if bytes <= 0 {
+			return fmt.Errorf("%w: segment size must be positive, got %d", ErrInvalidBlockSize, bytes)
+		}
+		c.segmentSize = bytes
+		return nil
+	}
+}
+
+// WithCounterLayout задает раскладку блока счетчика для режимов с CounterMode (CTR)
+func WithCounterLayout(layout CounterLayout) ContextOption {
+	return func(c *contextConfig) error {
+		if layout.Size < 0 {
+			return fmt.Errorf("%w: counter size must not be negative, got %d", ErrInvalidArgument, layout.Size)
+		}
+		c.counter = layout
+		c.counterSet = true
+		return nil
+	}
+}
+
+// WithParallelism задает число горутин для параллельных режимов; 0 - runtime.GOMAXPROCS(0)
+func WithParallelism(workers int) ContextOption {
+	return func(c *contextConfig) error {
+		if workers < 0 {
+			return fmt.Errorf("%w: parallelism must not be negative, got %d", ErrInvalidArgument, workers)
+		}
+		c.workers = workers
+		c.workersSet = true
+		return nil
+	}
+}
+
+// WithRand задает источник случайности (см. SetRand); по умолчанию crypto/rand
+func WithRand(random io.Reader) ContextOption {
+	return func(c *contextConfig) error {
+		if random == nil {
+			return fmt.Errorf("%w: random source is nil", ErrInvalidArgument)
+		}
+		c.random = lockRandom(random)
+		return nil
+	}
+}
+
+// WithOverwrite разрешает файловым методам заменять существующий выходной файл (см. SetOverwrite)
+func WithOverwrite(allow bool) ContextOption {
+	return func(c *contextConfig) error {
+		c.overwrite = allow
+		return nil
+	}
+}
+
+// WithAllowMissingKCV разрешает дешифровать шифротекст без заголовка с KCV (см. SetAllowMissingKCV)
+func WithAllowMissingKCV(allow bool) ContextOption {
+	return func(c *contextConfig) error {
+		c.allowMissingKCV = allow
+		return nil
+	}
+}
+
+// WithKeyScheduleCache включает кэш расписаний ключей: контексты с общим кэшем и одним
+// алгоритмом не строят расписание повторно для уже встречавшегося ключа.
+// По умолчанию кэша нет, и расписания не переживают свой контекст.
+func WithKeyScheduleCache(cache *KeyScheduleCache) ContextOption {
+	return func(c *contextConfig) error {
+		if cache == nil {
+			return fmt.Errorf("%w: key schedule cache is nil", ErrInvalidArgument)
+		}
+		c.keyCache = cache
+		return nil
+	}
+}
+
+// WithLogger задает журнал для отладочных записей контекста; по умолчанию - логгер библиотеки.
+// Ключ и IV в записях скрыты, если не включен SetLogSecrets.
+func WithLogger(logger *slog.Logger) ContextOption {
+	return func(c *contextConfig) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// NewContext создает контекст для алгоритма cipher с ключом key.
+// Без опций используется CBC с набивкой PKCS7; для CBC нужен WithIV.
+func NewContext(key []byte, cipher SymmetricAlgorithm, opts ...ContextOption) (*CryptoSymmetricContext, error) {
+	if cipher == nil {
+		return nil, fmt.Errorf("%w: cipher is nil", ErrInvalidArgument)
+	}
+	cfg := contextConfig{mode: CBC, padding: PKCS7}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	blockSize := cipher.BlockSize()
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("%w: cipher reports %d", ErrInvalidBlockSize, blockSize)
+	}
+	if cfg.blockSize != 0 && cfg.blockSize != blockSize {
+		return nil, fmt.Errorf("%w: %d does not match the cipher's %d-byte block", ErrInvalidBlockSize, cfg.blockSize, blockSize)
+	}
+	cfg.blockSize = blockSize
+	if err := checkKeySize(cipher, key); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	cstc := &CryptoSymmetricContext{
+		cipher:          cipher,
+		mode:            cfg.mode,
+		padding:         cfg.padding,
+		iv:              cfg.iv,
+		blockSize:       cfg.blockSize,
+		segmentSize:     cfg.segmentSize,
+		counter:         cfg.counter,
+		workers:         cfg.workers,
+		overwrite:       cfg.overwrite,
+		allowMissingKCV: cfg.allowMissingKCV,
+		random:          cfg.random,
+		logger:          cfg.logger,
+		keyCache:        cfg.keyCache,
+	}
+	// Получение экземпляра шифра с ключом (из кэша расписаний, если алгоритм это поддерживает)
+	if err := cstc.SetKey(key); err != nil {
+		return nil, fmt.Errorf("failed to set key: %w", err)
+	}
+	logDebug(cstc.logger, "context created", func() []slog.Attr {
+		return []slog.Attr{
+			slog.String("mode", cstc.mode.String()),
+			slog.String("padding", cstc.padding.String()),
+			slog.Int("block_size", cstc.blockSize),
+			secretAttr("key", key),
+			secretAttr("iv", cstc.iv),
+		}
+	})
+	return cstc, nil
+}
+
+// checkKeySize проверяет длину ключа по списку допустимых длин алгоритма
+func checkKeySize(cipher SymmetricAlgorithm, key []byte) error {
+	sizes := cipher.KeySizes()
+	if len(sizes) == 0 {
+		return fmt.Errorf("%w: cipher reports no valid key sizes", ErrInvalidKeySize)
+	}
+	for _, size := range sizes {
+		if len(key) == size {
+			return nil
+		}
+	}
+	keyErr := &KeySizeError{Size: len(key), Valid: sizes}
+	if named, ok := cipher.(interface{ Name() string }); ok {
+		keyErr.Algorithm = named.Name()
+	}
+	return keyErr
+}
+
+// validate проверяет настройки и их сочетания
+func (c *contextConfig) validate() error {
+	blockMode, err := c.mode.BlockMode()
+	if err != nil {
+		return err
+	}
+	padding, err := c.padding.Padding()
+	if err != nil {
+		return err
+	}
+	if !paddingSupportsBlockSize(padding, c.blockSize) {
+		return fmt.Errorf("%w: %s padding is not defined for %d-byte blocks", ErrInvalidBlockSize, padding.Name(), c.blockSize)
+	}
+	name := blockMode.Name()
+
+	ivSize := blockMode.IVSize(c.blockSize)
+	switch {
+	case ivSize == 0 && c.ivSet && len(c.iv) > 0:
+		return fmt.Errorf("%w: %s mode does not use an IV", ErrInvalidIV, name)
+	case ivSize > 0 && !c.ivSet:
+		return fmt.Errorf("%w: %s mode requires a %d-byte IV: use WithIV", ErrInvalidIV, name, ivSize)
+	case ivSize > 0 && len(c.iv) != ivSize:
+		return fmt.Errorf("%w: %s mode requires a %d-byte IV, got %d", ErrInvalidIV, name, ivSize, len(c.iv))
+	}
+
+	if c.segmentSize != 0 {
+		if _, ok := blockMode.(SegmentedMode); !ok {
+			return fmt.Errorf("%w: %s mode does not use segments", ErrUnsupportedMode, name)
+		}
+		if c.segmentSize > c.blockSize || c.blockSize%c.segmentSize != 0 {
+			return fmt.Errorf("%w: segment size %d must divide the %d-byte block", ErrInvalidBlockSize, c.segmentSize, c.blockSize)
+		}
+	}
+	if c.counterSet {
+		counterMode, ok := blockMode.(CounterMode)
+		if !ok {
+			return fmt.Errorf("%w: %s mode does not use a counter layout", ErrUnsupportedMode, name)
+		}
+		counterSize := counterMode.CounterBlockSize(c.blockSize)
+		if c.counter.Size > counterSize {
+			return fmt.Errorf("%w: counter size %d exceeds the %d-byte counter block", ErrInvalidBlockSize, c.counter.Size, counterSize)
+		}
+		// Явная раскладка с Size 0 - счетчик во весь блок с заданным порядком байтов
+		if c.counter.Size == 0 {
+			c.counter.Size = counterSize
+		}
+	}
+	if c.workersSet && c.workers != 0 && !blockMode.Parallelizable() {
+		return fmt.Errorf("%w: %s processes blocks sequentially and cannot use parallelism", ErrUnsupportedMode, name)
+	}
+	return nil
+}
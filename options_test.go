@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// noKeySizesCipher - шифр, не сообщающий допустимых длин ключа
+type noKeySizesCipher struct {
+	*DES
+}
+
+func (noKeySizesCipher) KeySizes() []int { return nil }
+
+// TestContextErrorsWrapSentinels: каждая ошибка NewContext относится к одному из классов
+// errors.go, чтобы CLI выбирал код возврата по errors.Is, а не по тексту
+func TestContextErrorsWrapSentinels(t *testing.T) {
+	key := make([]byte, 8)
+	iv := make([]byte, 8)
+	tests := []struct {
+		name   string
+		cipher func() SymmetricAlgorithm
+		opts   []ContextOption
+		want   error
+	}{
+		{name: "nil cipher", cipher: func() SymmetricAlgorithm { return nil }, want: ErrInvalidArgument},
+		{name: "nil random", opts: []ContextOption{WithRand(nil)}, want: ErrInvalidArgument},
+		{name: "negative parallelism", opts: []ContextOption{WithMode(ECB), WithParallelism(-1)}, want: ErrInvalidArgument},
+		{name: "negative counter size", opts: []ContextOption{WithMode(CTR), WithIV(iv), WithCounterLayout(CounterLayout{Size: -1})}, want: ErrInvalidArgument},
+		{name: "counter size exceeds block", opts: []ContextOption{WithMode(CTR), WithIV(iv), WithCounterLayout(CounterLayout{Size: 9})}, want: ErrInvalidBlockSize},
+		{name: "counter layout outside CTR", opts: []ContextOption{WithMode(CBC), WithIV(iv), WithCounterLayout(CounterLayout{Size: 4})}, want: ErrUnsupportedMode},
+		{
+			name: "no valid key sizes",
+			cipher: func() SymmetricAlgorithm {
+				des, err := NewDES()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return noKeySizesCipher{des}
+			},
+			opts: []ContextOption{WithMode(ECB)},
+			want: ErrInvalidKeySize,
+		},
+	}
+	for _, tt := range tests {
+		var cipher SymmetricAlgorithm
+		if tt.cipher != nil {
+			cipher = tt.cipher()
+		} else {
+			des, err := NewDES()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cipher = des
+		}
+		_, err := NewContext(key, cipher, tt.opts...)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+		if code := exitCode(err); code != exitUsage {
+			t.Errorf("%s: exit code %d, want %d", tt.name, code, exitUsage)
+		}
+	}
+}
+
+// truncatingCipher возвращает блоки короче входных
+type truncatingCipher struct{}
+
+func (truncatingCipher) Encrypt(block []byte) ([]byte, error) { return block[:2], nil }
+func (truncatingCipher) Decrypt(block []byte) ([]byte, error) { return block[:2], nil }
+
+// TestErrorsWrapSentinels: ошибки KCV, заголовков PEM и генерации ключей тоже относятся
+// к классам errors.go, и CLI выбирает для них код возврата по errors.Is
+func TestErrorsWrapSentinels(t *testing.T) {
+	des, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext(mustDecodeHex("133457799bbcdff1"), des, WithMode(CBC), WithIV(make([]byte, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := func(version, length byte, kcv ...byte) []byte {
+		return append(append(append([]byte(nil), kcvMagic...), version, length), kcv...)
+	}
+	dir := t.TempDir()
+	truncatedFile := filepath.Join(dir, "truncated")
+	if err := os.WriteFile(truncatedFile, header(kcvHeaderVersion, KCVSize, 0xAA), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pemIV := func(iv string) func() error {
+		return func() error {
+			_, err := ctx.forArmoredMessage(&ArmoredMessage{Format: ArmorPEM, Headers: map[string]string{armorHeaderIV: iv}})
+			return err
+		}
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+		exit int
+	}{
+		{"KCV of a tiny block", func() error { _, err := ComputeKCV(des, 2); return err }, ErrInvalidBlockSize, exitUsage},
+		{"KCV from a truncating cipher", func() error { _, err := ComputeKCV(truncatingCipher{}, 8); return err }, ErrInvalidBlockSize, exitUsage},
+		{"KCV with a wrong key size", func() error { _, err := KeyCheckValue(des, make([]byte, 7), 8); return err }, ErrInvalidKeySize, exitUsage},
+		{"truncated KCV header", func() error { _, _, err := ParseKCVHeader(kcvMagic); return err }, ErrInvalidKCVHeader, exitDecryption},
+		{"unknown KCV header version", func() error { _, _, err := ParseKCVHeader(header(9, KCVSize, 1, 2, 3)); return err }, ErrInvalidKCVHeader, exitDecryption},
+		{"wrong KCV length in header", func() error { _, _, err := ParseKCVHeader(header(kcvHeaderVersion, 4, 1, 2, 3, 4)); return err }, ErrInvalidKCVHeader, exitDecryption},
+		{"KCV shorter than its length", func() error { _, _, err := ParseKCVHeader(header(kcvHeaderVersion, KCVSize, 1)); return err }, ErrInvalidKCVHeader, exitDecryption},
+		{"short KCV", func() error { return ctx.verifyKCV([]byte{1}) }, ErrInvalidKCVHeader, exitDecryption},
+		{"truncated KCV header in a file", func() error { return <-ctx.DecryptFileAsync(truncatedFile, filepath.Join(dir, "out")) }, ErrInvalidKCVHeader, exitDecryption},
+		{"KCV header is not hex", func() error { _, err := parseKCVHex("zzzzzz"); return err }, ErrInvalidKCVHeader, exitDecryption},
+		{"KCV header too short", func() error { _, err := parseKCVHex("AB"); return err }, ErrInvalidKCVHeader, exitDecryption},
+		{"PEM IV header is not hex", pemIV("zz"), ErrInvalidIV, exitUsage},
+		{"PEM IV header too short", pemIV("0011"), ErrInvalidIV, exitUsage},
+		{"key for an unknown algorithm", func() error { _, err := GenerateKey("Blowfish", 0, zeroReader{}); return err }, ErrInvalidArgument, exitUsage},
+		{"key of an unsupported size", func() error { _, err := GenerateKey("DES", 16, zeroReader{}); return err }, ErrInvalidKeySize, exitUsage},
+		{"only weak keys", func() error { _, err := GenerateKey("DES", 0, zeroReader{}); return err }, ErrWeakRandomKeys, exitFailure},
+	}
+	for _, tt := range tests {
+		err := tt.call()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+		if code := exitCode(err); code != tt.exit {
+			t.Errorf("%s: exit code %d, want %d", tt.name, code, tt.exit)
+		}
+	}
+
+	var sizeErr *KeySizeError
+	if _, err := GenerateKey("DES", 16, zeroReader{}); !errors.As(err, &sizeErr) || sizeErr.Algorithm != "DES" || sizeErr.Size != 16 {
+		t.Errorf("GenerateKey(DES, 16) error = %#v, want *KeySizeError for DES", err)
+	}
+}
+
+// TestNewContextRejectsInvalidCombinations: сочетания настроек, которые не могут работать,
+// отвергаются при создании контекста, а не при первом Encrypt
+func TestNewContextRejectsInvalidCombinations(t *testing.T) {
+	desIV, aesIV := make([]byte, 8), make([]byte, 16)
+	tests := []struct {
+		name      string
+		algorithm string
+		opts      []ContextOption
+		want      error
+	}{
+		{"PKCS5 with a 16-byte block", "AES", []ContextOption{WithMode(ECB), WithPadding(PKCS5)}, ErrInvalidBlockSize},
+		{"PKCS5 with a 16-byte block in CTR", "DEAL", []ContextOption{WithMode(CTR), WithIV(aesIV), WithPadding(PKCS5)}, ErrInvalidBlockSize},
+		{"block size mismatch", "DES", []ContextOption{WithMode(ECB), WithBlockSize(16)}, ErrInvalidBlockSize},
+		{"IV for ECB", "DES", []ContextOption{WithMode(ECB), WithIV(desIV)}, ErrInvalidIV},
+		{"missing IV", "DES", []ContextOption{WithMode(CBC)}, ErrInvalidIV},
+		{"short IV", "AES", []ContextOption{WithMode(OFB), WithIV(desIV)}, ErrInvalidIV},
+		{"segment size outside CFB", "DES", []ContextOption{WithMode(CBC), WithIV(desIV), WithSegmentSize(1)}, ErrUnsupportedMode},
+		{"segment size not dividing the block", "DES", []ContextOption{WithMode(CFB), WithIV(desIV), WithSegmentSize(3)}, ErrInvalidBlockSize},
+		{"segment size exceeds the block", "DES", []ContextOption{WithMode(CFB), WithIV(desIV), WithSegmentSize(16)}, ErrInvalidBlockSize},
+		{"counter layout in CBC", "DES", []ContextOption{WithMode(CBC), WithIV(desIV), WithCounterLayout(CounterLayout{Size: 4})}, ErrUnsupportedMode},
+		{"counter layout in OFB", "DES", []ContextOption{WithMode(OFB), WithIV(desIV), WithCounterLayout(CounterLayout{Size: 4})}, ErrUnsupportedMode},
+		{"whole-block counter layout in ECB", "DES", []ContextOption{WithMode(ECB), WithCounterLayout(CounterLayout{})}, ErrUnsupportedMode},
+		{"counter exceeds the block", "AES", []ContextOption{WithMode(CTR), WithIV(aesIV), WithCounterLayout(CounterLayout{Size: 17})}, ErrInvalidBlockSize},
+		{"parallel CBC", "DES", []ContextOption{WithMode(CBC), WithIV(desIV), WithParallelism(4)}, ErrUnsupportedMode},
+		{"parallel PCBC", "DES", []ContextOption{WithMode(PCBC), WithIV(desIV), WithParallelism(2)}, ErrUnsupportedMode},
+		{"parallel OFB", "DES", []ContextOption{WithMode(OFB), WithIV(desIV), WithParallelism(2)}, ErrUnsupportedMode},
+		{"parallel RandomDelta", "DES", []ContextOption{WithMode(RandomDelta), WithParallelism(2)}, ErrUnsupportedMode},
+	}
+	for _, tt := range tests {
+		spec, _ := LookupAlgorithm(tt.algorithm)
+		alg, err := spec.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := GenerateKey(spec.Name, 0, NewTestRand("options/"+tt.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewContext(key, alg, tt.opts...); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestWholeBlockCounterLayout: CounterLayout с Size 0 - счетчик во весь блок,
+// и порядок байтов при этом не игнорируется
+func TestWholeBlockCounterLayout(t *testing.T) {
+	key := mustDecodeHex("133457799bbcdff1")
+	iv := mustDecodeHex("00000000000000ff")
+	newContext := func(opts ...ContextOption) *CryptoSymmetricContext {
+		alg, err := NewDES()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, err := NewContext(key, alg, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ctx
+	}
+	ecb := newContext(WithMode(ECB), WithPadding(NoPadding))
+	keystream := func(counters ...string) []byte {
+		var blocks []byte
+		for _, c := range counters {
+			blocks = append(blocks, mustDecodeHex(c)...)
+		}
+		out, err := ecb.Encrypt(blocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		layout *CounterLayout
+		want   []byte
+	}{
+		{"default", nil, keystream("00000000000000ff", "0000000000000100")},
+		{"explicit big-endian", &CounterLayout{}, keystream("00000000000000ff", "0000000000000100")},
+		{"explicit little-endian", &CounterLayout{LittleEndian: true}, keystream("00000000000000ff", "01000000000000ff")},
+	}
+	for _, tt := range tests {
+		opts := []ContextOption{WithMode(CTR), WithPadding(NoPadding), WithIV(iv)}
+		if tt.layout != nil {
+			opts = append(opts, WithCounterLayout(*tt.layout))
+		}
+		got, err := newContext(opts...).Encrypt(make([]byte, 16))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: keystream %x, want %x", tt.name, got, tt.want)
+		}
+	}
+
+	// Явный счетчик во весь блок не переходит через ноль
+	overflow := newContext(WithMode(CTR), WithPadding(NoPadding), WithIV(mustDecodeHex("ffffffffffffffff")), WithCounterLayout(CounterLayout{}))
+	if _, err := overflow.Encrypt(make([]byte, 16)); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("whole-block counter overflow: error = %v, want ErrCounterOverflow", err)
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Padding - интерфейс схемы набивки
+type Padding interface {
+	// Name возвращает имя схемы, под которым она доступна в реестре
+	Name() string
+	// Pad возвращает новый срез с набивкой; исходные данные не изменяются
+	Pad(data []byte, blockSize int) ([]byte, error)
+	// Unpad проверяет набивку и возвращает данные без нее
+	Unpad(data []byte, blockSize int) ([]byte, error)
+}
+
+// RandomizedPadding - схема набивки со случайными байтами. Контекст передает ей
+// свой источник случайности, поэтому с детерминированным источником результат воспроизводим.
+type RandomizedPadding interface {
+	Padding
+	PadRand(data []byte, blockSize int, random io.Reader) ([]byte, error)
+}
+
+// BlockSizeRestrictedPadding - схема, определенная не для всех размеров блока
+// (PKCS#5 - только для 8 байт). NewContext отвергает ее для неподходящего шифра.
+type BlockSizeRestrictedPadding interface {
+	Padding
+	SupportsBlockSize(blockSize int) bool
+}
+
+// paddingSupportsBlockSize сообщает, определена ли схема для размера блока
+func paddingSupportsBlockSize(p Padding, blockSize int) bool {
+	restricted, ok := p.(BlockSizeRestrictedPadding)
+	return !ok || restricted.SupportsBlockSize(blockSize)
+}
+
+// Реестр схем набивки. Индекс в срезе совпадает со значением PaddingMode.
+var (
+	paddingRegistryMu sync.RWMutex
+	paddingRegistry   []Padding
+	paddingByName     = make(map[string]PaddingMode)
+	// paddingAliases - другие имена зарегистрированных схем: псевдоним -> имя схемы
+	paddingAliases = make(map[string]string)
+)
+
+// Встроенные схемы регистрируются в порядке констант Zeros..NoPadding
+func init() {
+	builtin := []Padding{
+		zerosPadding{}, ansiX923Padding{}, pkcs7Padding{}, iso10126Padding{},
+		iso7816Padding{}, trailingBitComplementPadding{}, pkcs5Padding{}, noPadding{},
+	}
+	for _, p := range builtin {
+		if _, err := RegisterPadding(p); err != nil {
+			panic(err)
+		}
+	}
+	// Битовая набивка для байтовых данных совпадает с ISO/IEC 7816-4
+	if err := RegisterPaddingAlias("Bit", "ISO7816"); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterPadding добавляет схему набивки в реестр и возвращает присвоенный ей идентификатор
+func RegisterPadding(p Padding) (PaddingMode, error) {
+	if p == nil {
+		return 0, errors.New("padding is nil")
+	}
+	name := p.Name()
+	if name == "" {
+		return 0, errors.New("padding name is empty")
+	}
+
+	paddingRegistryMu.Lock()
+	defer paddingRegistryMu.Unlock()
+
+	if _, exists := paddingByName[name]; exists {
+		return 0, fmt.Errorf("padding %q is already registered", name)
+	}
+	if _, exists := paddingAliases[name]; exists {
+		return 0, fmt.Errorf("padding %q is already registered as an alias", name)
+	}
+	id := PaddingMode(len(paddingRegistry))
+	paddingRegistry = append(paddingRegistry, p)
+	paddingByName[name] = id
+	return id, nil
+}
+
+// RegisterPaddingAlias добавляет другое имя для уже зарегистрированной схемы.
+// LookupPadding находит схему и по псевдониму, но PaddingNames его не перечисляет.
+func RegisterPaddingAlias(alias, name string) error {
+	paddingRegistryMu.Lock()
+	defer paddingRegistryMu.Unlock()
+
+	if _, ok := paddingByName[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedPadding, name)
+	}
+	if _, exists := paddingByName[alias]; exists {
+		return fmt.Errorf("padding %q is already registered", alias)
+	}
+	if _, exists := paddingAliases[alias]; exists {
+		return fmt.Errorf("padding alias %q is already registered", alias)
+	}
+	paddingAliases[alias] = name
+	return nil
+}
+
+// LookupPadding ищет схему набивки по имени или псевдониму
+func LookupPadding(name string) (PaddingMode, bool) {
+	paddingRegistryMu.RLock()
+	defer paddingRegistryMu.RUnlock()
+	if canonical, ok := paddingAliases[name]; ok {
+		name = canonical
+	}
+	id, ok := paddingByName[name]
+	return id, ok
+}
+
+// PaddingAliases возвращает псевдонимы схемы name
+func PaddingAliases(name string) []string {
+	paddingRegistryMu.RLock()
+	defer paddingRegistryMu.RUnlock()
+	var aliases []string
+	for alias, canonical := range paddingAliases {
+		if canonical == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// PaddingNames возвращает имена всех зарегистрированных схем в порядке регистрации
+func PaddingNames() []string {
+	paddingRegistryMu.RLock()
+	defer paddingRegistryMu.RUnlock()
+	names := make([]string, len(paddingRegistry))
+	for i, p := range paddingRegistry {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Padding возвращает реализацию схемы по ее идентификатору
+func (m PaddingMode) Padding() (Padding, error) {
+	paddingRegistryMu.RLock()
+	defer paddingRegistryMu.RUnlock()
+	if m < 0 || int(m) >= len(paddingRegistry) {
+		return nil, fmt.Errorf("%w: PaddingMode(%d)", ErrUnsupportedPadding, int(m))
+	}
+	return paddingRegistry[m], nil
+}
+
+func (m PaddingMode) String() string {
+	p, err := m.Padding()
+	if err != nil {
+		return fmt.Sprintf("PaddingMode(%d)", int(m))
+	}
+	return p.Name()
+}
+
+// paddingLength возвращает длину набивки: от 1 до blockSize байт
+func paddingLength(dataLen, blockSize int) (int, error) {
+	if blockSize <= 0 || blockSize > 255 {
+		return 0, fmt.Errorf("%w: unsupported block size for padding: %d", ErrInvalidBlockSize, blockSize)
+	}
+	return blockSize - dataLen%blockSize, nil
+}
+
+// checkPaddedLength проверяет, что набитые данные непусты и кратны размеру блока
+func checkPaddedLength(data []byte, blockSize int) error {
+	if blockSize <= 0 || blockSize > 255 {
+		return fmt.Errorf("%w: unsupported block size for padding: %d", ErrInvalidBlockSize, blockSize)
+	}
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return fmt.Errorf("%w: data length (%d) is not a positive multiple of block size (%d)", ErrInvalidPadding, len(data), blockSize)
+	}
+	return nil
+}
+
+// appendPadding копирует данные в новый срез, чтобы не писать в массив вызывающей стороны
+func appendPadding(data, padding []byte) []byte {
+	result := make([]byte, 0, len(data)+len(padding))
+	result = append(result, data...)
+	return append(result, padding...)
+}
+
+// Zeros: нулевые байты, всегда хотя бы один
+type zerosPadding struct{}
+
+func (zerosPadding) Name() string { return "Zeros" }
+
+func (zerosPadding) Pad(data []byte, blockSize int) ([]byte, error) {
+	paddingLen, err := paddingLength(len(data), blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return ZerosPadding(data, paddingLen), nil
+}
+
+func (zerosPadding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPaddedLength(data, blockSize); err != nil {
+		return nil, err
+	}
+	return removeZerosPadding(data, blockSize)
+}
+
+// ANSI X9.23: нулевые байты, последний байт - длина набивки
+type ansiX923Padding struct{}
+
+func (ansiX923Padding) Name() string { return "ANSIX923" }
+
+func (ansiX923Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	paddingLen, err := paddingLength(len(data), blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return ANSIX923Padding(data, paddingLen), nil
+}
+
+func (ansiX923Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPaddedLength(data, blockSize); err != nil {
+		return nil, err
+	}
+	return removeANSIX923Padding(data, blockSize)
+}
+
+// PKCS#7: каждый байт набивки равен ее длине
+type pkcs7Padding struct{}
+
+func (pkcs7Padding) Name() string { return "PKCS7" }
+
+func (pkcs7Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	paddingLen, err := paddingLength(len(data), blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return PKCS7Padding(data, paddingLen), nil
+}
+
+func (pkcs7Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPaddedLength(data, blockSize); err != nil {
+		return nil, err
+	}
+	return removePKCS7Padding(data, blockSize)
+}
+
+// PKCS#5: то же, что PKCS#7, но определена только для 8-байтовых блоков
+type pkcs5Padding struct{}
+
+func (pkcs5Padding) Name() string { return "PKCS5" }
+
+func (pkcs5Padding) SupportsBlockSize(blockSize int) bool { return blockSize == 8 }
+
+func (p pkcs5Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if !p.SupportsBlockSize(blockSize) {
+		return nil, fmt.Errorf("%w: PKCS5 padding requires 8-byte blocks, got %d", ErrInvalidBlockSize, blockSize)
+	}
+	return pkcs7Padding{}.Pad(data, blockSize)
+}
+
+func (p pkcs5Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if !p.SupportsBlockSize(blockSize) {
+		return nil, fmt.Errorf("%w: PKCS5 padding requires 8-byte blocks, got %d", ErrInvalidBlockSize, blockSize)
+	}
+	return pkcs7Padding{}.Unpad(data, blockSize)
+}
+
+// ISO 10126: случайные байты, последний байт - длина набивки
+type iso10126Padding struct{}
+
+func (iso10126Padding) Name() string { return "ISO10126" }
+
+func (p iso10126Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	return p.PadRand(data, blockSize, rand.Reader)
+}
+
+func (iso10126Padding) PadRand(data []byte, blockSize int, random io.Reader) ([]byte, error) {
+	paddingLen, err := paddingLength(len(data), blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return ISO10126PaddingFrom(random, data, paddingLen)
+}
+
+func (iso10126Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPaddedLength(data, blockSize); err != nil {
+		return nil, err
+	}
+	// Содержимое случайных байтов проверить нельзя, поэтому проверяется только длина
+	paddingLen := int(data[len(data)-1])
+	if paddingLen == 0 || paddingLen > blockSize {
+		return nil, fmt.Errorf("%w: bad length byte", ErrInvalidPadding)
+	}
+	return data[:len(data)-paddingLen], nil
+}
+
+// ISO/IEC 7816-4: байт 0x80, затем нулевые байты. Зарегистрирована также под псевдонимом "Bit":
+// битовая набивка ISO/IEC 9797-1 (метод 2) для данных, кратных байту, дает те же байты.
+type iso7816Padding struct{}
+
+func (iso7816Padding) Name() string { return "ISO7816" }
+
+func (iso7816Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	paddingLen, err := paddingLength(len(data), blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return ISO7816Padding(data, paddingLen), nil
+}
+
+func (iso7816Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPaddedLength(data, blockSize); err != nil {
+		return nil, err
+	}
+	return removeISO7816Padding(data, blockSize)
+}
+
+// Trailing bit complement: набивка из битов, противоположных последнему биту данных
+type trailingBitComplementPadding struct{}
+
+func (trailingBitComplementPadding) Name() string { return "TrailingBitComplement" }
+
+func (trailingBitComplementPadding) Pad(data []byte, blockSize int) ([]byte, error) {
+	paddingLen, err := paddingLength(len(data), blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return TrailingBitComplementPadding(data, paddingLen), nil
+}
+
+func (trailingBitComplementPadding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if err := checkPaddedLength(data, blockSize); err != nil {
+		return nil, err
+	}
+	return removeTrailingBitComplementPadding(data, blockSize)
+}
+
+// Без набивки: данные передаются как есть, выравнивание проверяет режим шифрования
+type noPadding struct{}
+
+func (noPadding) Name() string { return "None" }
+
+func (noPadding) Pad(data []byte, blockSize int) ([]byte, error) {
+	return appendPadding(data, nil), nil
+}
+
+func (noPadding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	return data, nil
+}
+
+// Реализация функций набивки и удаления набивки
+
+func ZerosPadding(data []byte, paddingLen int) []byte {
+	padding := bytes.Repeat([]byte{0}, paddingLen)
+	return appendPadding(data, padding)
+}
+
+// removeZerosPadding удаляет нулевые байты только в пределах последнего блока,
+// поэтому данные, оканчивающиеся нулями и кратные блоку, восстанавливаются без потерь
+func removeZerosPadding(data []byte, blockSize int) ([]byte, error) {
+	if data[len(data)-1] != 0 {
+		return nil, fmt.Errorf("%w: no trailing zeros", ErrInvalidPadding)
+	}
+	end := len(data)
+	for end > len(data)-blockSize && data[end-1] == 0 {
+		end--
+	}
+	return data[:end], nil
+}
+
+func ANSIX923Padding(data []byte, paddingLen int) []byte {
+	padding := append(bytes.Repeat([]byte{0}, paddingLen-1), byte(paddingLen))
+	return appendPadding(data, padding)
+}
+
+// removeANSIX923Padding проверяет набивку за постоянное время: просматривается весь последний блок
+// независимо от ее длины и содержимого, а любая ошибка возвращается как ErrInvalidPadding без подробностей,
+// чтобы по ее тексту нельзя было понять, какой именно байт набивки неверен
+func removeANSIX923Padding(data []byte, blockSize int) ([]byte, error) {
+	paddingLen := int(data[len(data)-1])
+	good := subtle.ConstantTimeLessOrEq(1, paddingLen) & subtle.ConstantTimeLessOrEq(paddingLen, blockSize)
+	for i := 2; i <= blockSize; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, paddingLen)
+		isZero := subtle.ConstantTimeByteEq(data[len(data)-i], 0)
+		good &= isZero | (inPadding ^ 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return data[:len(data)-paddingLen], nil
+}
+
+func PKCS7Padding(data []byte, paddingLen int) []byte {
+	padding := bytes.Repeat([]byte{byte(paddingLen)}, paddingLen)
+	return appendPadding(data, padding)
+}
+
+// removePKCS7Padding проверяет набивку за постоянное время (см. removeANSIX923Padding)
+func removePKCS7Padding(data []byte, blockSize int) ([]byte, error) {
+	last := data[len(data)-1]
+	paddingLen := int(last)
+	good := subtle.ConstantTimeLessOrEq(1, paddingLen) & subtle.ConstantTimeLessOrEq(paddingLen, blockSize)
+	for i := 1; i <= blockSize; i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, paddingLen)
+		matches := subtle.ConstantTimeByteEq(data[len(data)-i], last)
+		good &= matches | (inPadding ^ 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return data[:len(data)-paddingLen], nil
+}
+
+func ISO10126Padding(data []byte, paddingLen int) ([]byte, error) {
+	return ISO10126PaddingFrom(rand.Reader, data, paddingLen)
+}
+
+// ISO10126PaddingFrom берет случайные байты набивки из random
+func ISO10126PaddingFrom(random io.Reader, data []byte, paddingLen int) ([]byte, error) {
+	padding := make([]byte, paddingLen)
+	if _, err := io.ReadFull(random, padding[:paddingLen-1]); err != nil {
+		return nil, err
+	}
+	padding[paddingLen-1] = byte(paddingLen)
+	return appendPadding(data, padding), nil
+}
+
+func ISO7816Padding(data []byte, paddingLen int) []byte {
+	padding := make([]byte, paddingLen)
+	padding[0] = 0x80
+	return appendPadding(data, padding)
+}
+
+// removeISO7816Padding проверяет набивку за постоянное время (см. removeANSIX923Padding):
+// первый ненулевой байт с конца последнего блока должен быть маркером 0x80
+func removeISO7816Padding(data []byte, blockSize int) ([]byte, error) {
+	found, good, markerIndex := 0, 0, 0
+	for i := len(data) - 1; i >= len(data)-blockSize; i-- {
+		searching := found ^ 1
+		isZero := subtle.ConstantTimeByteEq(data[i], 0x00)
+		isMarker := subtle.ConstantTimeByteEq(data[i], 0x80)
+		markerIndex = subtle.ConstantTimeSelect(searching&isMarker, i, markerIndex)
+		good |= searching & isMarker
+		found |= searching & (isZero ^ 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return data[:markerIndex], nil
+}
+
+func TrailingBitComplementPadding(data []byte, paddingLen int) []byte {
+	// Для пустых данных последним битом считается 0
+	fill := byte(0xFF)
+	if len(data) > 0 && data[len(data)-1]&1 == 1 {
+		fill = 0x00
+	}
+	padding := bytes.Repeat([]byte{fill}, paddingLen)
+	return appendPadding(data, padding)
+}
+
+func removeTrailingBitComplementPadding(data []byte, blockSize int) ([]byte, error) {
+	fill := data[len(data)-1]
+	if fill != 0x00 && fill != 0xFF {
+		return nil, fmt.Errorf("%w: bad trailing bit complement", ErrInvalidPadding)
+	}
+	end := len(data)
+	for end > len(data)-blockSize && data[end-1] == fill {
+		end--
+	}
+	// Последний бит данных обязан отличаться от битов набивки
+	if end > 0 && data[end-1]&1 == fill&1 {
+		return nil, fmt.Errorf("%w: bad trailing bit complement", ErrInvalidPadding)
+	}
+	return data[:end], nil
+}
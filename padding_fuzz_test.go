@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// fuzzPaddingBlockSizes - размеры блока, на которых проверяются схемы набивки
+var fuzzPaddingBlockSizes = []int{8, 16}
+
+// FuzzPaddingRoundTrip проверяет, что Unpad(Pad(data)) == data для каждой схемы
+func FuzzPaddingRoundTrip(f *testing.F) {
+	f.Add([]byte(""), uint8(0))
+	f.Add([]byte("A"), uint8(0))
+	f.Add([]byte("ABCDEFGH"), uint8(0))
+	f.Add([]byte("ABCDEFGHIJKLMNOP"), uint8(1))
+	f.Add([]byte{0x80, 0x00, 0x00}, uint8(0))
+	f.Add(bytes.Repeat([]byte{0x08}, 8), uint8(0))
+	f.Add(bytes.Repeat([]byte{0x10}, 31), uint8(1))
+
+	f.Fuzz(func(t *testing.T, data []byte, size uint8) {
+		blockSize := fuzzPaddingBlockSizes[int(size)%len(fuzzPaddingBlockSizes)]
+		for _, name := range PaddingNames() {
+			if name == "PKCS5" && blockSize != 8 {
+				continue
+			}
+			// Нулевая набивка неоднозначна для данных, оканчивающихся нулем
+			if name == "Zeros" && (len(data) == 0 || data[len(data)-1] == 0) {
+				continue
+			}
+			padding := paddingByTestName(t, name)
+			original := append([]byte(nil), data...)
+			padded, err := padding.Pad(data, blockSize)
+			if err != nil {
+				t.Fatalf("%s Pad(%x, %d): %v", name, data, blockSize, err)
+			}
+			if !bytes.Equal(data, original) {
+				t.Fatalf("%s Pad modified its input", name)
+			}
+			if name != "None" && (len(padded) == 0 || len(padded)%blockSize != 0) {
+				t.Fatalf("%s Pad(%d bytes, %d) returned %d bytes", name, len(data), blockSize, len(padded))
+			}
+			got, err := padding.Unpad(padded, blockSize)
+			if err != nil {
+				t.Fatalf("%s Unpad(Pad(%x)): %v", name, data, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("%s round trip of %x returned %x", name, data, got)
+			}
+		}
+	})
+}
+
+// FuzzUnpadMalformed проверяет, что Unpad не паникует на произвольных данных
+// и отвергает их только ошибкой ErrInvalidPadding
+func FuzzUnpadMalformed(f *testing.F) {
+	f.Add([]byte{}, uint8(0))
+	f.Add([]byte{0x00}, uint8(0))
+	f.Add(mustDecodeHex("4142434445464700"), uint8(0))
+	f.Add(mustDecodeHex("4142434445464709"), uint8(0))
+	f.Add(mustDecodeHex("0909090909090909"), uint8(0))
+	f.Add(mustDecodeHex("4142434445468001"), uint8(0))
+	f.Add(mustDecodeHex("41424344454647480000000000000000"), uint8(1))
+	f.Add(bytes.Repeat([]byte{0xFF}, 16), uint8(1))
+
+	f.Fuzz(func(t *testing.T, data []byte, size uint8) {
+		blockSize := fuzzPaddingBlockSizes[int(size)%len(fuzzPaddingBlockSizes)]
+		for _, name := range PaddingNames() {
+			if name == "PKCS5" && blockSize != 8 {
+				continue
+			}
+			padding := paddingByTestName(t, name)
+			original := append([]byte(nil), data...)
+			got, err := padding.Unpad(data, blockSize)
+			if !bytes.Equal(data, original) {
+				t.Fatalf("%s Unpad modified its input", name)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidPadding) {
+					t.Fatalf("%s Unpad(%x) error = %v, want ErrInvalidPadding", name, data, err)
+				}
+				continue
+			}
+			if len(got) > len(data) || !bytes.Equal(got, data[:len(got)]) {
+				t.Fatalf("%s Unpad(%x) = %x, not a prefix of the input", name, data, got)
+			}
+		}
+	})
+}
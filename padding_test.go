@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Схемы с проверкой набивки за постоянное время
+var constantTimePaddings = []struct {
+	name string
+	p    Padding
+}{
+	{"PKCS7", pkcs7Padding{}},
+	{"ANSIX923", ansiX923Padding{}},
+	{"ISO7816", iso7816Padding{}},
+}
+
+func hexBlock(t *testing.T, s string) []byte {
+	t.Helper()
+	return mustDecodeHex(s)
+}
+
+func TestConstantTimeUnpadValid(t *testing.T) {
+	tests := []struct {
+		scheme string
+		padded string
+		want   string
+	}{
+		{"PKCS7", "4142434445460202", "414243444546"},
+		{"PKCS7", "4107070707070707", "41"},
+		{"PKCS7", "0808080808080808", ""},
+		{"PKCS7", "41424344454647480808080808080808", "4142434445464748"},
+		{"ANSIX923", "4142434445460002", "414243444546"},
+		{"ANSIX923", "4100000000000007", "41"},
+		{"ANSIX923", "0000000000000008", ""},
+		{"ISO7816", "4142434445464780", "41424344454647"},
+		{"ISO7816", "4180000000000000", "41"},
+		{"ISO7816", "8000000000000000", ""},
+		{"ISO7816", "41424344454647488000000000000000", "4142434445464748"},
+	}
+	for _, tt := range tests {
+		padding := paddingByTestName(t, tt.scheme)
+		got, err := padding.Unpad(hexBlock(t, tt.padded), 8)
+		if err != nil {
+			t.Errorf("%s Unpad(%s): %v", tt.scheme, tt.padded, err)
+			continue
+		}
+		if want := hexBlock(t, tt.want); !bytes.Equal(got, want) {
+			t.Errorf("%s Unpad(%s) = %x, want %x", tt.scheme, tt.padded, got, want)
+		}
+	}
+}
+
+func TestConstantTimeUnpadInvalid(t *testing.T) {
+	tests := []struct {
+		scheme string
+		padded string
+	}{
+		{"PKCS7", "4142434445464700"},                   // нулевая длина
+		{"PKCS7", "4142434445464709"},                   // длина больше блока
+		{"PKCS7", "4142434445040303"},                   // неверный первый байт набивки
+		{"PKCS7", "4142434403030203"},                   // неверный средний байт набивки
+		{"PKCS7", "0909090909090909"},                   // длина 9 при блоке 8
+		{"ANSIX923", "4142434445464700"},                // нулевая длина
+		{"ANSIX923", "4142434445464709"},                // длина больше блока
+		{"ANSIX923", "4142434445010003"},                // ненулевой байт внутри набивки
+		{"ANSIX923", "4142434445000103"},                // ненулевой байт рядом с длиной
+		{"ISO7816", "4142434445464700"},                 // нет маркера
+		{"ISO7816", "0000000000000000"},                 // только нули
+		{"ISO7816", "4142434445468001"},                 // ненулевой байт после маркера
+		{"ISO7816", "4142434445464781"},                 // другой последний байт
+		{"ISO7816", "41424344454647480000000000000000"}, // маркер вне последнего блока
+	}
+	for _, tt := range tests {
+		padding := paddingByTestName(t, tt.scheme)
+		_, err := padding.Unpad(hexBlock(t, tt.padded), 8)
+		// Любая ошибка содержимого - один и тот же экземпляр ErrInvalidPadding без подробностей
+		if err != ErrInvalidPadding {
+			t.Errorf("%s Unpad(%s) error = %v, want ErrInvalidPadding itself", tt.scheme, tt.padded, err)
+		}
+	}
+}
+
+func TestUnpadInvalidLength(t *testing.T) {
+	for _, tt := range constantTimePaddings {
+		for _, data := range [][]byte{nil, {}, make([]byte, 7), make([]byte, 9)} {
+			_, err := tt.p.Unpad(data, 8)
+			if !errors.Is(err, ErrInvalidPadding) {
+				t.Errorf("%s Unpad(%d bytes) error = %v, want ErrInvalidPadding", tt.name, len(data), err)
+			}
+		}
+	}
+}
+
+func TestPKCS5RejectsOtherBlockSizes(t *testing.T) {
+	p := paddingByTestName(t, "PKCS5")
+	if _, err := p.Pad(make([]byte, 5), 16); !errors.Is(err, ErrInvalidBlockSize) {
+		t.Errorf("Pad with 16-byte blocks: error = %v, want ErrInvalidBlockSize", err)
+	}
+	if _, err := p.Unpad(make([]byte, 16), 16); !errors.Is(err, ErrInvalidBlockSize) {
+		t.Errorf("Unpad with 16-byte blocks: error = %v, want ErrInvalidBlockSize", err)
+	}
+}
+
+func TestPaddingRoundTrip(t *testing.T) {
+	for _, name := range PaddingNames() {
+		padding := paddingByTestName(t, name)
+		for _, blockSize := range []int{8, 16} {
+			if name == "PKCS5" && blockSize != 8 {
+				continue
+			}
+			for n := 0; n <= 2*blockSize; n++ {
+				data := bytes.Repeat([]byte{0x41}, n)
+				padded, err := padding.Pad(data, blockSize)
+				if err != nil {
+					if name == "None" && n%blockSize != 0 {
+						continue
+					}
+					t.Fatalf("%s Pad(%d bytes, %d): %v", name, n, blockSize, err)
+				}
+				if name == "Zeros" && n == 0 {
+					continue // пустые данные с нулевой набивкой неотличимы от блока нулей
+				}
+				got, err := padding.Unpad(padded, blockSize)
+				if err != nil {
+					t.Fatalf("%s Unpad(Pad(%d bytes)): %v", name, n, err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Fatalf("%s round trip of %d bytes returned %x", name, n, got)
+				}
+			}
+		}
+	}
+}
+
+// TestUnpadTimingIndependentOfErrorPosition сравнивает для каждой схемы с проверкой
+// за постоянное время медианное время проверки корректной набивки и набивок с ошибкой
+// в первом байте, в предпоследнем байте и в байте длины. Замеры чередуются, чтобы
+// изменение частоты процессора влияло на все варианты одинаково.
+func TestUnpadTimingIndependentOfErrorPosition(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test skipped in short mode")
+	}
+	const (
+		blockSize = 16
+		samples   = 101
+		rounds    = 2000
+	)
+	for _, tt := range constantTimePaddings {
+		t.Run(tt.name, func(t *testing.T) {
+			// Полный блок набивки: ошибка может оказаться в любом его байте
+			valid, err := tt.p.Pad(bytes.Repeat([]byte{0x41}, blockSize), blockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			variants := map[string][]byte{"valid": valid}
+			for name, pos := range map[string]int{"first byte": blockSize, "next to last byte": len(valid) - 2, "last byte": len(valid) - 1} {
+				bad := append([]byte(nil), valid...)
+				bad[pos] ^= 0x21
+				if _, err := tt.p.Unpad(bad, blockSize); err == nil {
+					t.Fatalf("corrupted %s was accepted", name)
+				}
+				variants[name] = bad
+			}
+
+			times := make(map[string][]time.Duration, len(variants))
+			for i := 0; i < samples; i++ {
+				for name, data := range variants {
+					start := time.Now()
+					for j := 0; j < rounds; j++ {
+						tt.p.Unpad(data, blockSize)
+					}
+					times[name] = append(times[name], time.Since(start))
+				}
+			}
+			median := func(d []time.Duration) time.Duration {
+				sort.Slice(d, func(a, b int) bool { return d[a] < d[b] })
+				return d[len(d)/2]
+			}
+
+			base := median(times["valid"])
+			for name, d := range times {
+				if name == "valid" {
+					continue
+				}
+				got := median(d)
+				if ratio := float64(got) / float64(base); ratio < 0.8 || ratio > 1.25 {
+					t.Errorf("invalid padding (%s) takes %v, valid %v: ratio %.2f", name, got, base, ratio)
+				}
+			}
+		})
+	}
+}
+
+// TestDecryptHidesPaddingErrors портит последний блок шифротекста CBC во всех позициях:
+// Decrypt контекста должен возвращать только сам ErrDecryption, без подробностей о набивке
+func TestDecryptHidesPaddingErrors(t *testing.T) {
+	for _, tt := range constantTimePaddings {
+		mode, ok := LookupPadding(tt.name)
+		if !ok {
+			t.Fatalf("padding %s is not registered", tt.name)
+		}
+		alg, err := NewDES()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, err := NewContext(mustDecodeHex("133457799bbcdff1"), alg,
+			WithMode(CBC), WithPadding(mode), WithIV(mustDecodeHex("0011223344556677")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext, err := ctx.Encrypt([]byte("attack at dawn!"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rejected := 0
+		for pos := len(ciphertext) - 16; pos < len(ciphertext); pos++ {
+			for _, flip := range []byte{0x01, 0x80} {
+				tampered := append([]byte(nil), ciphertext...)
+				tampered[pos] ^= flip
+				_, err := ctx.Decrypt(tampered)
+				if err == nil {
+					continue
+				}
+				rejected++
+				if err != ErrDecryption {
+					t.Errorf("%s: byte %d ^ %#x: error = %v, want ErrDecryption itself", tt.name, pos, flip, err)
+				}
+			}
+		}
+		if rejected == 0 {
+			t.Errorf("%s: no tampered ciphertext was rejected", tt.name)
+		}
+		if _, err := ctx.Decrypt(ciphertext[:len(ciphertext)-3]); err != ErrDecryption {
+			t.Errorf("%s: truncated ciphertext: error = %v, want ErrDecryption itself", tt.name, err)
+		}
+	}
+}
+
+func paddingByTestName(t *testing.T, name string) Padding {
+	t.Helper()
+	mode, ok := LookupPadding(name)
+	if !ok {
+		t.Fatalf("padding %s is not registered", name)
+	}
+	padding, err := mode.Padding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return padding
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Демонстрация атаки Воденэ на режим CBC с набивкой PKCS7.
+//
+// Атаке достаточно одного бита информации на запрос: принят шифротекст или нет.
+// Единая ошибка ErrDecryption и проверка набивки за постоянное время убирают
+// утечку через текст ошибки и время ответа, но не этот бит. Поэтому против
+// оракула, который честно сообщает об успехе расшифрования, атака проходит
+// и на защищенном пути; закрыть ее может только аутентификация шифротекста.
+
+// PaddingOracle - локальный оракул набивки вокруг контекста в режиме CBC
+type PaddingOracle struct {
+	ctx     *CryptoSymmetricContext
+	queries int
+}
+
+// NewPaddingOracle создает оракул; контекст должен быть настроен на CBC
+func NewPaddingOracle(ctx *CryptoSymmetricContext) (*PaddingOracle, error) {
+	if ctx.mode != CBC {
+		return nil, fmt.Errorf("padding oracle requires CBC mode, got %s", ctx.mode)
+	}
+	return &PaddingOracle{ctx: ctx}, nil
+}
+
+// Query сообщает, принял ли контекст шифротекст (т.е. корректна ли набивка)
+func (o *PaddingOracle) Query(ciphertext []byte) bool {
+	o.queries++
+	_, err := o.ctx.Decrypt(ciphertext)
+	return err == nil
+}
+
+// Queries возвращает число обращений к оракулу
+func (o *PaddingOracle) Queries() int {
+	return o.queries
+}
+
+// PaddingOracleAttack восстанавливает открытый текст блок за блоком.
+// Для блока C[i] оракулу отправляется пара C'||C[i], где C' подбирается так,
+// чтобы D(C[i]) xor C' имел корректную набивку PKCS7. Набивка из результата не удаляется.
+func PaddingOracleAttack(oracle func([]byte) bool, iv, ciphertext []byte, blockSize int) ([]byte, error) {
+	if err := checkIVSize(iv, blockSize); err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%blockSize != 0 {
+		return nil, fmt.Errorf("%w: ciphertext length (%d) is not a positive multiple of block size (%d)", ErrInvalidBlockSize, len(ciphertext), blockSize)
+	}
+
+	plaintext := make([]byte, 0, len(ciphertext))
+	previous := iv
+	for bs := 0; bs < len(ciphertext); bs += blockSize {
+		block := ciphertext[bs : bs+blockSize]
+		intermediate, err := recoverIntermediate(oracle, block, blockSize)
+		if err != nil {
+			return nil, fmt.Errorf("attack failed at block %d: %w", bs/blockSize, err)
+		}
+		plaintext = append(plaintext, xorBytes(intermediate, previous)...)
+		previous = block
+	}
+	return plaintext, nil
+}
+
+// recoverIntermediate находит D(block) побайтно, начиная с последнего байта
+func recoverIntermediate(oracle func([]byte) bool, block []byte, blockSize int) ([]byte, error) {
+	intermediate := make([]byte, blockSize)
+	forged := make([]byte, 2*blockSize)
+	copy(forged[blockSize:], block)
+
+	for pos := blockSize - 1; pos >= 0; pos-- {
+		padByte := byte(blockSize - pos)
+
+		// Уже найденные байты выставляем так, чтобы они расшифровывались в padByte
+		for k := pos + 1; k < blockSize; k++ {
+			forged[k] = intermediate[k] ^ padByte
+		}
+
+		found := false
+		for guess := 0; guess < 256; guess++ {
+			forged[pos] = byte(guess)
+			if !oracle(forged) {
+				continue
+			}
+			// Для последнего байта исключаем случайное совпадение с более длинной набивкой
+			if pos == blockSize-1 && pos > 0 {
+				forged[pos-1] ^= 0xFF
+				accepted := oracle(forged)
+				forged[pos-1] ^= 0xFF
+				if !accepted {
+					continue
+				}
+			}
+			intermediate[pos] = byte(guess) ^ padByte
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("no valid padding found for byte %d", pos)
+		}
+	}
+	return intermediate, nil
+}
+
+// RunPaddingOracleDemo атакует DES и DEAL в режиме CBC/PKCS7 и печатает результат.
+// Возвращает ошибку, если атака не удалась или восстановленный текст не совпал с исходным.
+func RunPaddingOracleDemo(w io.Writer) error {
+	secret := []byte("Attack at dawn! The key is under the mat.")
+
+	demos := []struct {
+		name    string
+		newAlgo func() (SymmetricAlgorithm, error)
+		keySize int
+		block   int
+	}{
+		{"DES", func() (SymmetricAlgorithm, error) { return NewDES() }, 8, 8},
+		{"DEAL", func() (SymmetricAlgorithm, error) { return NewDEAL() }, 16, 16},
+	}
+
+	var failed []string
+	for _, d := range demos {
+		algo, err := d.newAlgo()
+		if err != nil {
+			return err
+		}
+		key := generateRandomBytes(d.keySize)
+		iv := generateRandomBytes(d.block)
+
+		ctx, err := NewCryptoSymmetricContext(key, algo, CBC, PKCS7, iv, d.block)
+		if err != nil {
+			return err
+		}
+		ciphertext, err := ctx.Encrypt(secret)
+		if err != nil {
+			return err
+		}
+
+		oracle, err := NewPaddingOracle(ctx)
+		if err != nil {
+			return err
+		}
+		recovered, err := PaddingOracleAttack(oracle.Query, iv, ciphertext, d.block)
+		if err != nil {
+			fmt.Fprintf(w, "%s: атака не удалась после %d запросов: %v\n", d.name, oracle.Queries(), err)
+			failed = append(failed, d.name)
+			continue
+		}
+
+		expected := PKCS7Padding(secret, d.block-len(secret)%d.block)
+		status := "совпадает"
+		if !bytes.Equal(recovered, expected) {
+			status = "НЕ совпадает"
+			failed = append(failed, d.name)
+		}
+		fmt.Fprintf(w, "%s: восстановлено %d байт за %d запросов (%.1f на байт), открытый текст %s\n",
+			d.name, len(recovered), oracle.Queries(), float64(oracle.Queries())/float64(len(recovered)), status)
+		fmt.Fprintf(w, "  %q\n", recovered)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("padding oracle attack did not recover the plaintext for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestPaddingOracleAttackRecoversPlaintext(t *testing.T) {
+	secret := []byte("Attack at dawn! The key is under the mat.")
+	for _, name := range []string{"DES", "DEAL"} {
+		t.Run(name, func(t *testing.T) {
+			spec, ok := LookupAlgorithm(name)
+			if !ok {
+				t.Fatalf("algorithm %s is not registered", name)
+			}
+			random := NewTestRand("paddingoracle/" + name)
+			key, err := GenerateKey(name, 0, random)
+			if err != nil {
+				t.Fatal(err)
+			}
+			iv, err := randomBytes(random, spec.BlockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cipher, err := spec.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, err := NewContext(key, cipher, WithMode(CBC), WithPadding(PKCS7), WithIV(iv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ciphertext, err := ctx.Encrypt(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			oracle, err := NewPaddingOracle(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recovered, err := PaddingOracleAttack(oracle.Query, iv, ciphertext, spec.BlockSize)
+			if err != nil {
+				t.Fatalf("attack failed after %d queries: %v", oracle.Queries(), err)
+			}
+			want := PKCS7Padding(secret, spec.BlockSize-len(secret)%spec.BlockSize)
+			if !bytes.Equal(recovered, want) {
+				t.Fatalf("recovered %q, want %q", recovered, want)
+			}
+			// Не больше 256 запросов на байт, плюс проверки последнего байта блока
+			if max := len(ciphertext) * 257; oracle.Queries() > max {
+				t.Errorf("attack used %d queries, want at most %d", oracle.Queries(), max)
+			}
+		})
+	}
+}
+
+func TestRunPaddingOracleDemo(t *testing.T) {
+	if err := RunPaddingOracleDemo(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewPaddingOracleRequiresCBC(t *testing.T) {
+	des, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := NewContext([]byte("abcdefgh"), des, WithMode(ECB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPaddingOracle(ctx); err == nil {
+		t.Fatal("NewPaddingOracle accepted an ECB context")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"testing"
+)
+
+// TestCrossCheckStdlibModes сверяет наши CBC, CFB, OFB и CTR с crypto/cipher для каждого алгоритма
+func TestCrossCheckStdlibModes(t *testing.T) {
+	for _, name := range AlgorithmNames() {
+		t.Run(name, func(t *testing.T) {
+			spec, _ := LookupAlgorithm(name)
+			random := NewTestRand("stdcipher/" + name)
+			for _, keySize := range spec.KeySizes {
+				key, err := GenerateKey(name, keySize, random)
+				if err != nil {
+					t.Fatal(err)
+				}
+				iv, err := randomBytes(random, spec.BlockSize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				alg, err := spec.New()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := CrossCheckStdlibModes(alg, key, iv, spec.BlockSize); err != nil {
+					t.Errorf("%d-byte key: %v", keySize, err)
+				}
+			}
+		})
+	}
+}
+
+// TestCipherBlockMatchesStdlibDES проверяет адаптер SymmetricAlgorithm -> cipher.Block
+func TestCipherBlockMatchesStdlibDES(t *testing.T) {
+	key := mustDecodeHex("133457799bbcdff1")
+	ours, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ours.SetKey(key); err != nil {
+		t.Fatal(err)
+	}
+	block, err := NewCipherBlock(ours, ours.BlockSize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference, err := des.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.BlockSize() != reference.BlockSize() {
+		t.Fatalf("BlockSize = %d, want %d", block.BlockSize(), reference.BlockSize())
+	}
+
+	src := mustDecodeHex("0123456789abcdef")
+	got, want := make([]byte, 8), make([]byte, 8)
+	block.Encrypt(got, src)
+	reference.Encrypt(want, src)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Encrypt = %x, want %x", got, want)
+	}
+	block.Decrypt(got, want)
+	if !bytes.Equal(got, src) {
+		t.Fatalf("Decrypt = %x, want %x", got, src)
+	}
+}
+
+// TestStdBlockAlgorithmWrapsStdlib проверяет адаптер cipher.Block -> SymmetricAlgorithm
+func TestStdBlockAlgorithmWrapsStdlib(t *testing.T) {
+	alg := NewStdBlockAlgorithm(des.NewCipher)
+	if alg.BlockSize() != 8 {
+		t.Fatalf("BlockSize before SetKey = %d, want 8", alg.BlockSize())
+	}
+	if sizes := alg.KeySizes(); len(sizes) != 1 || sizes[0] != 8 {
+		t.Fatalf("KeySizes = %v, want [8]", sizes)
+	}
+	if err := alg.SetKey(make([]byte, 7)); err == nil {
+		t.Fatal("SetKey accepted a 7-byte key")
+	}
+
+	key := mustDecodeHex("133457799bbcdff1")
+	if err := alg.SetKey(key); err != nil {
+		t.Fatal(err)
+	}
+	ours, err := NewDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ours.SetKey(key); err != nil {
+		t.Fatal(err)
+	}
+	src := mustDecodeHex("0123456789abcdef")
+	got, err := alg.Encrypt(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ours.Encrypt(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Encrypt = %x, want %x", got, want)
+	}
+	if _, err := alg.Encrypt(src[:7]); err == nil {
+		t.Fatal("Encrypt accepted a 7-byte block")
+	}
+
+	// Стандартный режим поверх нашего адаптера должен совпадать со стандартным режимом поверх crypto/des
+	iv := mustDecodeHex("1234567890abcdef")
+	wrapped, err := NewCipherBlock(alg, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference, _ := des.NewCipher(key)
+	plaintext := bytes.Repeat(src, 4)
+	a, b := make([]byte, len(plaintext)), make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(wrapped, iv).CryptBlocks(a, plaintext)
+	cipher.NewCBCEncrypter(reference, iv).CryptBlocks(b, plaintext)
+	if !bytes.Equal(a, b) {
+		t.Fatalf("CBC over adapter = %x, want %x", a, b)
+	}
+}